@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/config"
+)
+
+const apiKeyUsage = `Usage: stars apikey <command>
+
+Manages the API keys stored on MongoDB.
+
+Commands:
+  create -name NAME [-scopes planets:read,planets:write]
+  revoke ID
+  list`
+
+func runAPIKey(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		os.Exit(2)
+	}
+	cfg, err := config.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.APIKeyStore != "mongo" {
+		log.Fatalf("The apikey command manages the mongo key store, but API_KEY_STORE is %q: keys created here would not be accepted by the server", cfg.APIKeyStore)
+	}
+	client, err := connectMongo(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	store, err := apikey.NewMongoStore(context.Background(), client.Database("starwars"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "create":
+		err = createAPIKey(store, args[1:])
+	case "revoke":
+		err = revokeAPIKey(store, args[1:])
+	case "list":
+		err = listAPIKeys(store)
+	default:
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createAPIKey(store apikey.Store, args []string) error {
+	fs := flag.NewFlagSet("apikey create", flag.ExitOnError)
+	name := fs.String("name", "", "name identifying the key owner")
+	scopes := fs.String("scopes", "planets:read", "comma separated list of scopes")
+	fs.Parse(args)
+	if *name == "" {
+		return fmt.Errorf("The -name flag is required")
+	}
+
+	k, token, err := apikey.Generate(*name, strings.Split(*scopes, ","))
+	if err != nil {
+		return err
+	}
+	if err := store.Create(context.Background(), k); err != nil {
+		return err
+	}
+	fmt.Printf("ID:     %s\nName:   %s\nScopes: %s\nToken:  %s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), token)
+	fmt.Fprintln(os.Stderr, "Store the token now, it can not be shown again.")
+	return nil
+}
+
+func revokeAPIKey(store apikey.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: stars apikey revoke ID")
+	}
+	if err := store.Revoke(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("API key %s revoked\n", args[0])
+	return nil
+}
+
+func listAPIKeys(store apikey.Store) error {
+	keys, err := store.List(context.Background())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tSTATUS")
+	for _, k := range keys {
+		status := "active"
+		if k.Revoked() {
+			status = "revoked " + k.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), k.CreatedAt.Format("2006-01-02"), status)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/client"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/exporter"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+	"gopkg.in/yaml.v3"
+)
+
+const planetsUsage = `Usage: stars planets <command> [flags]
+
+Manages the planets of a running API. The address and the credential come from the
+-url and -token flags, or from the STARS_URL and STARS_TOKEN environment variables.
+
+Commands:
+  list    [-page-size 100]
+  get     ID | -name NAME
+  create  -name NAME [-climate CLIMATE] [-terrain TERRAIN]
+  update  ID [-name NAME] [-climate CLIMATE] [-terrain TERRAIN] [-version VERSION]
+  delete  ID [-version VERSION]
+  import  [-f FILE] [-format json|ndjson|csv] [-dry-run] [-upsert] [-columns HEADER:FIELD,...]
+          imports the planets of a file, FILE - is the standard input
+  export  [-f FILE] [-o json|yaml|table|ndjson|csv] [-counts]
+          writes every planet, FILE - is the standard output, ndjson and csv are streamed
+  sync    swapi [-mode merge|overwrite|report]
+          creates and updates the planets out of SWAPI and prints the diff, it needs the planets:admin scope
+
+Flags of every command:
+  -url URL       the API address, http://localhost:8080 by default
+  -token TOKEN   the API key or JWT
+  -o FORMAT      the output format: table, json or yaml
+  -timeout D     the time budget of the command, 30s by default and 10m for import, export and sync`
+
+const (
+	defaultServerURL     = "http://localhost:8080"
+	defaultPlanetsPage   = 100
+	defaultCommandBudget = 30 * time.Second
+	// defaultBulkBudget is the longer budget of the import, the export and the sync, which move the whole catalogue
+	defaultBulkBudget = 10 * time.Minute
+)
+
+// errUsage is returned when the command line can not be run, the usage was already printed
+var errUsage = errors.New("invalid usage")
+
+// planetsCLI is the struct which runs the planets commands, the streams and the environment are
+// fields so the tests can replace them
+type planetsCLI struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+func runPlanets(args []string) {
+	cli := planetsCLI{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}
+	switch err := cli.run(args); err {
+	case nil, flag.ErrHelp:
+	case errUsage:
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func (cli planetsCLI) run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(cli.stderr, planetsUsage)
+		return errUsage
+	}
+	commands := map[string]func([]string) error{
+		"list":   cli.list,
+		"get":    cli.get,
+		"create": cli.create,
+		"update": cli.update,
+		"delete": cli.delete,
+		"import": cli.importPlanets,
+		"export": cli.exportPlanets,
+		"sync":   cli.sync,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintln(cli.stderr, planetsUsage)
+		return errUsage
+	}
+	return command(args[1:])
+}
+
+// commandFlags is the struct which carries the flags shared by every planets command
+type commandFlags struct {
+	set     *flag.FlagSet
+	url     string
+	token   string
+	output  string
+	timeout time.Duration
+	// outputs are the output formats the command accepts
+	outputs []string
+}
+
+func (cli planetsCLI) flags(name, defaultOutput string, budget time.Duration) *commandFlags {
+	f := &commandFlags{set: flag.NewFlagSet("stars planets "+name, flag.ContinueOnError), outputs: []string{"table", "json", "yaml"}}
+	f.set.SetOutput(cli.stderr)
+	// the defaults from the environment are applied after the parsing, so -h never prints the token
+	f.set.StringVar(&f.url, "url", "", "the API address, $STARS_URL or "+defaultServerURL+" when empty")
+	f.set.StringVar(&f.token, "token", "", "the API key or JWT, $STARS_TOKEN when empty")
+	f.set.StringVar(&f.output, "o", defaultOutput, "the output format: table, json or yaml")
+	f.set.DurationVar(&f.timeout, "timeout", budget, "the time budget of the command")
+	return f
+}
+
+// parse parses the flags wherever they are among the positional arguments, which must be as many as expected
+func (cli planetsCLI) parse(f *commandFlags, args []string, positional int) ([]string, error) {
+	values, err := cli.parseAny(f, args)
+	if err == nil && len(values) != positional {
+		fmt.Fprintf(cli.stderr, "%s expects %d argument(s), got %d\n", f.set.Name(), positional, len(values))
+		return nil, errUsage
+	}
+	return values, err
+}
+
+// parseAny parses the flags wherever they are among the positional arguments, which are returned
+func (cli planetsCLI) parseAny(f *commandFlags, args []string) ([]string, error) {
+	var values []string
+	for {
+		if err := f.set.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		if f.set.NArg() == 0 {
+			break
+		}
+		values = append(values, f.set.Arg(0))
+		args = f.set.Args()[1:]
+	}
+	if !contains(f.outputs, f.output) {
+		fmt.Fprintf(cli.stderr, "Unknown output format %q, use %s\n", f.output, strings.Join(f.outputs, ", "))
+		return nil, errUsage
+	}
+	if f.url == "" {
+		f.url = cli.getenv("STARS_URL")
+	}
+	if f.url == "" {
+		f.url = defaultServerURL
+	}
+	if f.token == "" {
+		f.token = cli.getenv("STARS_TOKEN")
+	}
+	return values, nil
+}
+
+// connect creates the client of the API and the context bounded by the command budget
+func (f *commandFlags) connect() (*client.Client, context.Context, context.CancelFunc, error) {
+	c, err := client.New(f.url, client.Options{Token: f.token})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	return c, ctx, cancel, nil
+}
+
+func (cli planetsCLI) list(args []string) error {
+	f := cli.flags("list", "table", defaultCommandBudget)
+	pageSize := f.set.Int("page-size", defaultPlanetsPage, "how many planets are fetched per request, up to 500")
+	if _, err := cli.parse(f, args, 0); err != nil {
+		return err
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	planets := []planet.Planet{}
+	it := c.Planets(ctx, *pageSize)
+	for it.Next() {
+		planets = append(planets, it.Planet())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printPlanets(cli.stdout, f.output, planets)
+}
+
+func (cli planetsCLI) get(args []string) error {
+	f := cli.flags("get", "table", defaultCommandBudget)
+	name := f.set.String("name", "", "finds the planet by its exact name instead of the id")
+	values, err := cli.parseAny(f, args)
+	if err != nil {
+		return err
+	}
+	if (*name == "") == (len(values) != 1) {
+		fmt.Fprintln(cli.stderr, "stars planets get expects an ID or the -name flag")
+		return errUsage
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var p planet.Planet
+	if *name != "" {
+		p, err = c.FindPlanetByName(ctx, *name)
+	} else {
+		p, err = c.GetPlanet(ctx, values[0])
+	}
+	if err != nil {
+		return err
+	}
+	return printPlanets(cli.stdout, f.output, p)
+}
+
+func (cli planetsCLI) create(args []string) error {
+	f := cli.flags("create", "table", defaultCommandBudget)
+	var p planet.Planet
+	f.set.StringVar(&p.Name, "name", "", "the planet name")
+	f.set.StringVar(&p.Climate, "climate", "", "the planet climate")
+	f.set.StringVar(&p.Terrain, "terrain", "", "the planet terrain")
+	if _, err := cli.parse(f, args, 0); err != nil {
+		return err
+	}
+	if p.Name == "" {
+		fmt.Fprintln(cli.stderr, "The -name flag is required")
+		return errUsage
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	created, err := c.CreatePlanet(ctx, p)
+	if err != nil {
+		return err
+	}
+	return printPlanets(cli.stdout, f.output, created)
+}
+
+// update changes only the fields given on the flags, when a version is given it must be the current one
+func (cli planetsCLI) update(args []string) error {
+	f := cli.flags("update", "table", defaultCommandBudget)
+	name := f.set.String("name", "", "the new planet name")
+	climate := f.set.String("climate", "", "the new planet climate")
+	terrain := f.set.String("terrain", "", "the new planet terrain")
+	version := f.set.Int64("version", 0, "the version the planet must be on, any version when zero")
+	values, err := cli.parse(f, args, 1)
+	if err != nil {
+		return err
+	}
+	u := planet.Update{Version: *version}
+	f.set.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name":
+			u.Name = name
+		case "climate":
+			u.Climate = climate
+		case "terrain":
+			u.Terrain = terrain
+		}
+	})
+	if u.IsEmpty() {
+		fmt.Fprintln(cli.stderr, "Nothing to update, give -name, -climate or -terrain")
+		return errUsage
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	updated, err := c.PatchPlanet(ctx, values[0], u)
+	if err != nil {
+		return err
+	}
+	return printPlanets(cli.stdout, f.output, updated)
+}
+
+func (cli planetsCLI) delete(args []string) error {
+	f := cli.flags("delete", "table", defaultCommandBudget)
+	version := f.set.Int64("version", 0, "the version the planet must be on, any version when zero")
+	values, err := cli.parse(f, args, 1)
+	if err != nil {
+		return err
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := c.DeletePlanet(ctx, values[0], *version); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stderr, "Planet %s deleted\n", values[0])
+	return nil
+}
+
+// importPlanets sends a JSON array, NDJSON or CSV file to the API, which imports it a batch at a time
+func (cli planetsCLI) importPlanets(args []string) error {
+	f := cli.flags("import", "table", defaultBulkBudget)
+	file := f.set.String("f", "-", "the file with the planets, - is the standard input")
+	format := f.set.String("format", "", "the file format: json, ndjson or csv, by default it comes from the file extension")
+	dryRun := f.set.Bool("dry-run", false, "validates and counts the rows without writing them")
+	upsert := f.set.Bool("upsert", false, "updates the planet which already has the row name instead of creating another one")
+	columns := f.set.String("columns", "", "maps the CSV header names to the planet fields, as header:field,header:field")
+	if _, err := cli.parse(f, args, 0); err != nil {
+		return err
+	}
+	options := client.ImportOptions{Format: *format, DryRun: *dryRun, Upsert: *upsert}
+	if options.Format == "" {
+		options.Format = formatOf(*file)
+	}
+	if *columns != "" {
+		options.Columns = map[string]string{}
+		for _, pair := range strings.Split(*columns, ",") {
+			i := strings.LastIndexByte(pair, ':')
+			if i <= 0 || i == len(pair)-1 {
+				fmt.Fprintf(cli.stderr, "The column mapping %q must be header:field\n", pair)
+				return errUsage
+			}
+			options.Columns[pair[:i]] = pair[i+1:]
+		}
+	}
+	in, closeIn, err := cli.open(*file)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	report, err := c.ImportPlanets(ctx, in, options)
+	if err != nil && report.Created+report.Updated+report.Failed == 0 {
+		return err
+	}
+	for _, rowError := range report.Errors {
+		fmt.Fprintf(cli.stderr, "Row %d (%s) was not imported: %s\n", rowError.Row, rowError.Name, rowError.Error)
+	}
+	if report.ErrorsTruncated {
+		fmt.Fprintf(cli.stderr, "Only the first %d row errors are listed\n", len(report.Errors))
+	}
+	if err := printReport(cli.stdout, f.output, report); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return errors.Errorf("%d planets were not imported", report.Failed)
+	}
+	return nil
+}
+
+// formatOf guesses the import format from the file extension, JSON when it is unknown
+func formatOf(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return importer.FormatCSV
+	case ".ndjson", ".jsonl":
+		return importer.FormatNDJSON
+	}
+	return importer.FormatJSON
+}
+
+// printReport writes the import report in the output format, the table is a summary line
+func printReport(w io.Writer, format string, report importer.Report) error {
+	if format != "table" {
+		return printPlanets(w, format, report)
+	}
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	_, err := fmt.Fprintf(w, "%s %d planets (%d created, %d updated), %d failed\n", verb, report.Created+report.Updated, report.Created, report.Updated, report.Failed)
+	return err
+}
+
+// exportPlanets writes every planet, the outputs can be imported back. The ndjson and csv outputs are streamed
+// from the API as they are read, the others are fetched a page at a time and written at the end.
+func (cli planetsCLI) exportPlanets(args []string) error {
+	f := cli.flags("export", "json", defaultBulkBudget)
+	f.outputs = append(f.outputs, exporter.FormatNDJSON, exporter.FormatCSV)
+	file := f.set.String("f", "-", "the file the planets are written to, - is the standard output")
+	pageSize := f.set.Int("page-size", defaultPlanetsPage, "how many planets are fetched per request, up to 500")
+	counts := f.set.Bool("counts", false, "fills the number of appearances on movies of the ndjson and csv outputs")
+	if _, err := cli.parse(f, args, 0); err != nil {
+		return err
+	}
+	outputSet := false
+	f.set.Visit(func(fl *flag.Flag) { outputSet = outputSet || fl.Name == "o" })
+	if !outputSet && *file != "-" {
+		f.output = outputOf(*file)
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var stream io.ReadCloser
+	planets := []planet.Planet{}
+	if f.output == exporter.FormatNDJSON || f.output == exporter.FormatCSV {
+		if stream, err = c.ExportPlanets(ctx, client.ExportOptions{Format: f.output, Counts: *counts}); err != nil {
+			return err
+		}
+		defer stream.Close()
+	} else {
+		it := c.Planets(ctx, *pageSize)
+		for it.Next() {
+			planets = append(planets, it.Planet())
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+
+	out := cli.stdout
+	if *file != "-" {
+		created, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer created.Close()
+		out = created
+	}
+	if stream != nil {
+		if _, err := io.Copy(out, stream); err != nil {
+			return err
+		}
+		if *file != "-" {
+			fmt.Fprintf(cli.stderr, "Exported the planets to %s\n", *file)
+		}
+		return nil
+	}
+	if err := printPlanets(out, f.output, planets); err != nil {
+		return err
+	}
+	if *file != "-" {
+		fmt.Fprintf(cli.stderr, "Exported %d planets to %s\n", len(planets), *file)
+	}
+	return nil
+}
+
+// outputOf guesses the export output from the file extension, JSON when it is unknown
+func outputOf(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return exporter.FormatCSV
+	case ".ndjson", ".jsonl":
+		return exporter.FormatNDJSON
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "json"
+}
+
+// sync creates and updates the planets out of the catalogue of the source, SWAPI is the only one
+func (cli planetsCLI) sync(args []string) error {
+	f := cli.flags("sync", "table", defaultBulkBudget)
+	mode := f.set.String("mode", swapisync.ModeMerge, "merge fills the empty fields, overwrite replaces the fields which differ and report only prints the diff")
+	values, err := cli.parse(f, args, 1)
+	if err != nil {
+		return err
+	}
+	if values[0] != "swapi" {
+		fmt.Fprintf(cli.stderr, "Unknown sync source %q, use swapi\n", values[0])
+		return errUsage
+	}
+	if !swapisync.ValidMode(*mode) {
+		fmt.Fprintf(cli.stderr, "Unknown sync mode %q, use merge, overwrite or report\n", *mode)
+		return errUsage
+	}
+	c, ctx, cancel, err := f.connect()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	report, err := c.SyncSWAPI(ctx, *mode)
+	if err != nil && report.Mode == "" {
+		return err
+	}
+	if err := printSyncReport(cli.stdout, f.output, report); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return errors.Errorf("%d planets were not synced", report.Failed)
+	}
+	return nil
+}
+
+// printSyncReport writes the sync report in the output format, the table is the diff:
+// + for a created planet, ~ for an updated one, = for one whose differences were kept and ! for a failure
+func printSyncReport(w io.Writer, format string, report swapisync.Report) error {
+	if format != "table" {
+		return printPlanets(w, format, report)
+	}
+	marks := map[string]string{swapisync.ActionCreate: "+", swapisync.ActionUpdate: "~", swapisync.ActionKeep: "="}
+	for _, change := range report.Changes {
+		mark := marks[change.Action]
+		if change.Error != "" {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, change.Name)
+		for _, field := range change.Fields {
+			kept := ""
+			if field.Kept {
+				kept = " (kept)"
+			}
+			fmt.Fprintf(w, "    %s: %q -> %q%s\n", field.Field, field.Local, field.SWAPI, kept)
+		}
+		if change.Error != "" {
+			fmt.Fprintf(w, "    error: %s\n", change.Error)
+		}
+	}
+	verb := "Synced"
+	if report.Mode == swapisync.ModeReport {
+		verb = "Would sync"
+	}
+	_, err := fmt.Fprintf(w, "%s with SWAPI: %d created, %d updated, %d kept, %d unchanged, %d failed\n",
+		verb, report.Created, report.Updated, report.Kept, report.Unchanged, report.Failed)
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// open opens the file to read, - is the standard input
+func (cli planetsCLI) open(file string) (io.Reader, func(), error) {
+	if file == "-" {
+		return cli.stdin, func() {}, nil
+	}
+	opened, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return opened, func() { opened.Close() }, nil
+}
+
+// printPlanets writes a planet, or a list of planets, in the output format
+func printPlanets(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		data, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	planets, ok := v.([]planet.Planet)
+	if !ok {
+		planets = []planet.Planet{v.(planet.Planet)}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tCLIMATE\tTERRAIN\tFILMS\tVERSION")
+	for _, p := range planets {
+		version := ""
+		if p.Version != 0 {
+			version = strconv.FormatInt(p.Version, 10)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", p.ID, p.Name, p.Climate, p.Terrain, p.NumberOfAppearancesOnMovies, version)
+	}
+	return tw.Flush()
+}
+
+// toYAML encodes v as YAML with the names and the order of its JSON encoding, which is read as a YAML document
+func toYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// blockStyle drops the flow style of the JSON syntax, the scalars are quoted again only when they need it
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/api"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/rafaelreinert/stars/pkg/swapi"
+	"github.com/rafaelreinert/stars/pkg/swapi/swapitest"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticCounter map[string]int
+
+func (c staticCounter) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	return c[name], nil
+}
+
+// newTestAPI serves the API over a memory repository and a fake SWAPI, the keys are required when given
+func newTestAPI(t *testing.T, keys ...apikey.Key) (*httptest.Server, repository.PlanetRepository) {
+	fakeSWAPI := swapitest.NewServer(
+		planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+		planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"},
+	)
+	t.Cleanup(fakeSWAPI.Close)
+	rep := memrep.NewMemoryRepository()
+	s := &api.Server{
+		PlanetRepository: rep,
+		CountRetriever:   staticCounter{"Tatooine": 5},
+		Cfg:              config.Config{AllowInsecureNoAuth: len(keys) == 0},
+		PlanetSource:     swapi.SWAPI{APIURL: fakeSWAPI.URL},
+	}
+	if len(keys) > 0 {
+		s.KeyStore = apikey.NewMemoryStore(keys...)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts, rep
+}
+
+// runCLI runs the planets command against the server, it returns the standard output and error
+func runCLI(serverURL, stdin string, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	cli := planetsCLI{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(name string) string {
+			if name == "STARS_URL" {
+				return serverURL
+			}
+			return ""
+		},
+	}
+	err := cli.run(args)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestPlanetsCreateGetAndList(t *testing.T) {
+	ts, _ := newTestAPI(t)
+
+	out, _, err := runCLI(ts.URL, "", "create", "-name", "Tatooine", "-climate", "arid", "-terrain", "desert")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "Tatooine")
+
+	out, _, err = runCLI(ts.URL, "", "get", "-name", "Tatooine", "-o", "json")
+	assert.NoError(t, err)
+	var found planet.Planet
+	assert.NoError(t, json.Unmarshal([]byte(out), &found))
+	assert.Equal(t, 5, found.NumberOfAppearancesOnMovies)
+
+	out, _, err = runCLI(ts.URL, "", "get", found.ID, "-o", "yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "id: \""+found.ID+"\"\nname: Tatooine\nclimate: arid\nterrain: desert\nnumberOfAppearancesOnMovies: 5\n", out)
+
+	runCLI(ts.URL, "", "create", "-name", "Hoth")
+	out, _, err = runCLI(ts.URL, "", "list", "-page-size", "1")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "ID"))
+	assert.Contains(t, lines[1], "Tatooine")
+	assert.Contains(t, lines[2], "Hoth")
+}
+
+func TestPlanetsUpdateAndDelete(t *testing.T) {
+	ts, rep := newTestAPI(t)
+	created, _ := rep.Create(context.Background(), planet.Planet{Name: "Alderaan", Climate: "temperate"})
+
+	_, _, err := runCLI(ts.URL, "", "update", created.ID, "-terrain", "grasslands", "-version", "1")
+	assert.NoError(t, err)
+	updated, _ := rep.FindByID(context.Background(), created.ID)
+	assert.Equal(t, planet.Planet{ID: created.ID, Name: "Alderaan", Climate: "temperate", Terrain: "grasslands", Version: 2}, updated)
+
+	_, _, err = runCLI(ts.URL, "", "delete", created.ID, "-version", "1")
+	assert.Error(t, err, "A stale version should not be deleted")
+	_, stderr, err := runCLI(ts.URL, "", "delete", created.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "deleted")
+	_, err = rep.FindByID(context.Background(), created.ID)
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestPlanetsImportAndExport(t *testing.T) {
+	ts, rep := newTestAPI(t)
+
+	out, _, err := runCLI(ts.URL, `[{"name": "Hoth", "climate": "frozen"}, {"name": "Dagobah", "terrain": "swamp"}]`, "import")
+	assert.NoError(t, err)
+	assert.Equal(t, "Imported 2 planets (2 created, 0 updated), 0 failed\n", out)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 2)
+
+	dir, _ := ioutil.TempDir("", "stars")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "planets.json")
+	_, _, err = runCLI(ts.URL, "", "export", "-f", file)
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadFile(file)
+	var exported []planet.Planet
+	assert.NoError(t, json.Unmarshal(data, &exported))
+	assert.Len(t, exported, 2)
+	assert.Equal(t, "Hoth", exported[0].Name)
+	assert.Equal(t, "swamp", exported[1].Terrain)
+
+	_, _, err = runCLI(ts.URL, `{"name": "Hoth"}`, "import")
+	assert.Error(t, err, "The import should expect an array")
+}
+
+func TestPlanetsImportCSVWithUpsert(t *testing.T) {
+	ts, rep := newTestAPI(t)
+	rep.Create(context.Background(), planet.Planet{Name: "Hoth", Climate: "temperate"})
+	dir, _ := ioutil.TempDir("", "stars")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "planets.csv")
+	ioutil.WriteFile(file, []byte("Planet,Weather\nHoth,frozen\nBespin,temperate\n,arid\n"), 0600)
+
+	out, stderr, err := runCLI(ts.URL, "", "import", "-f", file, "-upsert", "-columns", "Planet:name,Weather:climate", "-dry-run")
+	assert.Error(t, err, "The failed rows should fail the command")
+	assert.Equal(t, "Would import 2 planets (1 created, 1 updated), 1 failed\n", out)
+	assert.Contains(t, stderr, "Row 3 () was not imported: the planet name is required")
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 1)
+
+	out, _, _ = runCLI(ts.URL, "", "import", "-f", file, "-upsert", "-columns", "Planet:name,Weather:climate", "-o", "json")
+	var report importer.Report
+	assert.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, 1, report.Updated)
+	hoth, _ := rep.FindByName(context.Background(), "Hoth")
+	assert.Equal(t, "frozen", hoth.Climate)
+}
+
+func TestPlanetsExportStreamsNDJSONAndCSV(t *testing.T) {
+	ts, rep := newTestAPI(t)
+	tatooine, _ := rep.Create(context.Background(), planet.Planet{Name: "Tatooine", Climate: "arid"})
+
+	out, _, err := runCLI(ts.URL, "", "export", "-o", "ndjson", "-counts")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"`+tatooine.ID+`","name":"Tatooine","climate":"arid","terrain":"","version":1,"numberOfAppearancesOnMovies":5}`+"\n", out)
+
+	dir, _ := ioutil.TempDir("", "stars")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "planets.csv")
+	_, stderr, err := runCLI(ts.URL, "", "export", "-f", file)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, file)
+	data, _ := ioutil.ReadFile(file)
+	assert.Equal(t, "id,name,climate,terrain,version\n"+tatooine.ID+",Tatooine,arid,,1\n", string(data), "The output should come from the extension")
+
+	_, _, err = runCLI(ts.URL, "", "import", "-f", file, "-upsert")
+	assert.NoError(t, err, "The export should be imported back")
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 1)
+}
+
+func TestPlanetsSyncSWAPI(t *testing.T) {
+	ts, rep := newTestAPI(t)
+	rep.Create(context.Background(), planet.Planet{Name: "Tatooine", Climate: "hot"})
+
+	out, _, err := runCLI(ts.URL, "", "sync", "swapi", "-mode", "report")
+	assert.NoError(t, err)
+	assert.Equal(t, "~ Tatooine\n"+
+		"    climate: \"hot\" -> \"arid\"\n"+
+		"    terrain: \"\" -> \"desert\"\n"+
+		"+ Hoth\n"+
+		"Would sync with SWAPI: 1 created, 1 updated, 0 kept, 0 unchanged, 0 failed\n", out)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 1, "The report mode should not write")
+
+	out, _, err = runCLI(ts.URL, "", "sync", "swapi")
+	assert.NoError(t, err)
+	assert.Equal(t, "~ Tatooine\n"+
+		"    climate: \"hot\" -> \"arid\" (kept)\n"+
+		"    terrain: \"\" -> \"desert\"\n"+
+		"+ Hoth\n"+
+		"Synced with SWAPI: 1 created, 1 updated, 0 kept, 0 unchanged, 0 failed\n", out)
+
+	out, _, err = runCLI(ts.URL, "", "sync", "swapi", "-mode", "overwrite", "-o", "json")
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"updated": 1`)
+	tatooine, _ := rep.FindByName(context.Background(), "Tatooine")
+	assert.Equal(t, "arid", tatooine.Climate)
+}
+
+func TestOutputOf(t *testing.T) {
+	assert.Equal(t, "csv", outputOf("planets.csv"))
+	assert.Equal(t, "ndjson", outputOf("planets.jsonl"))
+	assert.Equal(t, "yaml", outputOf("planets.yml"))
+	assert.Equal(t, "json", outputOf("planets.txt"))
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, importer.FormatCSV, formatOf("planets.CSV"))
+	assert.Equal(t, importer.FormatNDJSON, formatOf("planets.jsonl"))
+	assert.Equal(t, importer.FormatNDJSON, formatOf("planets.ndjson"))
+	assert.Equal(t, importer.FormatJSON, formatOf("-"))
+}
+
+func TestPlanetsUsesTheToken(t *testing.T) {
+	keys, _ := apikey.ParseKeys([]string{"ops:s3cr3t:planets:read"})
+	ts, _ := newTestAPI(t, keys...)
+
+	_, _, err := runCLI(ts.URL, "", "list")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	_, _, err = runCLI(ts.URL, "", "list", "-token", "s3cr3t")
+	assert.NoError(t, err)
+}
+
+func TestPlanetsUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"get"},
+		{"get", "1", "-name", "Hoth"},
+		{"create"},
+		{"update", "1"},
+		{"delete"},
+		{"list", "-o", "xml"},
+		{"list", "-o", "csv"},
+		{"export", "-o", "xml"},
+		{"list", "-unknown"},
+		{"import", "-columns", "planet"},
+		{"sync"},
+		{"sync", "starwars"},
+		{"sync", "swapi", "-mode", "replace"},
+	} {
+		_, stderr, err := runCLI("http://localhost:1", "", args...)
+		assert.Equal(t, errUsage, err, strings.Join(args, " "))
+		assert.NotEmpty(t, stderr, strings.Join(args, " "))
+	}
+
+	_, stderr, err := runCLI("http://localhost:1", "", "list", "-h")
+	assert.Equal(t, flag.ErrHelp, err)
+	assert.Contains(t, stderr, "-page-size")
+}
+
+func TestPlanetsHelpDoesNotShowTheToken(t *testing.T) {
+	os.Setenv("STARS_TOKEN", "s3cr3t")
+	defer os.Unsetenv("STARS_TOKEN")
+	var stderr bytes.Buffer
+	cli := planetsCLI{stdout: &bytes.Buffer{}, stderr: &stderr, getenv: os.Getenv}
+
+	cli.run([]string{"list", "-h"})
+
+	assert.NotContains(t, stderr.String(), "s3cr3t")
+}
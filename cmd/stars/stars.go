@@ -2,17 +2,68 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/pkg/errors"
 	"github.com/rafaelreinert/stars/pkg/api"
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/auth/jwt"
 	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/idempotency"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/rafaelreinert/stars/pkg/planet/outbox"
+	"github.com/rafaelreinert/stars/pkg/planet/purger"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
 	"github.com/rafaelreinert/stars/pkg/planet/repository/mongorep"
 	"github.com/rafaelreinert/stars/pkg/swapi"
+	"github.com/rafaelreinert/stars/pkg/webhook"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const usage = `Usage: stars [command]
+
+Commands:
+  serve     runs the API, the default command
+  planets   manages the planets of a running API
+  import    imports a JSON, NDJSON or CSV file of planets, like "stars planets import"
+  export    exports every planet, like "stars planets export"
+  sync      creates and updates the planets out of SWAPI, like "stars planets sync swapi"
+  apikey    manages the API keys stored on MongoDB
+
+Run "stars <command> -h" for the command usage.`
+
 func main() {
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "serve":
+		serve()
+	case "planets":
+		runPlanets(os.Args[2:])
+	case "import":
+		runPlanets(append([]string{"import"}, os.Args[2:]...))
+	case "export":
+		runPlanets(append([]string{"export"}, os.Args[2:]...))
+	case "sync":
+		runPlanets(append([]string{"sync"}, os.Args[2:]...))
+	case "apikey":
+		runAPIKey(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// serve runs the API until it fails
+func serve() {
 	log.Println("Initiating stars...")
 	log.Println("Initiating Config...")
 	cfg, err := config.New()
@@ -21,27 +72,154 @@ func main() {
 	}
 	log.Println("Config OK")
 	log.Println("Initiating Mongo Client...")
-	client, err := mongo.NewClient(options.Client().ApplyURI(cfg.DBURI))
+	client, err := connectMongo(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = client.Connect(context.Background())
+	defer client.Disconnect(context.Background())
+	log.Println("Mongo Client OK")
+
+	keyStore, err := newKeyStore(cfg, client.Database("starwars"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokenVerifier, err := newTokenVerifier(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	idempotencyStore, err := idempotency.NewMongoStore(context.Background(), client.Database("starwars"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Disconnect(context.Background())
 
-	err = client.Ping(context.Background(), nil)
+	auditLog, err := audit.NewMongoStore(context.Background(), client.Database("starwars"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("Mongo Client OK")
+	events := event.NewBus(cfg.EventsBuffer)
+	webhooks, err := webhook.NewMongoStore(context.Background(), client.Database("starwars"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	dispatcher := webhook.NewDispatcher(webhooks, webhook.Options{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Backoff:     cfg.WebhookBackoff,
+		MaxBackoff:  cfg.WebhookMaxBackoff,
+		Timeout:     cfg.WebhookTimeout,
+	})
+	go dispatcher.Run(context.Background(), cfg.WebhookPollInterval)
+
+	planetRepository, err := newPlanetRepository(cfg, client.Database("starwars"), auditLog, events, dispatcher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.SoftDeleteRetention > 0 {
+		go purger.Run(context.Background(), planetRepository, cfg.SoftDeleteRetention, cfg.PurgeInterval)
+	}
 
 	s := api.Server{
-		PlanetRepository: mongorep.NewMongoRepository(client.Database("starwars")),
+		PlanetRepository: planetRepository,
 		CountRetriever:   swapi.SWAPI{APIURL: cfg.SWAPIURL},
 		Cfg:              cfg,
+		KeyStore:         keyStore,
+		TokenVerifier:    tokenVerifier,
+		IdempotencyStore: idempotencyStore,
+		AuditLog:         auditLog,
+		Events:           events,
+		Webhooks:         webhooks,
+		PlanetSource:     swapi.SWAPI{APIURL: cfg.SWAPIURL},
+		FilmsLister:      swapi.SWAPI{APIURL: cfg.SWAPIURL},
 	}
 	log.Println("Stars OK")
 	s.ListenAndServe()
 }
+
+// newPlanetRepository composes the planet repository, with the outbox the events are written with the changes
+// and relayed to the bus and the webhooks, otherwise they are published after the changes
+func newPlanetRepository(cfg config.Config, db *mongo.Database, auditLog audit.Store, events *event.Bus, dispatcher *webhook.Dispatcher) (repository.PlanetRepository, error) {
+	if err := mongorep.CreateIndexes(context.Background(), db); err != nil {
+		return nil, err
+	}
+	if !cfg.OutboxEnabled {
+		go dispatcher.Listen(context.Background(), events)
+		return event.NewRepository(audit.NewRepository(mongorep.NewMongoRepository(db), auditLog), events), nil
+	}
+	rep, err := mongorep.NewOutboxRepository(context.Background(), db)
+	if err != nil {
+		return nil, err
+	}
+	relay := outbox.Relay{Source: mongorep.NewOutboxSource(db), Sinks: []outbox.Sink{outbox.BusSink{Bus: events}, dispatcher}}
+	go relay.Run(context.Background(), cfg.OutboxPollInterval)
+	return audit.NewRepository(rep, auditLog), nil
+}
+
+func connectMongo(cfg config.Config) (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(cfg.DBURI))
+	if err != nil {
+		return nil, err
+	}
+	err = client.Connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func newKeyStore(cfg config.Config, db *mongo.Database) (apikey.Store, error) {
+	switch cfg.APIKeyStore {
+	case "none":
+		if !cfg.JWTEnabled() {
+			log.Println("WARNING: authentication is disabled, anyone reaching the service can change the planets")
+		}
+		return nil, nil
+	case "memory":
+		keys, err := apikey.ParseKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		return apikey.NewMemoryStore(keys...), nil
+	case "mongo":
+		return apikey.NewMongoStore(context.Background(), db)
+	default:
+		return nil, errors.Errorf("Unknown API key store %q, use none, memory or mongo", cfg.APIKeyStore)
+	}
+}
+
+func newTokenVerifier(cfg config.Config) (*jwt.Verifier, error) {
+	if !cfg.JWTEnabled() {
+		return nil, nil
+	}
+	var keys jwt.KeySet
+	if cfg.JWTHS256Secret != "" {
+		keys = append(keys, jwt.HMACKey(cfg.JWTHS256Secret))
+	}
+	if cfg.JWTRS256PublicKeyFile != "" {
+		k, err := jwt.LoadRSAPublicKey(cfg.JWTRS256PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if cfg.JWTJWKSFile != "" {
+		jwks, err := jwt.LoadJWKS(cfg.JWTJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwks...)
+	}
+	roleScopes, err := jwt.ParseRoleScopes(cfg.JWTRoleScopes)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.NewVerifier(keys, jwt.Options{
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		Leeway:     cfg.JWTLeeway,
+		RolesClaim: cfg.JWTRolesClaim,
+		RoleScopes: roleScopes,
+	})
+}
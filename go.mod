@@ -8,5 +8,8 @@ require (
 	github.com/gorilla/mux v1.7.4
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
+	github.com/vmihailenco/msgpack/v4 v4.3.13
 	go.mongodb.org/mongo-driver v1.3.4
+	golang.org/x/text v0.3.2
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
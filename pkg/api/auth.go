@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/auth/jwt"
+)
+
+// requireScope authenticates the request bearer API key or JWT and checks if it was granted the scope,
+// the handler is only left unprotected when the authentication was explicitly disabled.
+// The failed authentications are limited per client IP, and an IP over the limit is refused before
+// its credentials reach the API key store or the token verifier.
+func (s *Server) requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	if s.KeyStore == nil && s.TokenVerifier == nil && s.Cfg.AllowInsecureNoAuth {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.KeyStore == nil && s.TokenVerifier == nil {
+			log.Println("Refusing the request, no API key store or token verifier is configured")
+			handleError(w, http.StatusUnauthorized, "Authentication is not configured on the server")
+			return
+		}
+		ip := clientIPKey(r)
+		if s.authFailureLimiter != nil {
+			if result := s.authFailureLimiter.Check(ip); !result.Allowed {
+				tooManyRequests(w, result)
+				return
+			}
+		}
+		token, ok := bearerToken(r)
+		if !ok {
+			s.authenticationFailed(ip)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="stars"`)
+			handleError(w, http.StatusUnauthorized, "A bearer API key or token is required")
+			return
+		}
+		p, err := s.authenticate(r.Context(), token)
+		if cause := errors.Cause(err); cause == apikey.ErrInvalidKey || cause == jwt.ErrInvalidToken {
+			s.authenticationFailed(ip)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="stars", error="invalid_token"`)
+			handleError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			handleContextError(r.Context(), w, http.StatusInternalServerError, "Error authenticating the request", err)
+			return
+		}
+		if !p.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="stars", error="insufficient_scope", scope="`+scope+`"`)
+			handleError(w, http.StatusForbidden, "The credential was not granted the "+scope+" scope")
+			return
+		}
+		h(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	}
+}
+
+// authenticationFailed spends a token of the client IP failed authentications bucket
+func (s *Server) authenticationFailed(ip string) {
+	if s.authFailureLimiter != nil {
+		s.authFailureLimiter.Allow(ip)
+	}
+}
+
+// authenticate validates JWTs with the token verifier and every other bearer token against the API key store
+func (s *Server) authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	if s.TokenVerifier != nil && jwt.LooksLikeJWT(token) {
+		return s.TokenVerifier.Verify(token)
+	}
+	if s.KeyStore == nil {
+		return auth.Principal{}, jwt.ErrInvalidToken
+	}
+	return apikey.Authenticate(ctx, s.KeyStore, token)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/auth/jwt"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	reader, readerToken, _ := apikey.Generate("reader", []string{auth.ScopePlanetsRead})
+	writer, writerToken, _ := apikey.Generate("writer", []string{auth.ScopePlanetsWrite})
+	admin, adminToken, _ := apikey.Generate("admin", []string{auth.ScopePlanetsAdmin})
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		KeyStore:         apikey.NewMemoryStore(reader, writer, admin),
+	}
+	h := s.handler()
+	body := `{"name":"Tatooine","climate":"arid","terrain":"desert"}`
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		status int
+	}{
+		{"ListWithoutKey", http.MethodGet, "/planets", "", http.StatusUnauthorized},
+		{"ListWithReadKey", http.MethodGet, "/planets", readerToken, http.StatusOK},
+		{"ListWithWriteOnlyKey", http.MethodGet, "/planets", writerToken, http.StatusForbidden},
+		{"GetByIDWithoutKey", http.MethodGet, "/planets/1", "", http.StatusUnauthorized},
+		{"GetByIDWithReadKey", http.MethodGet, "/planets/1", readerToken, http.StatusOK},
+		{"GetByIDWithWriteOnlyKey", http.MethodGet, "/planets/1", writerToken, http.StatusForbidden},
+		{"GetByNameWithoutKey", http.MethodGet, "/planets?name=Tatooine", "", http.StatusUnauthorized},
+		{"GetByNameWithReadKey", http.MethodGet, "/planets?name=Tatooine", readerToken, http.StatusOK},
+		{"CreateWithoutKey", http.MethodPost, "/planets", "", http.StatusUnauthorized},
+		{"CreateWithReadKey", http.MethodPost, "/planets", readerToken, http.StatusForbidden},
+		{"CreateWithWriteKey", http.MethodPost, "/planets", writerToken, http.StatusCreated},
+		{"UpdateWithReadKey", http.MethodPut, "/planets/1", readerToken, http.StatusForbidden},
+		{"UpdateWithWriteKey", http.MethodPut, "/planets/1", writerToken, http.StatusOK},
+		{"DeleteWithInvalidKey", http.MethodDelete, "/planets/1", "stars_invalid", http.StatusUnauthorized},
+		{"DeleteWithReadKey", http.MethodDelete, "/planets/1", readerToken, http.StatusForbidden},
+		{"DeleteWithWriteKey", http.MethodDelete, "/planets/1", writerToken, http.StatusOK},
+		{"ListDeletedWithWriteKey", http.MethodGet, "/planets:deleted", writerToken, http.StatusForbidden},
+		{"ListDeletedWithAdminKey", http.MethodGet, "/planets:deleted", adminToken, http.StatusOK},
+		{"HistoryWithReadKey", http.MethodGet, "/planets/1/history", readerToken, http.StatusForbidden},
+		{"RestoreWithReadKey", http.MethodPost, "/planets/1:restore", readerToken, http.StatusForbidden},
+		{"RestoreWithWriteKey", http.MethodPost, "/planets/1:restore", writerToken, http.StatusOK},
+		{"WebhooksWithWriteKey", http.MethodGet, "/webhooks", writerToken, http.StatusForbidden},
+		{"CreateWebhookWithWriteKey", http.MethodPost, "/webhooks", writerToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	verifier, _ := jwt.NewVerifier(jwt.KeySet{jwt.HMACKey("secret")}, jwt.Options{
+		Issuer:     "https://idp.example.com",
+		Audience:   "stars",
+		RoleScopes: map[string][]string{"planets-editor": {auth.ScopePlanetsRead, auth.ScopePlanetsWrite}, "planets-reader": {auth.ScopePlanetsRead}},
+	})
+	reader, readerToken, _ := apikey.Generate("reader", []string{auth.ScopePlanetsRead})
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		KeyStore:         apikey.NewMemoryStore(reader),
+		TokenVerifier:    verifier,
+	}
+	h := s.handler()
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+	}{
+		{"ReaderCanNotDelete", http.MethodDelete, signTestJWT("secret", "planets-reader", time.Hour), http.StatusForbidden},
+		{"ReaderCanRead", http.MethodGet, signTestJWT("secret", "planets-reader", time.Hour), http.StatusOK},
+		{"ExpiredToken", http.MethodDelete, signTestJWT("secret", "planets-editor", -time.Hour), http.StatusUnauthorized},
+		{"WrongSignature", http.MethodDelete, signTestJWT("other", "planets-editor", time.Hour), http.StatusUnauthorized},
+		{"APIKeysStillWork", http.MethodGet, readerToken, http.StatusOK},
+		{"EditorCanDelete", http.MethodDelete, signTestJWT("secret", "planets-editor", time.Hour), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/planets/1", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func signTestJWT(secret, role string, expiresIn time.Duration) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   "https://idp.example.com",
+		"aud":   "stars",
+		"sub":   "alice",
+		"exp":   time.Now().Add(expiresIn).Unix(),
+		"roles": []string{role},
+	})
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestRoutesAreRefusedWithoutKeyStore(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestBearerToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/planets", nil)
+	_, ok := bearerToken(req)
+	assert.False(t, ok)
+
+	req.Header.Set("Authorization", "bearer stars_abc ")
+	token, ok := bearerToken(req)
+	assert.True(t, ok)
+	assert.Equal(t, "stars_abc", token)
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, ok = bearerToken(req)
+	assert.False(t, ok)
+}
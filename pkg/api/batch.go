@@ -0,0 +1,104 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+// maxBatchOperations bounds the operations of a single batch request
+const maxBatchOperations = 1000
+
+// batchRequest is the body of POST /planets:batch, the operations are ordered unless ordered is false
+type batchRequest struct {
+	Ordered    *bool            `json:"ordered"`
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Op     repository.BulkOperationType `json:"op"`
+	ID     string                       `json:"id"`
+	Planet planet.Planet                `json:"planet"`
+}
+
+type batchResult struct {
+	Op     repository.BulkOperationType `json:"op"`
+	ID     string                       `json:"id,omitempty"`
+	Status int                          `json:"status"`
+	Error  string                       `json:"error,omitempty"`
+}
+
+func (s *Server) batchPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request batchRequest
+	if err := decodeBody(r, &request); err != nil {
+		log.Println("Error Decoding the batch", err)
+		handleDecodeError(w, err, "Batch JSON is Invalid")
+		return
+	}
+	if len(request.Operations) == 0 {
+		handleError(w, http.StatusBadRequest, "The batch has no operations")
+		return
+	}
+	if len(request.Operations) > maxBatchOperations {
+		handleError(w, http.StatusRequestEntityTooLarge, "A batch accepts at most "+strconv.Itoa(maxBatchOperations)+" operations")
+		return
+	}
+
+	ops := make([]repository.BulkOperation, len(request.Operations))
+	for i, op := range request.Operations {
+		switch op.Op {
+		case repository.BulkCreate:
+		case repository.BulkUpdate, repository.BulkDelete:
+			if op.ID == "" {
+				handleError(w, http.StatusBadRequest, "Operation "+strconv.Itoa(i)+" requires an id")
+				return
+			}
+			// batch changes can not carry an If-Match, so they are refused when every change must be conditional
+			if s.Cfg.RequireIfMatch {
+				handleError(w, http.StatusPreconditionRequired, "Batch updates and deletes are disabled while If-Match is required")
+				return
+			}
+		default:
+			handleError(w, http.StatusBadRequest, "Operation "+strconv.Itoa(i)+" must be create, update or delete")
+			return
+		}
+		op.Planet.ID = op.ID
+		ops[i] = repository.BulkOperation{Type: op.Op, Planet: op.Planet}
+	}
+
+	ordered := request.Ordered == nil || *request.Ordered
+	bulkResults, err := s.PlanetRepository.Bulk(ctx, ops, ordered)
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error running the batch", err)
+		return
+	}
+
+	results := make([]batchResult, len(bulkResults))
+	for i, result := range bulkResults {
+		results[i] = batchResult{Op: ops[i].Type, ID: result.ID, Status: batchStatus(ops[i].Type, result.Err)}
+		if result.Err != nil {
+			results[i].Error = result.Err.Error()
+		}
+	}
+	writeResponse(w, r, http.StatusOK, map[string][]batchResult{"results": results})
+}
+
+// batchStatus is the HTTP status the operation would have as a single request
+func batchStatus(op repository.BulkOperationType, err error) int {
+	switch {
+	case err == repository.ErrNotExecuted:
+		return http.StatusFailedDependency
+	case err == repository.ErrDuplicateName:
+		return http.StatusConflict
+	case err != nil:
+		return http.StatusBadRequest
+	case op == repository.BulkCreate:
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
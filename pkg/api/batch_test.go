@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchPlanets(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		statuses []int
+		planets  int
+	}{
+		{
+			name: "AllSucceed",
+			body: `{"operations": [
+				{"op": "create", "planet": {"name": "Hoth"}},
+				{"op": "update", "id": "1", "planet": {"name": "Tatooine", "climate": "hot"}},
+				{"op": "delete", "id": "1"}
+			]}`,
+			statuses: []int{http.StatusCreated, http.StatusOK, http.StatusOK},
+			planets:  1,
+		},
+		{
+			name: "OrderedStopsOnTheFirstFailure",
+			body: `{"operations": [
+				{"op": "delete", "id": "42"},
+				{"op": "create", "planet": {"name": "Hoth"}}
+			]}`,
+			statuses: []int{http.StatusBadRequest, http.StatusFailedDependency},
+			planets:  1,
+		},
+		{
+			name: "UnorderedRunsEveryOperation",
+			body: `{"ordered": false, "operations": [
+				{"op": "delete", "id": "42"},
+				{"op": "create", "planet": {"name": "Hoth"}}
+			]}`,
+			statuses: []int{http.StatusBadRequest, http.StatusCreated},
+			planets:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Tatooine"})
+			s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/planets:batch", strings.NewReader(tt.body)))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var response struct {
+				Results []batchResult `json:"results"`
+			}
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			statuses := []int{}
+			for _, result := range response.Results {
+				statuses = append(statuses, result.Status)
+				if result.Status >= http.StatusBadRequest {
+					assert.NotEmpty(t, result.Error)
+				}
+			}
+			assert.Equal(t, tt.statuses, statuses)
+			planets, _ := repo.FindAll(context.Background())
+			assert.Len(t, planets, tt.planets)
+		})
+	}
+}
+
+func TestBatchPlanetsRejectsInvalidBatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		body   string
+		status int
+	}{
+		{name: "InvalidJSON", body: `[`, status: http.StatusBadRequest},
+		{name: "Empty", body: `{"operations": []}`, status: http.StatusBadRequest},
+		{name: "UnknownOperation", body: `{"operations": [{"op": "upsert"}]}`, status: http.StatusBadRequest},
+		{name: "UpdateWithoutID", body: `{"operations": [{"op": "update", "planet": {"name": "Hoth"}}]}`, status: http.StatusBadRequest},
+		{
+			name:   "TooManyOperations",
+			body:   `{"operations": [` + strings.Repeat(`{"op": "create"},`, maxBatchOperations) + `{"op": "create"}]}`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "UnconditionalDeleteWhileIfMatchIsRequired",
+			cfg:    config.Config{RequireIfMatch: true},
+			body:   `{"operations": [{"op": "delete", "id": "1"}]}`,
+			status: http.StatusPreconditionRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Tatooine"})
+			tt.cfg.AllowInsecureNoAuth = true
+			s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: tt.cfg}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/planets:batch", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			planets, _ := repo.FindAll(context.Background())
+			assert.Len(t, planets, 1, "A rejected batch should change nothing")
+		})
+	}
+}
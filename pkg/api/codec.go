@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/csvcell"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/vmihailenco/msgpack/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// errNotRepresentable is returned by an encoder which can not write the value, like the CSV of a webhook
+var errNotRepresentable = errors.New("the response can not be written in this format")
+
+// errUnsupportedBody is returned by decodeBody when the Content-Type has no decoder
+var errUnsupportedBody = errors.New("the body Content-Type is not supported")
+
+// codec is the struct which describes a format of the bodies. JSON is the canonical format, the others are
+// converted from the JSON encoding so the member names, the omitted fields and the OpenAPI schemas are the same.
+type codec struct {
+	// contentType is sent on the responses, mediaTypes are the ones accepted on the Accept and Content-Type headers
+	contentType string
+	mediaTypes  []string
+	// encode writes the value, it returns errNotRepresentable when the format can not hold it
+	encode func(v interface{}) ([]byte, error)
+	// toJSON converts a request body to JSON, it is nil when the format is only used on the responses
+	toJSON func(body []byte) ([]byte, error)
+	// planetsOnly is set on the formats which can only hold the planets and the lists of planets
+	planetsOnly bool
+}
+
+// responseBody is the kind of body a route answers with, it tells negotiate which formats the route can produce
+type responseBody int
+
+const (
+	// otherBody is a body which every format but the planets only ones can hold, like the reports and the webhooks
+	otherBody responseBody = iota
+	// planetsBody is a planet or a list of planets, every format can hold it
+	planetsBody
+)
+
+// produces reports whether the route can answer in the format
+func (b responseBody) produces(c *codec) bool {
+	return b == planetsBody || !c.planetsOnly
+}
+
+// codecs is the registry of the formats, the first one is used when the client accepts any of them
+var codecs = []*codec{
+	{
+		contentType: "application/json",
+		mediaTypes:  []string{"application/json"},
+		encode:      json.Marshal,
+		toJSON:      func(body []byte) ([]byte, error) { return body, nil },
+	},
+	{
+		contentType: "application/yaml",
+		mediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		encode:      encodeYAML,
+		toJSON:      yamlToJSON,
+	},
+	{
+		contentType: "application/msgpack",
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		encode:      encodeMsgpack,
+		toJSON:      msgpackToJSON,
+	},
+	{
+		contentType: "text/csv; charset=utf-8",
+		mediaTypes:  []string{"text/csv"},
+		encode:      encodePlanetsCSV,
+		planetsOnly: true,
+	},
+}
+
+// codecOf returns the codec of the media type, nil when there is none
+func codecOf(mediaType string) *codec {
+	for _, c := range codecs {
+		for _, t := range c.mediaTypes {
+			if t == mediaType {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// acceptedCodecs returns the codecs the Accept header allows, the preferred first.
+// A missing Accept header accepts JSON, which was the only format before the negotiation.
+func acceptedCodecs(r *http.Request) []*codec {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return codecs[:1]
+	}
+	ranges := parseAccept(accept)
+
+	type candidate struct {
+		codec *codec
+		q     float64
+		// position is the index of the media range which matched, the earlier ranges win the ties
+		position int
+		order    int
+	}
+	var candidates []candidate
+	for order, c := range codecs {
+		best := candidate{codec: c, q: -1, order: order}
+		specificity := -1
+		for position, mr := range ranges {
+			s := mr.matches(c)
+			if s > specificity {
+				specificity = s
+				best.q = mr.q
+				best.position = position
+			}
+		}
+		if specificity >= 0 && best.q > 0 {
+			candidates = append(candidates, best)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].position < candidates[j].position
+	})
+	accepted := make([]*codec, len(candidates))
+	for i, c := range candidates {
+		accepted[i] = c.codec
+	}
+	return accepted
+}
+
+// mediaRange is an entry of the Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// matches returns how specific the range is for the codec: 2 for the media type, 1 for type/* and 0 for */*,
+// it is -1 when the range does not match
+func (mr mediaRange) matches(c *codec) int {
+	best := -1
+	for _, t := range c.mediaTypes {
+		switch {
+		case mr.mediaType == t:
+			return 2
+		case mr.mediaType == strings.SplitN(t, "/", 2)[0]+"/*":
+			best = 1
+		case mr.mediaType == "*/*" && best < 0:
+			best = 0
+		}
+	}
+	return best
+}
+
+// negotiate answers 406 before the handler runs when the client accepts none of the formats the route produces,
+// so a write is not made only to have its response refused
+func negotiate(b responseBody, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range acceptedCodecs(r) {
+			if b.produces(c) {
+				h(w, r)
+				return
+			}
+		}
+		notAcceptable(w, b)
+	}
+}
+
+func notAcceptable(w http.ResponseWriter, b responseBody) {
+	types := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		if b.produces(c) {
+			types = append(types, c.mediaTypes[0])
+		}
+	}
+	handleError(w, http.StatusNotAcceptable, "The response can be "+strings.Join(types, ", "))
+}
+
+// writeResponse writes the value in the preferred format the client accepts which can hold it, the error bodies are
+// always JSON. The route already negotiated a format which holds its body, so JSON is only the fallback of a body the
+// route did not declare, and a change which was already made is never answered 406.
+func writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+	candidates := append([]*codec{}, acceptedCodecs(r)...)
+	for _, c := range append(candidates, codecs[0]) {
+		response, err := c.encode(v)
+		if err == errNotRepresentable {
+			continue
+		}
+		if err != nil {
+			log.Println("Error Marshaling the response", err)
+			handleError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", c.contentType)
+		w.WriteHeader(statusCode)
+		if _, err := w.Write(response); err != nil {
+			log.Println("Error to write the response", err)
+		}
+		return
+	}
+}
+
+// decodeBody decodes the request body in the format of its Content-Type, JSON when it is absent
+func decodeBody(r *http.Request, v interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	c := codecs[0]
+	if mediaType != "" {
+		if c = codecOf(mediaType); c == nil || c.toJSON == nil {
+			return errUnsupportedBody
+		}
+	}
+	if c == codecs[0] {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	data, err := c.toJSON(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// handleDecodeError answers 415 for a body whose format is not supported, and 400 with the message otherwise
+func handleDecodeError(w http.ResponseWriter, err error, message string) {
+	if err == errUnsupportedBody {
+		handleError(w, http.StatusUnsupportedMediaType, "The body can be application/json, application/yaml or application/msgpack")
+		return
+	}
+	handleError(w, http.StatusBadRequest, message)
+}
+
+// encodeYAML writes the JSON encoding as a YAML document, keeping the member order
+func encodeYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// blockStyle drops the flow style of the JSON syntax, the scalars are quoted again only when they need it
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+func yamlToJSON(body []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// encodeMsgpack writes the JSON encoding as MessagePack, the integers stay integers and the map keys are sorted
+func encodeMsgpack(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf).SortMapKeys(true)
+	if err := encoder.Encode(msgpackValue(value)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// msgpackValue replaces the JSON numbers by int64, or float64 when they have a fraction
+func msgpackValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = msgpackValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = msgpackValue(value)
+		}
+	}
+	return v
+}
+
+func msgpackToJSON(body []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(bytes.NewReader(body))
+	v, err := decoder.DecodeInterfaceLoose()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.DecodeInterfaceLoose(); err != io.EOF {
+		return nil, errors.New("the MessagePack body has more than one value")
+	}
+	return json.Marshal(v)
+}
+
+// encodePlanetsCSV writes a planet or a list of planets as CSV with a header of their members,
+// the films are their titles separated by semicolons and the other values are not representable
+func encodePlanetsCSV(v interface{}) ([]byte, error) {
+	fields := planetFields
+	var planets []planet.Planet
+	switch v := v.(type) {
+	case planet.Planet:
+		planets = []planet.Planet{v}
+	case []planet.Planet:
+		planets = v
+	case sparsePlanet:
+		fields, planets = v.fields, []planet.Planet{v.planet}
+	case sparsePlanets:
+		fields, planets = v.fields, v.planets
+	default:
+		return nil, errNotRepresentable
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(fields)
+	for _, p := range planets {
+		record := make([]string, len(fields))
+		for i, field := range fields {
+			switch value := member(p, field).(type) {
+			case string:
+				record[i] = csvcell.Escape(value)
+			case int:
+				record[i] = strconv.Itoa(value)
+			case []planet.Film:
+				titles := make([]string, len(value))
+				for j, film := range value {
+					titles[j] = film.Title
+				}
+				record[i] = csvcell.Escape(strings.Join(titles, "; "))
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+	"gopkg.in/yaml.v3"
+)
+
+func newNegotiationServer(planets ...planet.Planet) (*Server, *repositoryMock) {
+	s, repo := newTestServer(config.Config{OpenAPIValidateRequests: true, OpenAPIValidateResponses: true}, planets...)
+	s.Webhooks = webhook.NewMemoryStore()
+	return s, repo
+}
+
+func TestAcceptedCodecs(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected []string
+	}{
+		{"", []string{"application/json"}},
+		{"application/json", []string{"application/json"}},
+		{"application/x-yaml", []string{"application/yaml"}},
+		{"text/csv, application/json;q=0.5", []string{"text/csv; charset=utf-8", "application/json"}},
+		{"application/yaml, application/msgpack", []string{"application/yaml", "application/msgpack"}},
+		{"application/*;q=0.8, application/msgpack", []string{"application/msgpack", "application/json", "application/yaml"}},
+		{"*/*", []string{"application/json", "application/yaml", "application/msgpack", "text/csv; charset=utf-8"}},
+		{"*/*, application/json;q=0", []string{"application/yaml", "application/msgpack", "text/csv; charset=utf-8"}},
+		{"text/html", []string{}},
+		{"application/xml, text/*;q=0.1", []string{"application/yaml", "text/csv; charset=utf-8"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/planets", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		accepted := []string{}
+		for _, c := range acceptedCodecs(r) {
+			accepted = append(accepted, c.contentType)
+		}
+		assert.Equal(t, tt.expected, accepted, tt.accept)
+	}
+}
+
+func TestResponsesFollowTheAcceptHeader(t *testing.T) {
+	s, _ := newNegotiationServer(planet.Planet{ID: "1", Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/planets/1", nil)
+		r.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := get("application/yaml")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+	assert.Equal(t, "id: \"1\"\nname: Tatooine\nclimate: arid\nterrain: desert\nnumberOfAppearancesOnMovies: 5\n", rec.Body.String())
+
+	rec = get("application/msgpack")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/msgpack", rec.Header().Get("Content-Type"))
+	var decoded map[string]interface{}
+	assert.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &decoded))
+	assert.Equal(t, "Tatooine", decoded["name"])
+	assert.EqualValues(t, 5, decoded["numberOfAppearancesOnMovies"], "The integers should stay integers")
+
+	rec = get("text/csv")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,climate,terrain,numberOfAppearancesOnMovies\n1,Tatooine,arid,desert,5\n", rec.Body.String())
+
+	rec = get("application/xml")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), "The errors should be JSON")
+}
+
+func TestTheListCanBeCSV(t *testing.T) {
+	s, _ := newNegotiationServer(planet.Planet{ID: "1", Name: "Tatooine"}, planet.Planet{ID: "2", Name: "Hoth", Climate: "frozen"})
+	r := httptest.NewRequest(http.MethodGet, "/planets?limit=10", nil)
+	r.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "id,name,climate,terrain,numberOfAppearancesOnMovies\n1,Tatooine,,,5\n2,Hoth,frozen,,0\n", rec.Body.String())
+}
+
+func TestTheCSVEscapesTheFormulas(t *testing.T) {
+	s, _ := newNegotiationServer(planet.Planet{ID: "1", Name: "=cmd|' /C calc'!A0", Climate: "+arid", Terrain: "@desert"})
+	r := httptest.NewRequest(http.MethodGet, "/planets/1", nil)
+	r.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "id,name,climate,terrain,numberOfAppearancesOnMovies\n1,'=cmd|' /C calc'!A0,'+arid,'@desert,0\n", rec.Body.String())
+}
+
+func TestTheFormatWhichCanNotHoldTheResponseIsSkipped(t *testing.T) {
+	s, _ := newNegotiationServer()
+	r := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	r.Header.Set("Accept", "text/csv, application/yaml;q=0.5")
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+
+	r = httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	r.Header.Set("Accept", "text/csv")
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+func TestNotAcceptableIsAnsweredBeforeTheWrite(t *testing.T) {
+	s, repo := newNegotiationServer()
+	r := httptest.NewRequest(http.MethodPost, "/planets", strings.NewReader(`{"name": "Hoth"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/xml")
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Empty(t, repo.planets)
+}
+
+func TestTheRoutesRefuseTheFormatsTheyCanNotProduceBeforeTheWrite(t *testing.T) {
+	s, repo := newNegotiationServer()
+	post := func(target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept", "text/csv")
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := post("/planets:batch", `{"operations": [{"op": "create", "planet": {"name": "Hoth"}}]}`)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "text/csv", "The error should list the formats the route produces")
+	assert.Empty(t, repo.planets, "The batch should not run")
+
+	rec = post("/webhooks", `{"url": "https://example.com/hook", "events": ["planet.created"]}`)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code, rec.Body.String())
+	subs, err := s.Webhooks.ListSubscriptions(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, subs, "The webhook and its secret should not be created")
+
+	rec = post("/planets", `{"name": "Hoth"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestTheResponseFallsBackToJSONAfterTheHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/planets:batch", nil)
+	r.Header.Set("Accept", "text/csv")
+
+	writeResponse(rec, r, http.StatusOK, map[string]string{"status": "done"})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status": "done"}`, rec.Body.String())
+}
+
+func TestRequestBodiesFollowTheContentType(t *testing.T) {
+	yamlBody, _ := yaml.Marshal(map[string]string{"name": "Hoth", "climate": "frozen"})
+	msgpackBody, _ := msgpack.Marshal(map[string]string{"name": "Dagobah", "climate": "murky"})
+	tests := []struct {
+		contentType string
+		body        []byte
+		name        string
+	}{
+		{"application/yaml", yamlBody, "Hoth"},
+		{"application/x-yaml; charset=utf-8", yamlBody, "Hoth"},
+		{"application/msgpack", msgpackBody, "Dagobah"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			for _, validate := range []bool{true, false} {
+				s, repo := newNegotiationServer()
+				s.Cfg.OpenAPIValidateRequests = validate
+				r := httptest.NewRequest(http.MethodPost, "/planets", bytes.NewReader(tt.body))
+				r.Header.Set("Content-Type", tt.contentType)
+				rec := httptest.NewRecorder()
+				s.handler().ServeHTTP(rec, r)
+
+				assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+				var created planet.Planet
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+				assert.Equal(t, tt.name, created.Name)
+				assert.Len(t, repo.planets, 1)
+			}
+		})
+	}
+}
+
+func TestRequestBodiesWhichCanNotBeDecoded(t *testing.T) {
+	for _, validate := range []bool{true, false} {
+		s, _ := newNegotiationServer()
+		s.Cfg.OpenAPIValidateRequests = validate
+
+		r := httptest.NewRequest(http.MethodPost, "/planets", strings.NewReader("id,name\n1,Hoth\n"))
+		r.Header.Set("Content-Type", "text/csv")
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, r)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code, "The CSV should only be a response")
+
+		r = httptest.NewRequest(http.MethodPost, "/planets", strings.NewReader("name: [Hoth"))
+		r.Header.Set("Content-Type", "application/yaml")
+		rec = httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, r)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
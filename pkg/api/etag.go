@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+// etag formats the planet version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// notModified reports whether the If-None-Match header already matches the version, weak tags match too
+func notModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion reads the version of the planet id expected by the If-Match header, zero means any version.
+// A single tag is checked by the repository with the change. "*" and a list of tags are checked here against
+// the current planet, which must exist, and the matching version is returned so the change still fails if the
+// planet changes meanwhile. The tags are compared strongly, so the weak tags are parsed but never match.
+// It writes 428 when the header is required and missing, and 412 when the header does not match.
+func (s *Server) ifMatchVersion(w http.ResponseWriter, r *http.Request, id string) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if s.Cfg.RequireIfMatch {
+			handleError(w, http.StatusPreconditionRequired, "The If-Match header with the planet ETag is required")
+			return 0, false
+		}
+		return 0, true
+	}
+	anyVersion, versions := parseIfMatch(header)
+	if !anyVersion && len(versions) == 1 {
+		return versions[0], true
+	}
+	if !anyVersion && len(versions) == 0 {
+		handleError(w, http.StatusPreconditionFailed, "The If-Match header does not match the planet ETag")
+		return 0, false
+	}
+
+	ctx := r.Context()
+	current, err := s.PlanetRepository.FindByID(ctx, id, repository.FieldName)
+	if err == repository.ErrNotFound {
+		handleError(w, http.StatusPreconditionFailed, "The If-Match header does not match a current planet")
+		return 0, false
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the planet to check the If-Match header", err)
+		return 0, false
+	}
+	if anyVersion {
+		return current.Version, true
+	}
+	for _, version := range versions {
+		if version == current.Version {
+			return version, true
+		}
+	}
+	handleError(w, http.StatusPreconditionFailed, repository.ErrVersionMismatch.Error())
+	return 0, false
+}
+
+// parseIfMatch reads the "*" or the list of strong planet tags of an If-Match header,
+// the weak and the malformed tags are left out since they can not match strongly
+func parseIfMatch(header string) (anyVersion bool, versions []int64) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true, nil
+		}
+		version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err == nil && version > 0 && tag == etag(version) {
+			versions = append(versions, version)
+		}
+	}
+	return false, versions
+}
+
+// handleVersionError writes the status of the errors caused by the conditional changes and by the repeated names,
+// it reports whether err was one of them
+func handleVersionError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case repository.ErrVersionMismatch:
+		handleError(w, http.StatusPreconditionFailed, err.Error())
+	case repository.ErrNotFound:
+		handleError(w, http.StatusNotFound, err.Error())
+	case repository.ErrDuplicateName:
+		handleError(w, http.StatusConflict, err.Error())
+	default:
+		return false
+	}
+	return true
+}
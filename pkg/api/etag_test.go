@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"}),
+		CountRetriever:   staticCounterMock{"Hoth": 1},
+		Cfg:              config.Config{AllowInsecureNoAuth: true},
+	}
+	h := s.handler()
+	do := func(method, ifMatch, ifNoneMatch, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/planets/1", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "", "", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodGet, "", `W/"1"`, "", "")
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = do(http.MethodPut, `"1"`, "", "", `{"name": "Hoth", "climate": "cold", "terrain": "tundra"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodPut, `"1"`, "", "", `{"name": "Hoth", "climate": "hot", "terrain": "tundra"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, "A stale ETag must not overwrite the newer version")
+
+	rec = do(http.MethodPatch, `"1"`, "", mergePatchContentType, `{"climate": "hot"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = do(http.MethodPatch, `"1"`, "", jsonPatchContentType, `[{"op": "replace", "path": "/climate", "value": "hot"}]`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = do(http.MethodPatch, `"2"`, "", mergePatchContentType, `{"climate": "hot"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodDelete, "invalid", "", "", "")
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = do(http.MethodDelete, `"2"`, "", "", "")
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = do(http.MethodDelete, `"3"`, "", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = do(http.MethodDelete, `"3"`, "", "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Hoth"}),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, RequireIfMatch: true},
+	}
+	h := s.handler()
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/planets/1", strings.NewReader(`{"name": "Hoth"}`))
+		req.Header.Set("Content-Type", mergePatchContentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code, method)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/planets/1", nil)
+	req.Header.Set("If-Match", "*")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestIfMatchAnyRequiresACurrentPlanet(t *testing.T) {
+	repo := newRepositoryMock(planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+	s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	h := s.handler()
+	do := func(method, target, contentType, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("If-Match", "*")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodPut, "/planets/9", "application/json", `{"name": "Endor"}`))
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodPatch, "/planets/9", mergePatchContentType, `{"climate": "hot"}`))
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/planets/9", "", ""))
+	_, err := repo.FindByID(context.Background(), "9")
+	assert.Equal(t, repository.ErrNotFound, err, "The PUT must not create the planet")
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/planets/1", "application/json", `{"name": "Hoth", "climate": "cold"}`))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/planets/1", "", ""))
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/planets/1", "", ""), "A deleted planet is not current")
+}
+
+func TestIfMatchList(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"}),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true},
+	}
+	h := s.handler()
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/planets/1", strings.NewReader(`{"name": "Hoth", "climate": "cold", "terrain": "tundra"}`))
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := put(`"3", "1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusPreconditionFailed, put(`"1", "3"`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, put(`W/"2"`).Code, "The weak tags do not match strongly")
+
+	rec = put(`W/"2", "2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+}
+
+func TestCreateReturnsTheETag(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+
+	rec := doRequest(s.handler(), http.MethodPost, "/planets", `{"name": "Hoth"}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+)
+
+// typeReset is sent when the missed events are gone, the client must reload the planets
+const typeReset = "reset"
+
+const defaultHeartbeat = 15 * time.Second
+
+// planetEventsHandler streams the planet events as Server-Sent Events, a reconnection with Last-Event-ID
+// gets the events it missed first
+func (s *Server) planetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Events == nil {
+		handleError(w, http.StatusNotFound, "The planet events are not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, http.StatusInternalServerError, "Streaming is not supported by the connection")
+		return
+	}
+	sub := s.Events.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if sub.Reset {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", typeReset)
+	}
+	for _, e := range sub.Replay {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := s.Cfg.EventsHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, open := <-sub.Events:
+			if !open {
+				// the subscriber fell behind, the client reconnects with its Last-Event-ID
+				return
+			}
+			writeEvent(w, e)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, e event.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Println("Error Marshaling the event", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvent reads the next event of the stream, skipping the retry and comment lines
+func readEvent(t *testing.T, r *bufio.Reader) (id, eventType string, data map[string]interface{}) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data))
+		case line == "" && eventType != "":
+			return id, eventType, data
+		}
+	}
+}
+
+func TestPlanetEvents(t *testing.T) {
+	bus := event.NewBus(10)
+	repo := event.NewRepository(newRepositoryMock(), bus)
+	s := Server{
+		PlanetRepository: repo,
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, ReadHandlerTimeout: 10 * time.Millisecond, EventsHeartbeat: 5 * time.Millisecond},
+		Events:           bus,
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	subscribe := func(lastEventID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/planets/events", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body), func() { cancel(); resp.Body.Close() }
+	}
+
+	stream, closeStream := subscribe("")
+	// the stream must outlive the read handler timeout
+	time.Sleep(20 * time.Millisecond)
+	created, _ := repo.Create(context.Background(), planet.Planet{Name: "Hoth"})
+	repo.Delete(context.Background(), created.ID, 0)
+
+	firstID, eventType, data := readEvent(t, stream)
+	assert.Equal(t, event.TypeCreated, eventType)
+	assert.Equal(t, created.ID, data["planetId"])
+	assert.Equal(t, "Hoth", data["planet"].(map[string]interface{})["name"])
+	_, eventType, data = readEvent(t, stream)
+	assert.Equal(t, event.TypeDeleted, eventType)
+	assert.Nil(t, data["planet"])
+	closeStream()
+
+	resumed, closeResumed := subscribe(firstID)
+	defer closeResumed()
+	_, eventType, _ = readEvent(t, resumed)
+	assert.Equal(t, event.TypeDeleted, eventType, "The events after Last-Event-ID should be replayed")
+
+	reset, closeReset := subscribe("unknown-1")
+	defer closeReset()
+	_, eventType, _ = readEvent(t, reset)
+	assert.Equal(t, typeReset, eventType)
+}
+
+func TestPlanetEventsWithoutBus(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets/events", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
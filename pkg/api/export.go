@@ -0,0 +1,106 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelreinert/stars/pkg/planet/exporter"
+	"github.com/rafaelreinert/stars/pkg/planet/retriever"
+)
+
+// exportContentTypes is the Content-Type of each export format
+var exportContentTypes = map[string]string{
+	exporter.FormatNDJSON: "application/x-ndjson",
+	exporter.FormatCSV:    "text/csv; charset=utf-8",
+}
+
+// exportPlanetsHandler streams every planet from the repository cursor, the SWAPI counts are only fetched with counts=true.
+// The body is compressed when the client accepts gzip. Once the body started a failure can only cut it,
+// so the NDJSON and CSV readers see a truncated last line.
+func (s *Server) exportPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = exporter.FormatNDJSON
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		handleError(w, http.StatusBadRequest, "The export format must be ndjson or csv")
+		return
+	}
+	var counter retriever.PlanetAppearancesOnMoviesCounter
+	if value := query.Get("counts"); value != "" {
+		counts, err := strconv.ParseBool(value)
+		if err != nil {
+			handleError(w, http.StatusBadRequest, "The counts must be true or false")
+			return
+		}
+		if counts {
+			counter = s.CountRetriever
+		}
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="planets.`+format+`"`)
+	body := &startedWriter{w: w}
+	var out io.Writer = body
+	var compressed *gzip.Writer
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		compressed = gzip.NewWriter(body)
+		out = compressed
+	}
+
+	planetWriter, _ := exporter.NewWriter(format, out, counter != nil)
+	n, err := exporter.Export(ctx, s.PlanetRepository, counter, planetWriter)
+	if err == nil && compressed != nil {
+		err = compressed.Close()
+	}
+	if err != nil && !body.started {
+		w.Header().Del("Content-Encoding")
+		w.Header().Del("Content-Disposition")
+		handleContextError(ctx, w, http.StatusBadRequest, "Error exporting the planets", err)
+		return
+	}
+	if err != nil {
+		log.Println("Error exporting the planets, the response was cut after", n, "planets", err)
+	}
+}
+
+// startedWriter tells whether the body started, after that the status can not be changed
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
+
+// acceptsGzip reports whether the Accept-Encoding of the request allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			params := strings.Split(coding, ";")
+			if strings.TrimSpace(params[0]) != "gzip" {
+				continue
+			}
+			for _, param := range params[1:] {
+				if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+					weight, err := strconv.ParseFloat(strings.TrimPrefix(q, "q="), 64)
+					return err == nil && weight > 0
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportPlanets(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "NDJSONByDefault",
+			target:      "/planets:export",
+			contentType: "application/x-ndjson",
+			body: `{"id":"1","name":"Tatooine","climate":"arid","terrain":"desert","version":1}` + "\n" +
+				`{"id":"2","name":"Hoth","climate":"frozen","terrain":"tundra","version":1}` + "\n",
+		},
+		{
+			name:        "NDJSONWithCounts",
+			target:      "/planets:export?format=ndjson&counts=true",
+			contentType: "application/x-ndjson",
+			body: `{"id":"1","name":"Tatooine","climate":"arid","terrain":"desert","version":1,"numberOfAppearancesOnMovies":5}` + "\n" +
+				`{"id":"2","name":"Hoth","climate":"frozen","terrain":"tundra","version":1,"numberOfAppearancesOnMovies":0}` + "\n",
+		},
+		{
+			name:        "CSV",
+			target:      "/planets:export?format=csv",
+			contentType: "text/csv; charset=utf-8",
+			body:        "id,name,climate,terrain,version\n1,Tatooine,arid,desert,1\n2,Hoth,frozen,tundra,1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"}, planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+			s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{"Tatooine": 5}, Cfg: config.Config{AllowInsecureNoAuth: true, OpenAPIValidateResponses: true}}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestExportPlanetsCompressesWithGzip(t *testing.T) {
+	repo := newRepositoryMock(planet.Planet{Name: "Tatooine"})
+	s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	req := httptest.NewRequest(http.MethodGet, "/planets:export?format=csv", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Contains(t, rec.Header().Get("Vary"), "Accept-Encoding")
+	reader, err := gzip.NewReader(rec.Body)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "id,name,climate,terrain,version\n1,Tatooine,,,1\n", string(body))
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for value, accepts := range map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip":     true,
+		"gzip;q=0":          false,
+		"gzip; q=0.5, br":   true,
+		"br, identity;q=0":  false,
+		"x-gzip, deflate":   false,
+		"gzip;level=1;q=1":  true,
+		"gzip;q=not-number": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/planets:export", nil)
+		r.Header.Set("Accept-Encoding", value)
+		assert.Equal(t, accepts, acceptsGzip(r), value)
+	}
+}
+
+// failingEachRepository fails the cursor before the first planet
+type failingEachRepository struct {
+	*repositoryMock
+}
+
+func (r failingEachRepository) FindEach(ctx context.Context, fn func(planet.Planet) error) error {
+	return errors.New("cursor failed")
+}
+
+func TestExportPlanetsReportsAFailureBeforeTheBody(t *testing.T) {
+	s := Server{PlanetRepository: failingEachRepository{newRepositoryMock()}, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	req := httptest.NewRequest(http.MethodGet, "/planets:export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"error": "cursor failed"}`, rec.Body.String())
+}
+
+func TestExportPlanetsRejectsInvalidParameters(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	for _, target := range []string{"/planets:export?format=xml", "/planets:export?counts=maybe"} {
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/retriever"
+)
+
+// The members of a planet response
+const (
+	fieldID     = "id"
+	fieldCount  = "numberOfAppearancesOnMovies"
+	fieldFilms  = "films"
+	expandFilms = "films"
+)
+
+// planetFields are the members of the planet JSON in their order, the ones the fields query parameter may select
+var planetFields = []string{fieldID, repository.FieldName, repository.FieldClimate, repository.FieldTerrain, fieldCount}
+
+// planetView is the struct which carries the members asked with the fields and expand query parameters
+type planetView struct {
+	// fields are the members of the response in the planet JSON order, nil is the whole planet
+	fields []string
+	// films is set by expand=films, which adds the films the planet appears on
+	films bool
+}
+
+// viewOf reads the fields and expand query parameters, the planet is whole when neither is given
+func viewOf(r *http.Request) (planetView, error) {
+	var view planetView
+	query := r.URL.Query()
+	if expand := query.Get("expand"); expand != "" {
+		for _, value := range strings.Split(expand, ",") {
+			if strings.TrimSpace(value) != expandFilms {
+				return view, errors.Errorf("The expand %q is not supported, use films", value)
+			}
+			view.films = true
+		}
+	}
+	if value := query.Get("fields"); value != "" {
+		asked := map[string]bool{}
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if !contains(planetFields, field) {
+				return view, errors.Errorf("The field %q is not a planet field, use %s", field, strings.Join(planetFields, ", "))
+			}
+			asked[field] = true
+		}
+		for _, field := range planetFields {
+			if asked[field] {
+				view.fields = append(view.fields, field)
+			}
+		}
+	}
+	if view.films {
+		if view.fields == nil {
+			view.fields = append([]string{}, planetFields...)
+		}
+		view.fields = append(view.fields, fieldFilms)
+	}
+	return view, nil
+}
+
+// whole reports whether the view is the planet JSON
+func (v planetView) whole() bool {
+	return v.fields == nil
+}
+
+// counts reports whether the number of appearances on movies is asked, which is a SWAPI lookup
+func (v planetView) counts() bool {
+	return v.whole() || contains(v.fields, fieldCount)
+}
+
+// repositoryFields are the fields read from the repository, the name is read for the SWAPI lookups
+func (v planetView) repositoryFields() []string {
+	if v.whole() {
+		return nil
+	}
+	var fields []string
+	for _, field := range []string{repository.FieldName, repository.FieldClimate, repository.FieldTerrain} {
+		if contains(v.fields, field) || (field == repository.FieldName && (v.counts() || v.films)) {
+			fields = append(fields, field)
+		}
+	}
+	if fields == nil {
+		// the id is always read, the name stands for the empty projection which would read every field
+		fields = []string{repository.FieldName}
+	}
+	return fields
+}
+
+// fillAll fills the SWAPI data the view asks for, a planet whose lookup fails is kept without it
+func (s *Server) fillAll(ctx context.Context, planets []planet.Planet, view planetView) ([]planet.Planet, error) {
+	switch {
+	case view.films:
+		return retriever.FillAllFilms(ctx, planets, s.FilmsLister)
+	case view.counts():
+		return retriever.FillAllNumberOfAppearancesOnMovies(ctx, planets, s.CountRetriever)
+	}
+	return planets, nil
+}
+
+// fill fills the SWAPI data the view asks for
+func (s *Server) fill(ctx context.Context, p planet.Planet, view planetView) (planet.Planet, error) {
+	switch {
+	case view.films:
+		return retriever.FillFilms(ctx, p, s.FilmsLister)
+	case view.counts():
+		return retriever.FillNumberOfAppearancesOnMovies(ctx, p, s.CountRetriever)
+	}
+	return p, nil
+}
+
+// checkView writes the 400 of a view which can not be served, ok is false then
+func (s *Server) checkView(w http.ResponseWriter, r *http.Request) (view planetView, ok bool) {
+	view, err := viewOf(r)
+	if err == nil && view.films && s.FilmsLister == nil {
+		err = errors.New("The films expansion is not enabled")
+	}
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+		return view, false
+	}
+	return view, true
+}
+
+// render is the response of a planet, or a list of planets, on the view
+func (v planetView) render(planetOrPlanets interface{}) interface{} {
+	if v.whole() {
+		return planetOrPlanets
+	}
+	if planets, ok := planetOrPlanets.([]planet.Planet); ok {
+		return sparsePlanets{fields: v.fields, planets: planets}
+	}
+	return sparsePlanet{fields: v.fields, planet: planetOrPlanets.(planet.Planet)}
+}
+
+// sparsePlanet is a planet with only some members, in the planet JSON order
+type sparsePlanet struct {
+	fields []string
+	planet planet.Planet
+}
+
+func (p sparsePlanet) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range p.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(member(p.planet, field))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(field))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// sparsePlanets is a list of planets with only some members
+type sparsePlanets struct {
+	fields  []string
+	planets []planet.Planet
+}
+
+func (l sparsePlanets) MarshalJSON() ([]byte, error) {
+	sparse := make([]sparsePlanet, len(l.planets))
+	for i, p := range l.planets {
+		sparse[i] = sparsePlanet{fields: l.fields, planet: p}
+	}
+	return json.Marshal(sparse)
+}
+
+// member is the value of the member of the planet response
+func member(p planet.Planet, field string) interface{} {
+	switch field {
+	case fieldID:
+		return p.ID
+	case repository.FieldName:
+		return p.Name
+	case repository.FieldClimate:
+		return p.Climate
+	case repository.FieldTerrain:
+		return p.Terrain
+	case fieldCount:
+		return p.NumberOfAppearancesOnMovies
+	case fieldFilms:
+		if p.Films == nil {
+			return []planet.Film{}
+		}
+		return p.Films
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCounterMock counts the SWAPI lookups
+type countingCounterMock struct {
+	calls *int32
+}
+
+func (c countingCounterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	atomic.AddInt32(c.calls, 1)
+	return len(name), nil
+}
+
+type filmsListerMock map[string][]planet.Film
+
+func (l filmsListerMock) ListPlanetFilms(ctx context.Context, name string) ([]planet.Film, error) {
+	return l[name], nil
+}
+
+func newFieldsServer() (*Server, *repositoryMock, *int32) {
+	var calls int32
+	s, repo := newTestServer(config.Config{OpenAPIValidateRequests: true, OpenAPIValidateResponses: true},
+		planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+		planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"},
+	)
+	s.CountRetriever = countingCounterMock{calls: &calls}
+	s.FilmsLister = filmsListerMock{"Tatooine": {
+		{Title: "A New Hope", EpisodeID: 4, Director: "George Lucas", ReleaseDate: "1977-05-25"},
+		{Title: "Return of the Jedi", EpisodeID: 6, Director: "Richard Marquand", ReleaseDate: "1983-05-25"},
+	}}
+	return s, repo, &calls
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	tests := []struct {
+		target     string
+		body       string
+		repoFields []string
+		lookups    int32
+	}{
+		{"/planets?limit=10&fields=id,name", `[{"id":"1","name":"Tatooine"},{"id":"2","name":"Hoth"}]`, []string{"name"}, 0},
+		{"/planets?limit=10&fields=id", `[{"id":"1"},{"id":"2"}]`, []string{"name"}, 0},
+		{"/planets?limit=10&fields=numberOfAppearancesOnMovies,climate", `[{"climate":"arid","numberOfAppearancesOnMovies":8},{"climate":"frozen","numberOfAppearancesOnMovies":4}]`, []string{"name", "climate"}, 2},
+		{"/planets?limit=1&fields=terrain", `[{"terrain":"desert"}]`, []string{"terrain"}, 0},
+		{"/planets?name=Hoth&fields=name,terrain", `{"name":"Hoth","terrain":"tundra"}`, []string{"name", "terrain"}, 0},
+		{"/planets/1?fields=climate", `{"climate":"arid"}`, []string{"climate"}, 0},
+		{"/planets/2", `{"id":"2","name":"Hoth","climate":"frozen","terrain":"tundra","numberOfAppearancesOnMovies":4}`, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			s, repo, calls := newFieldsServer()
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.JSONEq(t, tt.body, rec.Body.String())
+			assert.Equal(t, tt.repoFields, repo.fields, "The projection should be asked to the repository")
+			assert.Equal(t, tt.lookups, atomic.LoadInt32(calls), "SWAPI should only be asked for the count")
+		})
+	}
+}
+
+func TestSparseFieldsetsKeepTheNextLink(t *testing.T) {
+	s, _, _ := newFieldsServer()
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets?limit=1&fields=id,name", nil))
+
+	assert.Equal(t, `</planets?after=1&fields=id%2Cname&limit=1>; rel="next"`, rec.Header().Get("Link"))
+}
+
+func TestExpandFilms(t *testing.T) {
+	s, _, calls := newFieldsServer()
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets/1?expand=films", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"id":"1","name":"Tatooine","climate":"arid","terrain":"desert","numberOfAppearancesOnMovies":2,"films":[
+		{"title":"A New Hope","episodeId":4,"director":"George Lucas","releaseDate":"1977-05-25"},
+		{"title":"Return of the Jedi","episodeId":6,"director":"Richard Marquand","releaseDate":"1983-05-25"}
+	]}`, rec.Body.String())
+	assert.Equal(t, int32(0), atomic.LoadInt32(calls), "The count should come from the films")
+
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets?limit=10&fields=name&expand=films", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `[{"name":"Tatooine","films":[
+		{"title":"A New Hope","episodeId":4,"director":"George Lucas","releaseDate":"1977-05-25"},
+		{"title":"Return of the Jedi","episodeId":6,"director":"Richard Marquand","releaseDate":"1983-05-25"}
+	]},{"name":"Hoth","films":[]}]`, rec.Body.String())
+
+	r := httptest.NewRequest(http.MethodGet, "/planets?limit=10&fields=name&expand=films", nil)
+	r.Header.Set("Accept", "text/csv")
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, r)
+	assert.Equal(t, "name,films\nTatooine,A New Hope; Return of the Jedi\nHoth,\n", rec.Body.String())
+}
+
+func TestInvalidFieldsAndExpansions(t *testing.T) {
+	for _, target := range []string{
+		"/planets?limit=10&fields=id,version",
+		"/planets/1?fields=films",
+		"/planets?expand=residents",
+	} {
+		s, _, _ := newFieldsServer()
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+
+	s, _, _ := newFieldsServer()
+	s.FilmsLister = nil
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets?expand=films", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not enabled")
+}
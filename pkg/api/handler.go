@@ -9,169 +9,209 @@ import (
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/rafaelreinert/stars/pkg/auth"
 	"github.com/rafaelreinert/stars/pkg/planet"
-	"github.com/rafaelreinert/stars/pkg/planet/retriever"
+	"github.com/rafaelreinert/stars/pkg/requestid"
 )
 
+const defaultTimeout = 20 * time.Second
+
 func (s *Server) handler() http.Handler {
-	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "X-Session-Token"})
-	originsOk := handlers.AllowedOrigins([]string{"*"})
-	credentialsOk := handlers.AllowCredentials()
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "DELETE", "PUT", "OPTIONS"})
+	s.readLimiter = newLimiter(s.Cfg.RateLimitReadRPS, s.Cfg.RateLimitReadBurst)
+	s.writeLimiter = newLimiter(s.Cfg.RateLimitWriteRPS, s.Cfg.RateLimitWriteBurst)
+	s.authFailureLimiter = newLimiter(s.Cfg.RateLimitAuthFailureRPS, s.Cfg.RateLimitAuthFailureBurst)
+
+	return s.cors(requestid.Middleware(s.validateOpenAPI(s.router())))
+}
 
+// router registers the routes, every route must be described on the OpenAPI document
+func (s *Server) router() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/planets", s.getPlanetByNameHandler).Methods("GET").Queries("name", "")
-	r.HandleFunc("/planets", s.listPlanetHandler).Methods("GET")
-	r.HandleFunc("/planets", s.createPlanetHandler).Methods("POST")
-	r.HandleFunc("/planets/{id}", s.getPlanetHandler).Methods("GET")
-	r.HandleFunc("/planets/{id}", s.updatePlanetHandler).Methods("PUT")
-	r.HandleFunc("/planets/{id}", s.deletePlanetHandler).Methods("DELETE")
+	r.HandleFunc("/planets", s.read(planetsBody, s.getPlanetByNameHandler)).Methods("GET").Queries("name", "")
+	r.HandleFunc("/planets", s.read(planetsBody, s.listPlanetHandler)).Methods("GET")
+	r.HandleFunc("/planets/search", s.read(otherBody, s.searchPlanetsHandler)).Methods("GET")
+	r.HandleFunc("/planets", s.write(planetsBody, s.idempotent(s.createPlanetHandler))).Methods("POST")
+	r.HandleFunc("/planets:batch", s.write(otherBody, s.idempotent(s.batchPlanetsHandler))).Methods("POST")
+	r.HandleFunc("/planets:export", s.stream(s.exportPlanetsHandler)).Methods("GET")
+	r.HandleFunc("/planets:import", s.bulk(auth.ScopePlanetsWrite, s.importPlanetsHandler)).Methods("POST")
+	r.HandleFunc("/planets:sync", s.bulk(auth.ScopePlanetsAdmin, s.syncPlanetsHandler)).Methods("POST")
+	r.HandleFunc("/planets/events", s.stream(s.planetEventsHandler)).Methods("GET")
+	r.HandleFunc("/planets:deleted", s.admin(planetsBody, s.listDeletedPlanetsHandler)).Methods("GET")
+	r.HandleFunc("/planets/{id}:restore", s.write(planetsBody, s.restorePlanetHandler)).Methods("POST")
+	r.HandleFunc("/planets/{id}/history", s.admin(otherBody, s.planetHistoryHandler)).Methods("GET")
+	r.HandleFunc("/planets/{id}", s.read(planetsBody, s.getPlanetHandler)).Methods("GET")
+	r.HandleFunc("/planets/{id}", s.write(planetsBody, s.updatePlanetHandler)).Methods("PUT")
+	r.HandleFunc("/planets/{id}", s.write(planetsBody, s.patchPlanetHandler)).Methods("PATCH")
+	r.HandleFunc("/planets/{id}", s.write(planetsBody, s.deletePlanetHandler)).Methods("DELETE")
+	r.HandleFunc("/webhooks", s.admin(otherBody, s.listWebhooksHandler)).Methods("GET")
+	r.HandleFunc("/webhooks", s.admin(otherBody, s.createWebhookHandler)).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", s.admin(otherBody, s.getWebhookHandler)).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", s.admin(otherBody, s.updateWebhookHandler)).Methods("PUT")
+	r.HandleFunc("/webhooks/{id}", s.admin(otherBody, s.deleteWebhookHandler)).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", s.admin(otherBody, s.listDeliveriesHandler)).Methods("GET")
+	r.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}:retry", s.admin(otherBody, s.retryDeliveryHandler)).Methods("POST")
+	r.HandleFunc("/openapi.json", s.openAPIHandler).Methods("GET")
+	r.HandleFunc("/docs", s.docsHandler).Methods("GET")
+	return r
+}
 
-	return handlers.CORS(headersOk, originsOk, methodsOk, credentialsOk)(r)
+// cors applies the configured CORS policy, the API is not exposed to browsers on other origins when no origin is allowed
+func (s *Server) cors(h http.Handler) http.Handler {
+	if len(s.Cfg.CORSAllowedOrigins) == 0 {
+		return h
+	}
+	options := []handlers.CORSOption{
+		handlers.AllowedOrigins(s.Cfg.CORSAllowedOrigins),
+		handlers.AllowedMethods(s.Cfg.CORSAllowedMethods),
+		handlers.AllowedHeaders(s.Cfg.CORSAllowedHeaders),
+		handlers.ExposedHeaders(s.Cfg.CORSExposedHeaders),
+		handlers.MaxAge(s.Cfg.CORSMaxAge),
+	}
+	if s.Cfg.CORSAllowCredentials {
+		options = append(options, handlers.AllowCredentials())
+	}
+	return handlers.CORS(options...)(h)
 }
 
 func (s *Server) listPlanetHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
-	planets, err := retriever.RetriveAllPlanets(ctx, s.CountRetriever, s.PlanetRepository)
+	ctx := r.Context()
+	page, paged, err := pageOf(r)
 	if err != nil {
-		log.Println("Error retriving all planets", err)
 		handleError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	response, err := json.Marshal(planets)
-	if err != nil {
-		log.Println("Error Marshaling the result planets", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
+	view, ok := s.checkView(w, r)
+	if !ok {
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(response)
+	if paged {
+		s.listPlanetPageHandler(w, r, page, view)
+		return
+	}
+	planets, err := s.PlanetRepository.FindAll(ctx, view.repositoryFields()...)
+	if err == nil {
+		planets, err = s.fillAll(ctx, planets, view)
+	}
 	if err != nil {
-		log.Println("Error to write the response", err)
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving all planets", err)
+		return
 	}
+
+	writeResponse(w, r, http.StatusOK, view.render(planets))
 }
 
 func (s *Server) createPlanetHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx := r.Context()
 
 	var newPlanet planet.Planet
-	err := json.NewDecoder(r.Body).Decode(&newPlanet)
+	err := decodeBody(r, &newPlanet)
 	if err != nil {
 		log.Println("Error Decoding the planet", err)
-		handleError(w, http.StatusBadRequest, "Planet JSON is Invalid")
+		handleDecodeError(w, err, "Planet JSON is Invalid")
 		return
 	}
 	savedPlanet, err := s.PlanetRepository.Create(ctx, newPlanet)
-	if err != nil {
-		log.Println("Error Creating a planet", err)
-		handleError(w, http.StatusBadRequest, err.Error())
+	if handleVersionError(w, err) {
 		return
 	}
-
-	response, err := json.Marshal(savedPlanet)
 	if err != nil {
-		log.Println("Error Marshaling a planet", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
+		handleContextError(ctx, w, http.StatusBadRequest, "Error Creating a planet", err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(response)
-	if err != nil {
-		log.Println("Error to write the response", err)
-	}
+	w.Header().Set("ETag", etag(savedPlanet.Version))
+
+	writeResponse(w, r, http.StatusCreated, savedPlanet)
 }
 
 func (s *Server) getPlanetByNameHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx := r.Context()
 	planetName := r.URL.Query().Get("name")
-	planet, err := retriever.RetrivePlanetByName(ctx, planetName, s.CountRetriever, s.PlanetRepository)
-	if err != nil {
-		log.Println("Error retriving the planet", err)
-		handleError(w, http.StatusNotFound, err.Error())
+	view, ok := s.checkView(w, r)
+	if !ok {
 		return
 	}
-
-	response, err := json.Marshal(planet)
-	if err != nil {
-		log.Println("Error Marshaling the result planet", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
-		return
+	planet, err := s.PlanetRepository.FindByName(ctx, planetName, view.repositoryFields()...)
+	if err == nil {
+		planet, err = s.fill(ctx, planet, view)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(response)
 	if err != nil {
-		log.Println("Error to write the response", err)
+		handleContextError(ctx, w, http.StatusNotFound, "Error retriving the planet", err)
+		return
 	}
+	w.Header().Set("ETag", etag(planet.Version))
+
+	writeResponse(w, r, http.StatusOK, view.render(planet))
 }
 
 func (s *Server) getPlanetHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx := r.Context()
 	vars := mux.Vars(r)
-	planet, err := retriever.RetrivePlanet(ctx, vars["id"], s.CountRetriever, s.PlanetRepository)
-	if err != nil {
-		log.Println("Error retriving the planet", err)
-		handleError(w, http.StatusNotFound, err.Error())
+	view, ok := s.checkView(w, r)
+	if !ok {
 		return
 	}
-
-	response, err := json.Marshal(planet)
+	planet, err := s.PlanetRepository.FindByID(ctx, vars["id"], view.repositoryFields()...)
 	if err != nil {
-		log.Println("Error Marshaling the result planet", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
+		handleContextError(ctx, w, http.StatusNotFound, "Error retriving the planet", err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(response)
+	w.Header().Set("ETag", etag(planet.Version))
+	if notModified(r, planet.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	planet, err = s.fill(ctx, planet, view)
 	if err != nil {
-		log.Println("Error to write the response", err)
+		handleContextError(ctx, w, http.StatusNotFound, "Error retriving the planet", err)
+		return
 	}
+
+	writeResponse(w, r, http.StatusOK, view.render(planet))
 }
 
 func (s *Server) updatePlanetHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx := r.Context()
 	vars := mux.Vars(r)
+	version, ok := s.ifMatchVersion(w, r, vars["id"])
+	if !ok {
+		return
+	}
 	var newPlanet planet.Planet
-	err := json.NewDecoder(r.Body).Decode(&newPlanet)
+	err := decodeBody(r, &newPlanet)
 	if err != nil {
 		log.Println("Error Decoding the planet", err)
-		handleError(w, http.StatusBadRequest, "Planet JSON is Invalid")
+		handleDecodeError(w, err, "Planet JSON is Invalid")
 		return
 	}
 	newPlanet.ID = vars["id"]
+	newPlanet.Version = version
 	planet, err := s.PlanetRepository.Update(ctx, newPlanet)
-	if err != nil {
-		log.Println("Error updating the planet", err)
-		handleError(w, http.StatusBadRequest, err.Error())
+	if handleVersionError(w, err) {
 		return
 	}
-
-	response, err := json.Marshal(planet)
 	if err != nil {
-		log.Println("Error Marshaling the result planet", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
+		handleContextError(ctx, w, http.StatusBadRequest, "Error updating the planet", err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(response)
-	if err != nil {
-		log.Println("Error to write the response", err)
-	}
+	w.Header().Set("ETag", etag(planet.Version))
+
+	writeResponse(w, r, http.StatusOK, planet)
 }
 
 func (s *Server) deletePlanetHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx := r.Context()
 	vars := mux.Vars(r)
+	version, ok := s.ifMatchVersion(w, r, vars["id"])
+	if !ok {
+		return
+	}
 
-	err := s.PlanetRepository.Delete(ctx, vars["id"])
+	err := s.PlanetRepository.Delete(ctx, vars["id"], version)
+	if handleVersionError(w, err) {
+		return
+	}
 	if err != nil {
-		log.Println("Error deleting the planet", err)
-		handleError(w, http.StatusBadRequest, err.Error())
+		handleContextError(ctx, w, http.StatusBadRequest, "Error deleting the planet", err)
 		return
 	}
 
@@ -179,6 +219,65 @@ func (s *Server) deletePlanetHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// read protects a handler which only reads planets, b is the kind of body it answers with
+func (s *Server) read(b responseBody, h http.HandlerFunc) http.HandlerFunc {
+	return withTimeout(s.Cfg.ReadHandlerTimeout, s.requireScope(auth.ScopePlanetsRead, rateLimit(s.readLimiter, negotiate(b, h))))
+}
+
+// write protects a handler which changes planets, b is the kind of body it answers with
+func (s *Server) write(b responseBody, h http.HandlerFunc) http.HandlerFunc {
+	return withTimeout(s.Cfg.WriteHandlerTimeout, s.requireScope(auth.ScopePlanetsWrite, rateLimit(s.writeLimiter, negotiate(b, h))))
+}
+
+// bulk protects a handler which writes many planets, like the imports and the SWAPI sync, it has the longer import timeout
+// and answers with a report
+func (s *Server) bulk(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return withTimeout(s.Cfg.ImportHandlerTimeout, s.requireScope(scope, rateLimit(s.writeLimiter, negotiate(otherBody, h))))
+}
+
+// admin protects a handler which only administrators may call, like the deleted planets, the audit trail and the webhooks,
+// b is the kind of body it answers with
+func (s *Server) admin(b responseBody, h http.HandlerFunc) http.HandlerFunc {
+	return withTimeout(s.Cfg.ReadHandlerTimeout, s.requireScope(auth.ScopePlanetsAdmin, rateLimit(s.readLimiter, negotiate(b, h))))
+}
+
+// stream protects a handler which streams to the reader, like the events and the exports,
+// it has no timeout since a stream lasts until the client leaves or, for the exports, until the last planet
+func (s *Server) stream(h http.HandlerFunc) http.HandlerFunc {
+	return s.requireScope(auth.ScopePlanetsRead, rateLimit(s.readLimiter, h))
+}
+
+// withTimeout derives the request context from the client one with the given timeout budget,
+// so a client disconnection or an exhausted budget cancels the Mongo and SWAPI work
+func withTimeout(timeout time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// clientClosedRequest is the non standard status logged when the client goes away before the response
+const clientClosedRequest = 499
+
+// handleContextError logs and writes the error with the given status code, unless the request context ended first:
+// an exceeded deadline becomes a Gateway Timeout and a client disconnection writes only the 499 status, since nobody reads it
+func handleContextError(ctx context.Context, w http.ResponseWriter, statusCode int, logMessage string, err error) {
+	switch ctx.Err() {
+	case context.Canceled:
+		w.WriteHeader(clientClosedRequest)
+	case context.DeadlineExceeded:
+		log.Println(logMessage, err)
+		handleError(w, http.StatusGatewayTimeout, "The request deadline was exceeded before the operation finished")
+	default:
+		log.Println(logMessage, err)
+		handleError(w, statusCode, err.Error())
+	}
+}
+
 func handleError(w http.ResponseWriter, statusCode int, errorMensage string) {
 	response, _ := json.Marshal(map[string]string{"error": errorMensage})
 	w.Header().Set("Content-Type", "application/json")
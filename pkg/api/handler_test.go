@@ -0,0 +1,395 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/rafaelreinert/stars/pkg/planet/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPlanetsWhenTheDeadlineIsExceeded(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   slowCounterMock{},
+		Cfg:              config.Config{ReadHandlerTimeout: 10 * time.Millisecond, AllowInsecureNoAuth: true},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.NotEmpty(t, body["error"])
+}
+
+func TestGetPlanetUsesTheClientContext(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   slowCounterMock{},
+		Cfg:              config.Config{ReadHandlerTimeout: time.Minute, AllowInsecureNoAuth: true},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets/1", nil).WithContext(ctx))
+
+	assert.True(t, time.Since(start) < time.Second, "The handler should stop when the client goes away")
+	assert.Equal(t, clientClosedRequest, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := Server{Cfg: config.Config{
+		AllowInsecureNoAuth: true,
+		CORSAllowedOrigins:  []string{"https://app.example.com"},
+		CORSAllowedMethods:  []string{"GET", "POST"},
+		CORSAllowedHeaders:  []string{"Authorization", "Content-Type"},
+		CORSExposedHeaders:  []string{"RateLimit-Remaining"},
+		CORSMaxAge:          300,
+	}}
+	h := s.handler()
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/planets", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Authorization,Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "300", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	rec = preflight("https://evil.example.com")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSIsDisabledWithoutOrigins(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/planets", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+type slowCounterMock struct{}
+
+func (c slowCounterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return 1, nil
+	}
+}
+
+type staticCounterMock map[string]int
+
+func (c staticCounterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	return c[name], nil
+}
+
+// repositoryMock is an in memory PlanetRepository used by the handler tests
+type repositoryMock struct {
+	mu      sync.Mutex
+	nextID  int
+	planets map[string]planet.Planet
+	// fields are the ones asked by the last finder
+	fields []string
+}
+
+func newRepositoryMock(planets ...planet.Planet) *repositoryMock {
+	r := &repositoryMock{planets: map[string]planet.Planet{}}
+	for _, p := range planets {
+		r.Create(context.Background(), p)
+	}
+	return r
+}
+
+// newTestServer creates a Server on a repositoryMock with the planets and with the counts of staticCounterMock{"Tatooine": 5},
+// the authentication of the config is disabled since the tests of the routes do not authenticate
+func newTestServer(cfg config.Config, planets ...planet.Planet) (*Server, *repositoryMock) {
+	cfg.AllowInsecureNoAuth = true
+	repo := newRepositoryMock(planets...)
+	return &Server{PlanetRepository: repo, CountRetriever: staticCounterMock{"Tatooine": 5}, Cfg: cfg}, repo
+}
+
+func (r *repositoryMock) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	p.ID = strconv.Itoa(r.nextID)
+	p.Version = 1
+	r.planets[p.ID] = p
+	return p, nil
+}
+
+// checkVersion mimics the compare-and-set of the mongo repository, deleted planets are not found
+func (r *repositoryMock) checkVersion(id string, version int64) (planet.Planet, error) {
+	p, ok := r.planets[id]
+	if !ok || p.DeletedAt != nil {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if version != 0 && version != p.Version {
+		return planet.Planet{}, repository.ErrVersionMismatch
+	}
+	return p, nil
+}
+
+func (r *repositoryMock) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = fields
+	p, err := r.checkVersion(id, 0)
+	return repository.Project(p, fields), err
+}
+
+func (r *repositoryMock) FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = fields
+	for _, p := range r.planets {
+		if planet.NameKey(p.Name) == planet.NameKey(name) && p.DeletedAt == nil {
+			return repository.Project(p, fields), nil
+		}
+	}
+	return planet.Planet{}, repository.ErrNotFound
+}
+
+func (r *repositoryMock) FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error) {
+	planets := r.filter(func(p planet.Planet) bool { return p.DeletedAt == nil })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = fields
+	for i, p := range planets {
+		planets[i] = repository.Project(p, fields)
+	}
+	return planets, nil
+}
+
+// FindEach calls fn in the numeric id order, like FindPage
+func (r *repositoryMock) FindEach(ctx context.Context, fn func(planet.Planet) error) error {
+	planets, _ := r.FindPage(ctx, "", math.MaxInt32)
+	for _, p := range planets {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindPage orders the planets by their numeric id, like the creation order of the mongo ids
+func (r *repositoryMock) FindPage(ctx context.Context, after string, limit int, fields ...string) ([]planet.Planet, error) {
+	start := 0
+	if after != "" {
+		n, err := strconv.Atoi(after)
+		if err != nil {
+			return nil, err
+		}
+		start = n
+	}
+	planets := r.filter(func(p planet.Planet) bool {
+		n, _ := strconv.Atoi(p.ID)
+		return p.DeletedAt == nil && n > start
+	})
+	sort.Slice(planets, func(i, j int) bool {
+		a, _ := strconv.Atoi(planets[i].ID)
+		b, _ := strconv.Atoi(planets[j].ID)
+		return a < b
+	})
+	if len(planets) > limit {
+		planets = planets[:limit]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = fields
+	for i, p := range planets {
+		planets[i] = repository.Project(p, fields)
+	}
+	return planets, nil
+}
+
+// Search ranks the planets in the numeric id order, like the memory repository
+func (r *repositoryMock) Search(ctx context.Context, query string, limit int) ([]repository.SearchResult, error) {
+	planets, _ := r.FindPage(ctx, "", math.MaxInt32)
+	return search.Rank(planets, query, limit), nil
+}
+
+func (r *repositoryMock) FindDeleted(ctx context.Context) ([]planet.Planet, error) {
+	return r.filter(func(p planet.Planet) bool { return p.DeletedAt != nil }), nil
+}
+
+func (r *repositoryMock) filter(match func(planet.Planet) bool) []planet.Planet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	planets := []planet.Planet{}
+	for _, p := range r.planets {
+		if match(p) {
+			planets = append(planets, p)
+		}
+	}
+	return planets
+}
+
+func (r *repositoryMock) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, err := r.checkVersion(p.ID, p.Version)
+	if _, exists := r.planets[p.ID]; !exists && p.Version == 0 {
+		err = nil
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	p.Version = current.Version + 1
+	r.planets[p.ID] = p
+	return p, nil
+}
+
+func (r *repositoryMock) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.checkVersion(id, u.Version)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	p = u.Apply(p)
+	p.Version++
+	r.planets[id] = p
+	return p, nil
+}
+
+func (r *repositoryMock) Delete(ctx context.Context, id string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.checkVersion(id, version)
+	if err != nil {
+		if version != 0 {
+			return err
+		}
+		return nil
+	}
+	now := time.Now()
+	p.DeletedAt = &now
+	p.Version++
+	r.planets[id] = p
+	return nil
+}
+
+func (r *repositoryMock) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.planets[id]
+	if !ok || p.DeletedAt == nil {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	p.DeletedAt = nil
+	p.Version++
+	r.planets[id] = p
+	return p, nil
+}
+
+func (r *repositoryMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var purged int64
+	for id, p := range r.planets {
+		if p.DeletedAt != nil && p.DeletedAt.Before(before) {
+			delete(r.planets, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Bulk fails the deletes of unknown planets, so the tests can exercise the failures
+func (r *repositoryMock) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	results := make([]repository.BulkResult, len(ops))
+	failed := false
+	for i, op := range ops {
+		if failed && ordered {
+			results[i].Err = repository.ErrNotExecuted
+			continue
+		}
+		var err error
+		switch op.Type {
+		case repository.BulkCreate:
+			op.Planet, err = r.Create(ctx, op.Planet)
+		case repository.BulkUpdate:
+			_, err = r.Update(ctx, op.Planet)
+		case repository.BulkDelete:
+			if _, err = r.FindByID(ctx, op.Planet.ID); err == nil {
+				err = r.Delete(ctx, op.Planet.ID, 0)
+			}
+		}
+		results[i] = repository.BulkResult{ID: op.Planet.ID, Err: err}
+		failed = failed || err != nil
+	}
+	return results, nil
+}
+
+func TestPlanetNamesAreNormalized(t *testing.T) {
+	repo := memrep.NewMemoryRepository()
+	alderaan, _ := repo.Create(context.Background(), planet.Planet{Name: "Alderaan", Climate: "temperate"})
+	hoth, _ := repo.Create(context.Background(), planet.Planet{Name: "Hoth"})
+	s := Server{
+		PlanetRepository: repo,
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, OpenAPIValidateRequests: true, OpenAPIValidateResponses: true},
+	}
+	h := s.handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if method == http.MethodPatch {
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/planets?name=%20alderaan", "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"id":"`+alderaan.ID+`"`)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/planets", `{"name": "ALDERAAN "}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/planets/"+hoth.ID, `{"name": "alderaan"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPatch, "/planets/"+hoth.ID, `{"name": "Alderaan"}`).Code)
+	rec = do(http.MethodPost, "/planets:batch", `{"operations": [{"op": "create", "planet": {"name": "alderaan"}}]}`)
+	assert.Contains(t, rec.Body.String(), `"status":409`)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/planets/"+alderaan.ID, "").Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/planets", `{"name": "alderaan"}`).Code, "A deleted planet should free its name")
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/planets/"+alderaan.ID+":restore", "").Code)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rafaelreinert/stars/pkg/audit"
+)
+
+// planetHistoryHandler lists the audit entries of a planet, the oldest first, it works for deleted planets too
+func (s *Server) planetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.AuditLog == nil {
+		handleError(w, http.StatusNotFound, "The audit trail is not enabled")
+		return
+	}
+	entries, err := s.AuditLog.FindByPlanet(ctx, mux.Vars(r)["id"])
+	if err == audit.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the planet history", err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, entries)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanetHistory(t *testing.T) {
+	store := audit.NewMemoryStore()
+	s := Server{
+		PlanetRepository: audit.NewRepository(newRepositoryMock(), store),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true},
+		AuditLog:         store,
+	}
+	h := s.handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Request-ID", "req-"+method)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/planets", `{"name": "Hoth", "climate": "frozen"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/planets/1", `{"name": "Hoth", "climate": "cold"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/planets/1", "").Code)
+
+	rec := do(http.MethodGet, "/planets/1/history", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-GET", rec.Header().Get("X-Request-ID"))
+	var entries []audit.Entry
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&entries))
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, audit.ActionCreate, entries[0].Action)
+		assert.Equal(t, "req-POST", entries[0].RequestID)
+		assert.Equal(t, "anonymous", entries[0].Actor.ID)
+		assert.Equal(t, "frozen", entries[1].Before.Climate)
+		assert.Equal(t, "cold", entries[1].After.Climate)
+		assert.Equal(t, audit.ActionDelete, entries[2].Action)
+		assert.Equal(t, "req-DELETE", entries[2].RequestID)
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/planets/42/history", "").Code)
+}
+
+func TestPlanetHistoryWithoutAuditLog(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets/1/history", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
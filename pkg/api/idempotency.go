@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/idempotency"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	// pendingLease is added to the handler timeout to keep the key of a running request,
+	// so a crashed instance does not hold the key for the whole window
+	pendingLease = time.Minute
+)
+
+// replayedHeaders are the response headers saved with the record, the rate limit headers belong to the retry
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotent replays the response of a request retried with the same Idempotency-Key,
+// requests without the header run as usual and failed requests release the key so they can be retried
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.IdempotencyStore == nil || s.Cfg.IdempotencyWindow <= 0 {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			handleError(w, http.StatusBadRequest, "The Idempotency-Key header is too long")
+			return
+		}
+		ctx := r.Context()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleContextError(ctx, w, http.StatusBadRequest, "Error reading the request body", err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		record := idempotency.Record{
+			Key:         idempotencyScope(ctx) + ":" + key,
+			RequestHash: idempotency.HashRequest(r.Method, r.URL.Path, body),
+			ExpiresAt:   time.Now().Add(s.Cfg.WriteHandlerTimeout + pendingLease),
+		}
+		existing, reserved, err := s.IdempotencyStore.Reserve(ctx, record)
+		if err == nil && !reserved {
+			err = idempotency.Check(existing, record.RequestHash)
+		}
+		switch err {
+		case nil:
+		case idempotency.ErrMismatch:
+			handleError(w, http.StatusUnprocessableEntity, "The Idempotency-Key was already used with another request")
+			return
+		case idempotency.ErrInProgress:
+			handleError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		default:
+			handleContextError(ctx, w, http.StatusInternalServerError, "Error reserving the idempotency key", err)
+			return
+		}
+		if !reserved {
+			replay(w, existing)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+
+		// the request context may be gone already, the record must still be saved or released
+		storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if rec.status < 200 || rec.status > 299 {
+			if err := s.IdempotencyStore.Release(storeCtx, record.Key); err != nil {
+				log.Println("Error releasing the idempotency key", err)
+			}
+			return
+		}
+		record.Status = rec.status
+		record.Header = http.Header{}
+		for _, name := range replayedHeaders {
+			if value := rec.Header().Get(name); value != "" {
+				record.Header.Set(name, value)
+			}
+		}
+		record.Body = rec.body.Bytes()
+		record.ExpiresAt = time.Now().Add(s.Cfg.IdempotencyWindow)
+		if err := s.IdempotencyStore.Complete(storeCtx, record); err != nil {
+			log.Println("Error saving the idempotent response", err)
+		}
+	}
+}
+
+// idempotencyScope keeps the keys of each principal apart, so a client can not replay the response of another one
+func idempotencyScope(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.ID
+	}
+	return "anonymous"
+}
+
+func replay(w http.ResponseWriter, record idempotency.Record) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	if _, err := w.Write(record.Body); err != nil {
+		log.Println("Error to write the response", err)
+	}
+}
+
+// responseRecorder writes the response through while keeping a copy of the status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
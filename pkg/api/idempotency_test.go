@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentCreate(t *testing.T) {
+	repo := newRepositoryMock()
+	s := Server{
+		PlanetRepository: repo,
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, IdempotencyWindow: time.Hour},
+		IdempotencyStore: idempotency.NewMemoryStore(),
+	}
+	h := s.handler()
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/planets", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("k1", `{"name": "Hoth"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	retry := post("k1", `{"name": "Hoth"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, post("k1", `{"name": "Naboo"}`).Code)
+
+	assert.Equal(t, http.StatusBadRequest, post("k2", `{`).Code)
+	assert.Equal(t, http.StatusCreated, post("k2", `{"name": "Naboo"}`).Code, "A failed request should release the key")
+
+	assert.Equal(t, http.StatusCreated, post("", `{"name": "Hoth"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(strings.Repeat("k", maxIdempotencyKeyLen+1), `{"name": "Hoth"}`).Code)
+
+	planets, _ := repo.FindAll(context.Background())
+	assert.Len(t, planets, 3, "The retries should not create planets")
+}
+
+func TestIdempotentCreateInProgress(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	s := Server{
+		PlanetRepository: newRepositoryMock(),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, IdempotencyWindow: time.Hour},
+		IdempotencyStore: store,
+	}
+	body := `{"name": "Hoth"}`
+	store.Reserve(context.Background(), idempotency.Record{
+		Key:         "anonymous:k1",
+		RequestHash: idempotency.HashRequest(http.MethodPost, "/planets", []byte(body)),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/planets", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "k1")
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+)
+
+// importFormats maps the Content-Type of an import to its format
+var importFormats = map[string]string{
+	"application/json":     importer.FormatJSON,
+	"application/x-ndjson": importer.FormatNDJSON,
+	"application/ndjson":   importer.FormatNDJSON,
+	"text/csv":             importer.FormatCSV,
+}
+
+// importResponse is the body of POST /planets:import, Error tells why the import stopped before the end
+type importResponse struct {
+	importer.Report
+	Error string `json:"error,omitempty"`
+}
+
+// importPlanetsHandler streams the body into the repository, the rows are read and written a batch at a time
+// so the size of the file does not matter
+func (s *Server) importPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, err := importFormat(r)
+	if err != nil {
+		handleError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	options, columns, err := importOptions(r.URL.Query())
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// the upserts are unconditional updates, so they are refused when every change must be conditional
+	if options.Upsert && s.Cfg.RequireIfMatch {
+		handleError(w, http.StatusPreconditionRequired, "Upsert imports are disabled while If-Match is required")
+		return
+	}
+	reader, err := importer.NewReader(format, r.Body, columns)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := importer.Import(ctx, s.PlanetRepository, reader, options)
+	if err != nil && ctx.Err() != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error importing the planets", err)
+		return
+	}
+	if err != nil {
+		log.Println("Error importing the planets", err)
+		writeResponse(w, r, http.StatusUnprocessableEntity, importResponse{Report: report, Error: err.Error()})
+		return
+	}
+	writeResponse(w, r, http.StatusOK, importResponse{Report: report})
+}
+
+// importFormat is the format query parameter, or the one of the Content-Type when it is absent
+func importFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case importer.FormatJSON, importer.FormatNDJSON, importer.FormatCSV:
+			return format, nil
+		}
+		return "", errors.Errorf("The import format %q is not supported, use json, ndjson or csv", format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if format, ok := importFormats[mediaType]; ok {
+		return format, nil
+	}
+	return "", errors.Errorf("The import Content-Type %q is not supported, use application/json, application/x-ndjson or text/csv", mediaType)
+}
+
+// importOptions reads the dryRun, mode and columns query parameters,
+// columns maps the CSV header names to the planet fields as "header:field,header:field"
+func importOptions(query url.Values) (importer.Options, map[string]string, error) {
+	var options importer.Options
+	if dryRun := query.Get("dryRun"); dryRun != "" {
+		var err error
+		if options.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return options, nil, errors.New("The dryRun must be true or false")
+		}
+	}
+	switch query.Get("mode") {
+	case "", "create":
+	case "upsert":
+		options.Upsert = true
+	default:
+		return options, nil, errors.New("The mode must be create or upsert")
+	}
+
+	var columns map[string]string
+	if mapping := query.Get("columns"); mapping != "" {
+		columns = map[string]string{}
+		for _, pair := range strings.Split(mapping, ",") {
+			i := strings.LastIndexByte(pair, ':')
+			if i <= 0 || i == len(pair)-1 {
+				return options, nil, errors.Errorf("The column mapping %q must be header:field", pair)
+			}
+			columns[pair[:i]] = pair[i+1:]
+		}
+	}
+	return options, columns, nil
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportPlanets(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		report      importResponse
+		planets     int
+	}{
+		{
+			name:        "JSON",
+			target:      "/planets:import",
+			contentType: "application/json",
+			body:        `[{"name": "Hoth"}, {"climate": "arid"}, {"name": "Dagobah"}]`,
+			report:      importResponse{Report: reportOf(2, 0, 1, false, `{"row": 2, "error": "the planet name is required"}`)},
+			planets:     3,
+		},
+		{
+			name:        "NDJSON",
+			target:      "/planets:import",
+			contentType: "application/x-ndjson; charset=utf-8",
+			body:        "{\"name\": \"Hoth\"}\n{\"name\": \"Dagobah\"}\n",
+			report:      importResponse{Report: reportOf(2, 0, 0, false)},
+			planets:     3,
+		},
+		{
+			name:        "CSVWithColumns",
+			target:      "/planets:import?columns=Planet:name,Weather:climate",
+			contentType: "text/csv",
+			body:        "Planet,Weather\nHoth,frozen\n",
+			report:      importResponse{Report: reportOf(1, 0, 0, false)},
+			planets:     2,
+		},
+		{
+			name:    "FormatParameter",
+			target:  "/planets:import?format=ndjson",
+			body:    "{\"name\": \"Hoth\"}\n",
+			report:  importResponse{Report: reportOf(1, 0, 0, false)},
+			planets: 2,
+		},
+		{
+			name:        "Upsert",
+			target:      "/planets:import?mode=upsert",
+			contentType: "application/json",
+			body:        `[{"name": "Tatooine", "climate": "hot"}, {"name": "Hoth"}]`,
+			report:      importResponse{Report: reportOf(1, 1, 0, false)},
+			planets:     2,
+		},
+		{
+			name:        "DryRun",
+			target:      "/planets:import?dryRun=true&mode=upsert",
+			contentType: "application/json",
+			body:        `[{"name": "Tatooine"}, {"name": "Hoth"}]`,
+			report:      importResponse{Report: reportOf(1, 1, 0, true)},
+			planets:     1,
+		},
+		{
+			name:        "StopsOnAnInvalidFile",
+			target:      "/planets:import",
+			contentType: "application/json",
+			body:        `[{"name": "Hoth"}, {"name": `,
+			report:      importResponse{Report: reportOf(0, 0, 0, false), Error: "reading the row 2: unexpected EOF"},
+			planets:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Tatooine"})
+			s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+
+			if tt.report.Error == "" {
+				assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			} else {
+				assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+			}
+			var report importResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.report, report)
+			planets, _ := repo.FindAll(context.Background())
+			assert.Len(t, planets, tt.planets)
+		})
+	}
+}
+
+// reportOf builds the expected report, the errors are given as JSON
+func reportOf(created, updated, failed int, dryRun bool, errors ...string) importer.Report {
+	report := importer.Report{Created: created, Updated: updated, Failed: failed, DryRun: dryRun, Errors: []importer.RowError{}}
+	for _, e := range errors {
+		var rowError importer.RowError
+		json.Unmarshal([]byte(e), &rowError)
+		report.Errors = append(report.Errors, rowError)
+	}
+	return report
+}
+
+func TestImportPlanetsRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+		cfg         config.Config
+	}{
+		{"UnknownContentType", "/planets:import", "text/plain", "Hoth", http.StatusUnsupportedMediaType, config.Config{}},
+		{"UnknownFormat", "/planets:import?format=xml", "", "<planet/>", http.StatusUnsupportedMediaType, config.Config{}},
+		{"InvalidMode", "/planets:import?mode=replace", "application/json", "[]", http.StatusBadRequest, config.Config{}},
+		{"InvalidDryRun", "/planets:import?dryRun=maybe", "application/json", "[]", http.StatusBadRequest, config.Config{}},
+		{"InvalidColumns", "/planets:import?columns=planet", "text/csv", "planet\nHoth\n", http.StatusBadRequest, config.Config{}},
+		{"CSVWithoutName", "/planets:import", "text/csv", "planet\nHoth\n", http.StatusBadRequest, config.Config{}},
+		{"UpsertWhileIfMatchIsRequired", "/planets:import?mode=upsert", "application/json", "[]", http.StatusPreconditionRequired, config.Config{RequireIfMatch: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.AllowInsecureNoAuth = true
+			s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: tt.cfg}
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
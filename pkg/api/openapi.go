@@ -0,0 +1,259 @@
+package api
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// openAPIDocument is the part of the OpenAPI document used to route, validate and render the docs
+type openAPIDocument struct {
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+		Responses  map[string]*openAPIResponse  `json:"responses"`
+		Schemas    map[string]*schema           `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Tags        []string                    `json:"tags"`
+	Summary     string                      `json:"summary"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []*openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+	// Stream marks the bodies read as they arrive, which are too large to be buffered by the validation
+	Stream bool `json:"x-stream"`
+}
+
+type openAPIResponse struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content"`
+	// Stream marks the bodies written as they are read, which are too large to be buffered by the validation
+	Stream bool `json:"x-stream"`
+}
+
+// openAPIRoute is an operation with the pattern of its path template
+type openAPIRoute struct {
+	Method    string
+	Path      string
+	pattern   *regexp.Regexp
+	literals  int
+	Operation *openAPIOperation
+}
+
+// spec is the parsed openAPISpec, the tests make sure it parses
+var spec = mustParseSpec(openAPISpec)
+
+func mustParseSpec(document string) *openAPIDocument {
+	var doc openAPIDocument
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		panic("the OpenAPI document is invalid: " + err.Error())
+	}
+	for _, s := range doc.Components.Schemas {
+		s.resolve(doc.Components.Schemas)
+	}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			for i, p := range op.Parameters {
+				if p.Ref != "" {
+					op.Parameters[i] = doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				}
+				op.Parameters[i].Schema.resolve(doc.Components.Schemas)
+			}
+			if op.RequestBody != nil {
+				for _, m := range op.RequestBody.Content {
+					m.Schema.resolve(doc.Components.Schemas)
+				}
+			}
+			for status, resp := range op.Responses {
+				if resp.Ref != "" {
+					resp = doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+					op.Responses[status] = resp
+				}
+				for _, m := range resp.Content {
+					m.Schema.resolve(doc.Components.Schemas)
+				}
+			}
+		}
+	}
+	return &doc
+}
+
+// routes returns the operations, the most specific path templates first
+func (doc *openAPIDocument) routes() []openAPIRoute {
+	var routes []openAPIRoute
+	for path, item := range doc.Paths {
+		pattern, literals := pathPattern(path)
+		for method, op := range item {
+			routes = append(routes, openAPIRoute{Method: strings.ToUpper(method), Path: path, pattern: pattern, literals: literals, Operation: op})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].literals != routes[j].literals {
+			return routes[i].literals > routes[j].literals
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+	return routes
+}
+
+func methodOrder(method string) int {
+	for i, m := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		if m == method {
+			return i
+		}
+	}
+	return len(method)
+}
+
+var pathParameter = regexp.MustCompile(`\{[^}]+\}`)
+
+// pathPattern converts the path template to a regular expression and counts its literal characters
+func pathPattern(path string) (*regexp.Regexp, int) {
+	literals := pathParameter.ReplaceAllString(path, "")
+	parts := pathParameter.Split(path, -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "[^/]+") + "$"), len(literals)
+}
+
+// findRoute returns the operation which serves the request
+func findRoute(routes []openAPIRoute, r *http.Request) (openAPIRoute, bool) {
+	for _, route := range routes {
+		if route.Method == r.Method && route.pattern.MatchString(r.URL.Path) {
+			return route, true
+		}
+	}
+	return openAPIRoute{}, false
+}
+
+// openAPIHandler serves the OpenAPI document
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(openAPISpec)); err != nil {
+		log.Println("Error to write the response", err)
+	}
+}
+
+// docsHandler serves the API reference rendered from the OpenAPI document
+func (s *Server) docsHandler(w http.ResponseWriter, r *http.Request) {
+	routes := spec.routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err := docsTemplate.Execute(w, struct {
+		Doc    *openAPIDocument
+		Routes []openAPIRoute
+	}{spec, routes})
+	if err != nil {
+		log.Println("Error to write the docs", err)
+	}
+}
+
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"lower":    strings.ToLower,
+	"statuses": sortedStatuses,
+	"name":     schemaName,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Doc.Info.Title}} API</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+section { border: 1px solid #ddd; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.method { display: inline-block; min-width: 4.5em; font-weight: bold; text-transform: uppercase; }
+.get { color: #1a7f37; } .post { color: #0969da; } .put { color: #9a6700; } .patch { color: #8250df; } .delete { color: #cf222e; }
+code { background: #f6f8fa; padding: 0 0.2em; }
+table { border-collapse: collapse; } td, th { text-align: left; padding: 0.2em 1em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Doc.Info.Title}} <small>{{.Doc.Info.Version}}</small></h1>
+<p>{{.Doc.Info.Description}}</p>
+<p>The machine-readable contract is at <a href="/openapi.json">/openapi.json</a>.</p>
+{{range .Routes}}
+<section id="{{.Operation.OperationID}}">
+<h3><span class="method {{lower .Method}}">{{.Method}}</span> <code>{{.Path}}</code></h3>
+<p>{{.Operation.Summary}}</p>
+{{with .Operation.Parameters}}<table>
+<tr><th>Parameter</th><th>In</th><th>Description</th></tr>
+{{range .}}<tr><td><code>{{.Name}}</code>{{if .Required}} *{{end}}</td><td>{{.In}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Operation.RequestBody}}<p>Body: {{range $type, $m := .Content}}<code>{{$type}}</code> {{name $m.Schema}} {{end}}</p>{{end}}
+<ul>
+{{$responses := .Operation.Responses}}{{range statuses $responses}}{{$resp := index $responses .}}<li><b>{{.}}</b> {{$resp.Description}}{{range $type, $m := $resp.Content}} <code>{{$type}}</code> {{name $m.Schema}}{{end}}</li>
+{{end}}</ul>
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+func sortedStatuses(responses map[string]*openAPIResponse) []string {
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
+
+// schemaName names the schema on the docs, by its component name or its type
+func schemaName(s *schema) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case s.Type == "array" && s.Items != nil:
+		return "array of " + schemaName(s.Items)
+	case len(s.OneOf) > 0:
+		names := make([]string, len(s.OneOf))
+		for i, o := range s.OneOf {
+			names[i] = schemaName(o)
+		}
+		return strings.Join(names, " or ")
+	}
+	return s.Type
+}
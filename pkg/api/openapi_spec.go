@@ -0,0 +1,631 @@
+package api
+
+// openAPISpec is the OpenAPI 3 contract of every route registered by Server.handler,
+// TestOpenAPISpecMatchesRoutes fails when they drift apart
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Stars",
+    "version": "1.0.0",
+    "description": "Star Wars planets catalogue, the number of appearances on movies comes from SWAPI. Every application/json body may also be application/yaml or application/msgpack, chosen by the Accept header on the responses and by the Content-Type on the requests, and the planets may also be read as text/csv. The errors are always JSON, and 406 answers, before any change, an Accept with none of the types the route produces."
+  },
+  "security": [{"bearerAuth": []}],
+  "tags": [
+    {"name": "planets", "description": "The planets catalogue"},
+    {"name": "admin", "description": "Deleted planets, audit trail and webhooks, they require the planets:admin scope"},
+    {"name": "docs", "description": "This contract"}
+  ],
+  "paths": {
+    "/planets": {
+      "get": {
+        "tags": ["planets"],
+        "summary": "List the planets, or find one by its name",
+        "operationId": "listPlanets",
+        "parameters": [
+          {"name": "name", "in": "query", "description": "Returns the single planet with this name instead of the list, ignoring the case and the surrounding spaces", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "description": "Returns a page of up to limit planets ordered by id, the Link header points to the next page", "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 100}},
+          {"name": "after", "in": "query", "description": "Returns the page of the planets after this id", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/Fields"},
+          {"$ref": "#/components/parameters/Expand"}
+        ],
+        "responses": {
+          "200": {
+            "description": "The planets, or the planet with the name",
+            "headers": {"Link": {"description": "The next page, rel=\"next\", while a page is full", "schema": {"type": "string"}}},
+            "content": {"application/json": {"schema": {"oneOf": [
+              {"type": "array", "items": {"$ref": "#/components/schemas/PlanetView"}},
+              {"$ref": "#/components/schemas/PlanetView"}
+            ]}}}
+          },
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "tags": ["planets"],
+        "summary": "Create a planet",
+        "operationId": "createPlanet",
+        "parameters": [{"$ref": "#/components/parameters/IdempotencyKey"}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PlanetInput"}}}
+        },
+        "responses": {
+          "201": {"description": "The created planet", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Planet"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets/search": {
+      "get": {
+        "tags": ["planets"],
+        "summary": "Search the planets by the words of their name, climate and terrain",
+        "description": "The matching ignores the case and the diacritics and the name words weigh more. When no word matches, the planets with close words, like a typo or the start of a word, are returned.",
+        "operationId": "searchPlanets",
+        "parameters": [
+          {"name": "q", "in": "query", "required": true, "description": "The words to search", "schema": {"type": "string", "minLength": 1}, "example": "tatooine"},
+          {"name": "limit", "in": "query", "description": "The maximum number of planets", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}}
+        ],
+        "responses": {
+          "200": {"description": "The matching planets, the most relevant first", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/SearchHit"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets:batch": {
+      "post": {
+        "tags": ["planets"],
+        "summary": "Create, update and delete up to 1000 planets",
+        "operationId": "batchPlanets",
+        "parameters": [{"$ref": "#/components/parameters/IdempotencyKey"}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BatchRequest"}}}
+        },
+        "responses": {
+          "200": {"description": "The result of each operation, in order", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BatchResponse"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets:export": {
+      "get": {
+        "tags": ["planets"],
+        "summary": "Stream every planet as NDJSON or CSV, gzip compressed when the client accepts it",
+        "operationId": "exportPlanets",
+        "parameters": [
+          {"name": "format", "in": "query", "description": "The format of the export", "schema": {"type": "string", "enum": ["ndjson", "csv"], "default": "ndjson"}},
+          {"name": "counts", "in": "query", "description": "Fills the number of appearances on movies from SWAPI, which is slower", "schema": {"type": "boolean", "default": false}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A planet per line, with its id, name, climate, terrain, version and, with counts, numberOfAppearancesOnMovies",
+            "x-stream": true,
+            "content": {
+              "application/x-ndjson": {"schema": {"type": "string"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets:import": {
+      "post": {
+        "tags": ["planets"],
+        "summary": "Import the planets of a JSON array, NDJSON or CSV file",
+        "operationId": "importPlanets",
+        "parameters": [
+          {"name": "format", "in": "query", "description": "The format of the body, by default it comes from the Content-Type", "schema": {"type": "string", "enum": ["json", "ndjson", "csv"]}},
+          {"name": "dryRun", "in": "query", "description": "Validates and counts the rows without writing them", "schema": {"type": "boolean", "default": false}},
+          {"name": "mode", "in": "query", "description": "upsert updates the planet which already has the row name instead of creating another one", "schema": {"type": "string", "enum": ["create", "upsert"], "default": "create"}},
+          {"name": "columns", "in": "query", "description": "Maps the CSV header names to the name, climate and terrain fields, as header:field,header:field", "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "x-stream": true,
+          "content": {
+            "application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/PlanetInput"}}},
+            "application/x-ndjson": {"schema": {"type": "string", "description": "A planet JSON per line"}},
+            "text/csv": {"schema": {"type": "string", "description": "A header with a name column, and optionally climate and terrain, then a planet per line"}}
+          }
+        },
+        "responses": {
+          "200": {"description": "The import report, the failed rows did not stop the import", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ImportReport"}}}},
+          "422": {"description": "The import stopped, the report has the rows written before the error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ImportReport"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets:sync": {
+      "post": {
+        "tags": ["admin"],
+        "summary": "Create and update the local planets out of the SWAPI catalogue",
+        "operationId": "syncPlanets",
+        "parameters": [
+          {"name": "mode", "in": "query", "description": "merge creates the missing planets and fills the empty fields, overwrite replaces the fields which differ and report only lists what overwrite would change", "schema": {"type": "string", "enum": ["merge", "overwrite", "report"], "default": "merge"}}
+        ],
+        "responses": {
+          "200": {"description": "The diff summary, the unchanged planets are only counted", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SyncReport"}}}},
+          "502": {"description": "SWAPI could not be read, the report has the changes made before it", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SyncReport"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets/events": {
+      "get": {
+        "tags": ["planets"],
+        "summary": "Stream the planet changes as Server-Sent Events",
+        "operationId": "streamPlanetEvents",
+        "parameters": [
+          {"name": "Last-Event-ID", "in": "header", "description": "Resumes the stream after this event", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The planet.created, planet.updated, planet.deleted and reset events", "content": {"text/event-stream": {"schema": {"type": "string"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets:deleted": {
+      "get": {
+        "tags": ["admin"],
+        "summary": "List the deleted planets which can still be restored",
+        "operationId": "listDeletedPlanets",
+        "responses": {
+          "200": {"description": "The deleted planets, the last deleted first", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Planet"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets/{id}:restore": {
+      "post": {
+        "tags": ["planets"],
+        "summary": "Restore a deleted planet",
+        "operationId": "restorePlanet",
+        "parameters": [{"$ref": "#/components/parameters/PlanetID"}],
+        "responses": {
+          "200": {"description": "The restored planet", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Planet"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets/{id}/history": {
+      "get": {
+        "tags": ["admin"],
+        "summary": "List the audit entries of a planet, the oldest first",
+        "operationId": "planetHistory",
+        "parameters": [{"$ref": "#/components/parameters/PlanetID"}],
+        "responses": {
+          "200": {"description": "The audit entries", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/AuditEntry"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/planets/{id}": {
+      "get": {
+        "tags": ["planets"],
+        "summary": "Get a planet",
+        "operationId": "getPlanet",
+        "parameters": [
+          {"$ref": "#/components/parameters/PlanetID"},
+          {"name": "If-None-Match", "in": "header", "description": "Answers 304 when the planet still has this ETag", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/Fields"},
+          {"$ref": "#/components/parameters/Expand"}
+        ],
+        "responses": {
+          "200": {"description": "The planet", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PlanetView"}}}},
+          "304": {"description": "The planet did not change"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "tags": ["planets"],
+        "summary": "Replace a planet, it is created when it does not exist",
+        "operationId": "updatePlanet",
+        "parameters": [{"$ref": "#/components/parameters/PlanetID"}, {"$ref": "#/components/parameters/IfMatch"}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PlanetInput"}}}
+        },
+        "responses": {
+          "200": {"description": "The updated planet", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Planet"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "tags": ["planets"],
+        "summary": "Change some fields of a planet with a JSON Merge Patch or a JSON Patch",
+        "operationId": "patchPlanet",
+        "parameters": [{"$ref": "#/components/parameters/PlanetID"}, {"$ref": "#/components/parameters/IfMatch"}],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/merge-patch+json": {"schema": {"$ref": "#/components/schemas/MergePatch"}},
+            "application/json-patch+json": {"schema": {"$ref": "#/components/schemas/JSONPatch"}}
+          }
+        },
+        "responses": {
+          "200": {"description": "The patched planet", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Planet"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "tags": ["planets"],
+        "summary": "Delete a planet, it can be restored until the purge",
+        "operationId": "deletePlanet",
+        "parameters": [{"$ref": "#/components/parameters/PlanetID"}, {"$ref": "#/components/parameters/IfMatch"}],
+        "responses": {
+          "200": {"description": "The planet was deleted"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhooks": {
+      "get": {
+        "tags": ["admin"],
+        "summary": "List the webhooks, without their secrets",
+        "operationId": "listWebhooks",
+        "responses": {
+          "200": {"description": "The webhooks", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Webhook"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "tags": ["admin"],
+        "summary": "Subscribe a URL to the planet events",
+        "operationId": "createWebhook",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookInput"}}}
+        },
+        "responses": {
+          "201": {"description": "The webhook with its signing secret, which is not shown again", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhooks/{id}": {
+      "get": {
+        "tags": ["admin"],
+        "summary": "Get a webhook, without its secret",
+        "operationId": "getWebhook",
+        "parameters": [{"$ref": "#/components/parameters/WebhookID"}],
+        "responses": {
+          "200": {"description": "The webhook", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "tags": ["admin"],
+        "summary": "Replace the url, events and active flag of a webhook, the secret is kept",
+        "operationId": "updateWebhook",
+        "parameters": [{"$ref": "#/components/parameters/WebhookID"}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookInput"}}}
+        },
+        "responses": {
+          "200": {"description": "The updated webhook", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "tags": ["admin"],
+        "summary": "Remove a webhook, its pending deliveries go to the dead letter",
+        "operationId": "deleteWebhook",
+        "parameters": [{"$ref": "#/components/parameters/WebhookID"}],
+        "responses": {
+          "200": {"description": "The webhook was removed"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhooks/{id}/deliveries": {
+      "get": {
+        "tags": ["admin"],
+        "summary": "List the deliveries of a webhook, the newest first",
+        "operationId": "listWebhookDeliveries",
+        "parameters": [
+          {"$ref": "#/components/parameters/WebhookID"},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 50}}
+        ],
+        "responses": {
+          "200": {"description": "The deliveries", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Delivery"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhooks/{id}/deliveries/{deliveryId}:retry": {
+      "post": {
+        "tags": ["admin"],
+        "summary": "Send a dead delivery again, with all its attempts",
+        "operationId": "retryWebhookDelivery",
+        "parameters": [
+          {"$ref": "#/components/parameters/WebhookID"},
+          {"name": "deliveryId", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "202": {"description": "The delivery is pending again", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Delivery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "tags": ["docs"],
+        "summary": "This OpenAPI document",
+        "operationId": "openAPI",
+        "security": [],
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/docs": {
+      "get": {
+        "tags": ["docs"],
+        "summary": "The API reference rendered from this document",
+        "operationId": "docs",
+        "security": [],
+        "responses": {
+          "200": {"description": "The API reference page", "content": {"text/html": {"schema": {"type": "string"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {"type": "http", "scheme": "bearer", "description": "An API key or a JWT issued by the platform"}
+    },
+    "parameters": {
+      "PlanetID": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}},
+      "WebhookID": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}},
+      "IfMatch": {"name": "If-Match", "in": "header", "description": "Applies the change only when the planet still has this ETag, or one of a list of ETags, * applies it to any existing planet", "schema": {"type": "string"}},
+      "IdempotencyKey": {"name": "Idempotency-Key", "in": "header", "description": "Replays the first response of a retried request", "schema": {"type": "string"}},
+      "Fields": {"name": "fields", "in": "query", "description": "The comma separated members of the planets, the others are not read from the database and SWAPI is only asked for numberOfAppearancesOnMovies", "schema": {"type": "string"}, "example": "id,name"},
+      "Expand": {"name": "expand", "in": "query", "description": "films adds the SWAPI films the planets appear on, a request per film", "schema": {"type": "string", "enum": ["films"]}}
+    },
+    "headers": {
+      "ETag": {"description": "The version of the planet", "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {"description": "The request failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {"error": {"type": "string"}}
+      },
+      "Planet": {
+        "type": "object",
+        "required": ["id", "name", "climate", "terrain", "numberOfAppearancesOnMovies"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "climate": {"type": "string"},
+          "terrain": {"type": "string"},
+          "numberOfAppearancesOnMovies": {"type": "integer"},
+          "deletedAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "SearchHit": {
+        "description": "A planet found by the search with its relevance, a higher score is a better match",
+        "allOf": [
+          {"$ref": "#/components/schemas/Planet"},
+          {"type": "object", "required": ["score"], "properties": {"score": {"type": "number"}}}
+        ]
+      },
+      "PlanetView": {
+        "type": "object",
+        "description": "A planet with the members asked with fields, every member when it is absent, and the films asked with expand",
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "climate": {"type": "string"},
+          "terrain": {"type": "string"},
+          "numberOfAppearancesOnMovies": {"type": "integer"},
+          "films": {"type": "array", "items": {"$ref": "#/components/schemas/Film"}}
+        }
+      },
+      "Film": {
+        "type": "object",
+        "required": ["title", "episodeId", "director", "releaseDate"],
+        "properties": {
+          "title": {"type": "string"},
+          "episodeId": {"type": "integer"},
+          "director": {"type": "string"},
+          "releaseDate": {"type": "string", "format": "date"}
+        }
+      },
+      "PlanetInput": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "climate": {"type": "string"},
+          "terrain": {"type": "string"}
+        }
+      },
+      "MergePatch": {
+        "type": "object",
+        "description": "The fields to change, null clears a field",
+        "properties": {
+          "name": {"type": "string", "nullable": true},
+          "climate": {"type": "string", "nullable": true},
+          "terrain": {"type": "string", "nullable": true}
+        }
+      },
+      "JSONPatch": {
+        "type": "array",
+        "items": {
+          "type": "object",
+          "required": ["op", "path"],
+          "properties": {
+            "op": {"type": "string", "enum": ["add", "remove", "replace", "move", "copy", "test"]},
+            "path": {"type": "string"},
+            "from": {"type": "string"},
+            "value": {}
+          }
+        }
+      },
+      "BatchRequest": {
+        "type": "object",
+        "required": ["operations"],
+        "properties": {
+          "ordered": {"type": "boolean", "default": true},
+          "operations": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["op"],
+              "properties": {
+                "op": {"type": "string", "enum": ["create", "update", "delete"]},
+                "id": {"type": "string"},
+                "planet": {"$ref": "#/components/schemas/PlanetInput"}
+              }
+            }
+          }
+        }
+      },
+      "ImportReport": {
+        "type": "object",
+        "required": ["created", "updated", "failed", "dryRun", "errors"],
+        "properties": {
+          "created": {"type": "integer"},
+          "updated": {"type": "integer"},
+          "failed": {"type": "integer"},
+          "dryRun": {"type": "boolean"},
+          "errors": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["row", "error"],
+              "properties": {
+                "row": {"type": "integer", "description": "The position of the planet on the file, starting at 1"},
+                "name": {"type": "string"},
+                "error": {"type": "string"}
+              }
+            }
+          },
+          "errorsTruncated": {"type": "boolean", "description": "Only the first 100 row errors are listed"},
+          "error": {"type": "string", "description": "Why the import stopped"}
+        }
+      },
+      "SyncReport": {
+        "type": "object",
+        "required": ["mode", "created", "updated", "kept", "unchanged", "failed", "changes"],
+        "properties": {
+          "mode": {"type": "string", "enum": ["merge", "overwrite", "report"]},
+          "created": {"type": "integer"},
+          "updated": {"type": "integer"},
+          "kept": {"type": "integer", "description": "The planets whose differences were all kept by merge"},
+          "unchanged": {"type": "integer"},
+          "failed": {"type": "integer"},
+          "changes": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["name", "action"],
+              "properties": {
+                "name": {"type": "string"},
+                "id": {"type": "string"},
+                "action": {"type": "string", "enum": ["create", "update", "keep"]},
+                "fields": {
+                  "type": "array",
+                  "items": {
+                    "type": "object",
+                    "required": ["field", "local", "swapi"],
+                    "properties": {
+                      "field": {"type": "string", "enum": ["name", "climate", "terrain"]},
+                      "local": {"type": "string"},
+                      "swapi": {"type": "string"},
+                      "kept": {"type": "boolean", "description": "merge kept the local value"}
+                    }
+                  }
+                },
+                "error": {"type": "string"}
+              }
+            }
+          },
+          "error": {"type": "string", "description": "Why the sync stopped"}
+        }
+      },
+      "BatchResponse": {
+        "type": "object",
+        "required": ["results"],
+        "properties": {
+          "results": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["op", "status"],
+              "properties": {
+                "op": {"type": "string", "enum": ["create", "update", "delete"]},
+                "id": {"type": "string"},
+                "status": {"type": "integer"},
+                "error": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "AuditEntry": {
+        "type": "object",
+        "required": ["id", "planetId", "action", "actor", "at", "version"],
+        "properties": {
+          "id": {"type": "string"},
+          "planetId": {"type": "string"},
+          "action": {"type": "string", "enum": ["create", "update", "delete", "restore"]},
+          "actor": {
+            "type": "object",
+            "required": ["id"],
+            "properties": {"id": {"type": "string"}, "name": {"type": "string"}}
+          },
+          "requestId": {"type": "string"},
+          "at": {"type": "string", "format": "date-time"},
+          "version": {"type": "integer"},
+          "before": {"allOf": [{"$ref": "#/components/schemas/Planet"}], "nullable": true},
+          "after": {"allOf": [{"$ref": "#/components/schemas/Planet"}], "nullable": true}
+        }
+      },
+      "WebhookInput": {
+        "type": "object",
+        "required": ["url"],
+        "properties": {
+          "url": {"type": "string", "format": "uri"},
+          "events": {"type": "array", "items": {"$ref": "#/components/schemas/EventType"}},
+          "active": {"type": "boolean", "default": true}
+        }
+      },
+      "Webhook": {
+        "type": "object",
+        "required": ["id", "url", "events", "active", "createdAt"],
+        "properties": {
+          "id": {"type": "string"},
+          "url": {"type": "string", "format": "uri"},
+          "events": {"type": "array", "items": {"$ref": "#/components/schemas/EventType"}},
+          "secret": {"type": "string"},
+          "active": {"type": "boolean"},
+          "createdAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "EventType": {"type": "string", "enum": ["planet.created", "planet.updated", "planet.deleted"]},
+      "Delivery": {
+        "type": "object",
+        "required": ["id", "subscriptionId", "eventId", "eventType", "status", "attempts", "nextAttemptAt", "createdAt", "updatedAt"],
+        "properties": {
+          "id": {"type": "string"},
+          "subscriptionId": {"type": "string"},
+          "eventId": {"type": "string"},
+          "eventType": {"$ref": "#/components/schemas/EventType"},
+          "status": {"type": "string", "enum": ["pending", "succeeded", "failed", "dead"]},
+          "attempts": {"type": "integer"},
+          "lastStatusCode": {"type": "integer"},
+          "lastError": {"type": "string"},
+          "nextAttemptAt": {"type": "string", "format": "date-time"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	s := Server{}
+	registered := map[string]bool{}
+	err := s.router().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			registered[method+" "+path] = true
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	documented := map[string]bool{}
+	for _, route := range spec.routes() {
+		documented[route.Method+" "+route.Path] = true
+	}
+	assert.Equal(t, sortedKeys(registered), sortedKeys(documented), "Every route must be described on the OpenAPI document, and only them")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	s := Server{KeyStore: apikey.NewMemoryStore()}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code, "The contract should not require authentication")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var doc map[string]interface{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+}
+
+func TestDocsHandler(t *testing.T) {
+	s := Server{KeyStore: apikey.NewMemoryStore()}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "<code>/planets/{id}</code>")
+	assert.Contains(t, body, `id="patchPlanet"`)
+	assert.Contains(t, body, "MergePatch")
+	assert.Less(t, strings.Index(body, `id="listPlanets"`), strings.Index(body, `id="getPlanet"`), "The routes should be sorted by path")
+}
+
+func TestFindRoute(t *testing.T) {
+	routes := spec.routes()
+	tests := []struct {
+		method string
+		target string
+		path   string
+	}{
+		{http.MethodGet, "/planets", "/planets"},
+		{http.MethodGet, "/planets?name=Hoth", "/planets"},
+		{http.MethodGet, "/planets/events", "/planets/events"},
+		{http.MethodGet, "/planets:deleted", "/planets:deleted"},
+		{http.MethodPost, "/planets/5f0c:restore", "/planets/{id}:restore"},
+		{http.MethodGet, "/planets/5f0c", "/planets/{id}"},
+		{http.MethodPost, "/webhooks/1/deliveries/2:retry", "/webhooks/{id}/deliveries/{deliveryId}:retry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			route, ok := findRoute(routes, httptest.NewRequest(tt.method, tt.target, nil))
+			assert.True(t, ok)
+			assert.Equal(t, tt.path, route.Path)
+		})
+	}
+	_, ok := findRoute(routes, httptest.NewRequest(http.MethodDelete, "/planets", nil))
+	assert.False(t, ok)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	planetSchema := spec.Components.Schemas["Planet"]
+	tests := []struct {
+		name   string
+		schema *schema
+		value  string
+		err    string
+	}{
+		{"Planet", planetSchema, `{"id":"1","name":"Hoth","climate":"","terrain":"","numberOfAppearancesOnMovies":2}`, ""},
+		{"MissingField", planetSchema, `{"id":"1","name":"Hoth","climate":"","terrain":""}`, "body.numberOfAppearancesOnMovies is required"},
+		{"WrongType", planetSchema, `{"id":"1","name":7,"climate":"","terrain":"","numberOfAppearancesOnMovies":2}`, "body.name must be a string"},
+		{"NotInteger", planetSchema, `{"id":"1","name":"","climate":"","terrain":"","numberOfAppearancesOnMovies":2.5}`, "body.numberOfAppearancesOnMovies must be an integer"},
+		{"DateTime", planetSchema, `{"id":"1","name":"","climate":"","terrain":"","numberOfAppearancesOnMovies":0,"deletedAt":"yesterday"}`, "body.deletedAt must be a RFC 3339 date-time"},
+		{"Nullable", spec.Components.Schemas["MergePatch"], `{"terrain":null}`, ""},
+		{"NotNullable", spec.Components.Schemas["PlanetInput"], `{"terrain":null}`, "body.terrain must not be null"},
+		{"Enum", spec.Components.Schemas["JSONPatch"], `[{"op":"rename","path":"/name"}]`, "body[0].op must be one of [add remove replace move copy test]"},
+		{"AnyValue", spec.Components.Schemas["JSONPatch"], `[{"op":"add","path":"/name","value":null}]`, ""},
+		{"NullableRef", spec.Components.Schemas["AuditEntry"], `{"id":"1","planetId":"1","action":"delete","actor":{"id":"k"},"at":"2020-07-13T10:00:00Z","version":2,"after":null}`, ""},
+		{"OneOf", spec.Paths["/planets"]["get"].Responses["200"].Content["application/json"].Schema, `[]`, ""},
+		{"NoneOf", spec.Paths["/planets"]["get"].Responses["200"].Content["application/json"].Schema, `"Hoth"`, "body must match exactly one of its schemas"},
+		{"URI", spec.Components.Schemas["WebhookInput"], `{"url":"/hook"}`, "body.url must be an absolute URI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tt.value), &v))
+			err := tt.schema.validate(v, "body")
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, OpenAPIValidateRequests: true},
+		Webhooks:         webhook.NewMemoryStore(),
+	}
+	h := s.handler()
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"ValidCreate", http.MethodPost, "/planets", "application/json", `{"name":"Hoth"}`, http.StatusCreated},
+		{"CreateWithoutContentType", http.MethodPost, "/planets", "", `{"name":"Hoth"}`, http.StatusCreated},
+		{"CreateWithWrongType", http.MethodPost, "/planets", "application/json", `{"name":42}`, http.StatusBadRequest},
+		{"CreateWithoutBody", http.MethodPost, "/planets", "application/json", ``, http.StatusBadRequest},
+		{"CreateWithText", http.MethodPost, "/planets", "text/plain", `Hoth`, http.StatusUnsupportedMediaType},
+		{"BatchWithoutOperations", http.MethodPost, "/planets:batch", "application/json", `{"ordered":true}`, http.StatusBadRequest},
+		{"BatchWithUnknownOp", http.MethodPost, "/planets:batch", "application/json", `{"operations":[{"op":"rename"}]}`, http.StatusBadRequest},
+		{"ValidMergePatch", http.MethodPatch, "/planets/1", "application/merge-patch+json", `{"climate":null}`, http.StatusOK},
+		{"JSONPatchWithoutPath", http.MethodPatch, "/planets/1", "application/json-patch+json", `[{"op":"remove"}]`, http.StatusBadRequest},
+		{"WebhookWithoutURL", http.MethodPost, "/webhooks", "application/json", `{"events":[]}`, http.StatusBadRequest},
+		{"DeliveriesWithInvalidLimit", http.MethodGet, "/webhooks/1/deliveries?limit=abc", "", ``, http.StatusBadRequest},
+		{"DeliveriesWithLimitOutOfRange", http.MethodGet, "/webhooks/1/deliveries?limit=501", "", ``, http.StatusBadRequest},
+		{"StreamedImport", http.MethodPost, "/planets:import?dryRun=true", "text/csv", "name\nHoth\n", http.StatusOK},
+		{"ImportWithInvalidDryRun", http.MethodPost, "/planets:import?dryRun=maybe", "text/csv", "name\nHoth\n", http.StatusBadRequest},
+		{"ImportWithText", http.MethodPost, "/planets:import", "text/plain", `Hoth`, http.StatusUnsupportedMediaType},
+		{"UndocumentedRoute", http.MethodGet, "/unknown", "", ``, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	store := audit.NewMemoryStore()
+	webhooks := webhook.NewMemoryStore()
+	s := Server{
+		PlanetRepository: audit.NewRepository(newRepositoryMock(), store),
+		CountRetriever:   staticCounterMock{},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, OpenAPIValidateRequests: true, OpenAPIValidateResponses: true},
+		AuditLog:         store,
+		Webhooks:         webhooks,
+	}
+	h := s.handler()
+	do := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.NotEqual(t, http.StatusInternalServerError, rec.Code, method+" "+target+": "+rec.Body.String())
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/planets", "application/json", `{"name":"Hoth","climate":"frozen"}`).Code)
+	assert.Equal(t, "\"1\"", do(http.MethodGet, "/planets/1", "", "").Header().Get("ETag"))
+	do(http.MethodGet, "/planets", "", "")
+	do(http.MethodGet, "/planets?name=Hoth", "", "")
+	do(http.MethodGet, "/planets/42", "", "")
+	do(http.MethodPut, "/planets/1", "application/json", `{"name":"Hoth","climate":"cold"}`)
+	do(http.MethodPatch, "/planets/1", "application/merge-patch+json", `{"terrain":"ice"}`)
+	do(http.MethodPost, "/planets:batch", "application/json", `{"operations":[{"op":"create","planet":{"name":"Endor"}}]}`)
+	do(http.MethodDelete, "/planets/1", "", "")
+	do(http.MethodGet, "/planets:deleted", "", "")
+	do(http.MethodGet, "/planets/1/history", "", "")
+	do(http.MethodPost, "/planets/1:restore", "", "")
+	created := do(http.MethodPost, "/webhooks", "application/json", `{"url":"https://example.com/hook"}`)
+	var sub webhook.Subscription
+	json.NewDecoder(created.Body).Decode(&sub)
+	do(http.MethodGet, "/webhooks", "", "")
+	do(http.MethodGet, "/webhooks/"+sub.ID, "", "")
+	do(http.MethodGet, "/webhooks/"+sub.ID+"/deliveries", "", "")
+	do(http.MethodGet, "/openapi.json", "", "")
+	do(http.MethodGet, "/docs", "", "")
+}
+
+func TestValidateResponsesRefusesDrift(t *testing.T) {
+	op := spec.Paths["/planets/{id}"]["get"]
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		valid       bool
+	}{
+		{"Documented", http.StatusOK, "application/json", `{"id":"1","name":"Hoth","climate":"","terrain":"","numberOfAppearancesOnMovies":0}`, true},
+		{"NotModified", http.StatusNotModified, "", ``, true},
+		{"Error", http.StatusNotFound, "application/json", `{"error":"not found"}`, true},
+		{"WrongBody", http.StatusOK, "application/json", `{"id":1}`, false},
+		{"WrongErrorBody", http.StatusBadRequest, "application/json", `{"message":"bad"}`, false},
+		{"WrongContentType", http.StatusOK, "text/plain", `Hoth`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &bufferedResponse{header: http.Header{}, status: tt.status}
+			resp.header.Set("Content-Type", tt.contentType)
+			resp.body.WriteString(tt.body)
+
+			err := validateResponse(op, resp)
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxValidatedBody bounds the request bodies read by the validation
+const maxValidatedBody = 10 << 20
+
+// schema is the subset of the OpenAPI schema object used by the document
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	Enum       []interface{}      `json:"enum"`
+	Properties map[string]*schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *schema            `json:"items"`
+	AllOf      []*schema          `json:"allOf"`
+	OneOf      []*schema          `json:"oneOf"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+
+	resolved *schema
+}
+
+// resolve links the references of the schema and its children to the component schemas
+func (s *schema) resolve(components map[string]*schema) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		s.resolved = components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if s.resolved == nil {
+			panic("the OpenAPI document references the unknown schema " + s.Ref)
+		}
+		return
+	}
+	for _, p := range s.Properties {
+		p.resolve(components)
+	}
+	s.Items.resolve(components)
+	for _, sub := range s.AllOf {
+		sub.resolve(components)
+	}
+	for _, sub := range s.OneOf {
+		sub.resolve(components)
+	}
+}
+
+// validate checks the decoded JSON value against the schema, path names the value on the error
+func (s *schema) validate(v interface{}, path string) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return s.resolved.validate(v, path)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0 && len(s.OneOf) == 0) {
+			return nil
+		}
+		return errors.Errorf("%s must not be null", path)
+	}
+	for _, sub := range s.AllOf {
+		if err := sub.validate(v, path); err != nil {
+			return err
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if sub.validate(v, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return errors.Errorf("%s must match exactly one of its schemas", path)
+		}
+	}
+	if err := s.validateType(v, path); err != nil {
+		return err
+	}
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if e == v {
+				return nil
+			}
+		}
+		return errors.Errorf("%s must be one of %v", path, s.Enum)
+	}
+	return nil
+}
+
+func (s *schema) validateType(v interface{}, path string) error {
+	switch s.Type {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return errors.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, value := range object {
+			if err := s.Properties[name].validate(value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := v.([]interface{})
+		if !ok {
+			return errors.Errorf("%s must be an array", path)
+		}
+		for i, item := range array {
+			if err := s.Items.validate(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return errors.Errorf("%s must be a string", path)
+		}
+		return s.validateFormat(str, path)
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return errors.Errorf("%s must be an %s", path, s.Type)
+		}
+		if (s.Minimum != nil && n < *s.Minimum) || (s.Maximum != nil && n > *s.Maximum) {
+			return errors.Errorf("%s is out of range", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return errors.Errorf("%s must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+func (s *schema) validateFormat(str, path string) error {
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			return errors.Errorf("%s must be a RFC 3339 date-time", path)
+		}
+	case "uri":
+		if u, err := url.Parse(str); err != nil || !u.IsAbs() {
+			return errors.Errorf("%s must be an absolute URI", path)
+		}
+	}
+	return nil
+}
+
+// validateOpenAPI checks the requests, and the responses when enabled, against the OpenAPI document.
+// A request which does not match is refused with 400 or 415, a response which does not match is replaced by a 500,
+// the response validation is meant to run on the tests and the development environments.
+func (s *Server) validateOpenAPI(h http.Handler) http.Handler {
+	if !s.Cfg.OpenAPIValidateRequests && !s.Cfg.OpenAPIValidateResponses {
+		return h
+	}
+	routes := spec.routes()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := findRoute(routes, r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if s.Cfg.OpenAPIValidateRequests {
+			if status, err := validateRequest(route.Operation, r); err != nil {
+				handleError(w, status, "The request does not match the API specification: "+err.Error())
+				return
+			}
+		}
+		if !s.Cfg.OpenAPIValidateResponses || streams(route.Operation) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		h.ServeHTTP(buffered, r)
+		if err := validateResponse(route.Operation, buffered); err != nil {
+			log.Println("The response of", route.Method, route.Path, "does not match the API specification:", err)
+			handleError(w, http.StatusInternalServerError, "The response does not match the API specification: "+err.Error())
+			return
+		}
+		buffered.writeTo(w)
+	})
+}
+
+// validateRequest checks the parameters and the body of the request, it returns the status of the refusal
+func validateRequest(op *openAPIOperation, r *http.Request) (int, error) {
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "query":
+			_, present = query[p.Name]
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				return http.StatusBadRequest, errors.Errorf("the %s parameter %s is required", p.In, p.Name)
+			}
+			continue
+		}
+		if err := validateParameter(p, value); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return 0, nil
+	}
+	if op.RequestBody.Stream {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if _, ok := op.RequestBody.Content[mediaType]; !ok && r.URL.Query().Get("format") == "" {
+			return http.StatusUnsupportedMediaType, errors.Errorf("the body can not be %q", mediaType)
+		}
+		return 0, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil {
+		return http.StatusBadRequest, errors.Wrap(err, "the body can not be read")
+	}
+	if len(body) > maxValidatedBody {
+		return http.StatusRequestEntityTooLarge, errors.New("the body is too large")
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			return http.StatusBadRequest, errors.New("the body is required")
+		}
+		return 0, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "" && len(op.RequestBody.Content) == 1 {
+		// the clients which send no Content-Type are served as before the validation
+		for t := range op.RequestBody.Content {
+			mediaType = t
+		}
+	}
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		// the other formats of a JSON body are checked against its schema once converted to JSON
+		c := codecOf(mediaType)
+		if content, ok = op.RequestBody.Content[codecs[0].contentType]; !ok || c == nil || c.toJSON == nil {
+			return http.StatusUnsupportedMediaType, errors.Errorf("the body can not be %q", mediaType)
+		}
+		if body, err = c.toJSON(body); err != nil {
+			return http.StatusBadRequest, errors.Errorf("the body is not valid %s", mediaType)
+		}
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return http.StatusBadRequest, errors.New("the body is not valid JSON")
+	}
+	if err := content.Schema.validate(v, "body"); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return 0, nil
+}
+
+// validateParameter checks a query or header value, which is a string on the wire
+func validateParameter(p *openAPIParameter, value string) error {
+	var v interface{} = value
+	if p.Schema != nil && (p.Schema.Type == "integer" || p.Schema.Type == "number") {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.Errorf("the %s parameter %s must be an %s", p.In, p.Name, p.Schema.Type)
+		}
+		v = n
+	}
+	if p.Schema != nil && p.Schema.Type == "boolean" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("the %s parameter %s must be a boolean", p.In, p.Name)
+		}
+		v = b
+	}
+	return p.Schema.validate(v, p.Name)
+}
+
+// validateResponse checks the status and the JSON body of the response
+func validateResponse(op *openAPIOperation, resp *bufferedResponse) error {
+	documented, ok := op.Responses[strconv.Itoa(resp.status)]
+	if !ok {
+		documented, ok = op.Responses["default"]
+	}
+	if !ok {
+		return errors.Errorf("the status %d is not documented", resp.status)
+	}
+	if len(documented.Content) == 0 || resp.body.Len() == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.header.Get("Content-Type"))
+	body := resp.body.Bytes()
+	content, ok := documented.Content[mediaType]
+	if !ok {
+		// the other formats of a JSON response are checked against its schema once converted to JSON,
+		// the CSV can not be converted back
+		c := codecOf(mediaType)
+		if content, ok = documented.Content[codecs[0].contentType]; !ok || c == nil {
+			return errors.Errorf("the content type %q is not documented", mediaType)
+		}
+		if c.toJSON == nil {
+			return nil
+		}
+		var err error
+		if body, err = c.toJSON(body); err != nil {
+			return errors.Errorf("the body is not valid %s", mediaType)
+		}
+		mediaType = codecs[0].contentType
+	}
+	if !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return errors.New("the body is not valid JSON")
+	}
+	return content.Schema.validate(v, "body")
+}
+
+// streams reports whether the operation streams its response, which can not be buffered
+func streams(op *openAPIOperation) bool {
+	for _, resp := range op.Responses {
+		if _, ok := resp.Content["text/event-stream"]; ok || resp.Stream {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse keeps the whole response so it can be validated before it is sent
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status = status
+		b.wrote = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	if _, err := w.Write(b.body.Bytes()); err != nil {
+		log.Println("Error to write the response", err)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPlanetsLimit = 100
+	maxPlanetsLimit     = 500
+)
+
+// planetsPage is the page of the planets asked with the limit and after query parameters
+type planetsPage struct {
+	After string
+	Limit int
+}
+
+// pageOf reads the page asked by the request, ok is false when the request asks the whole list
+func pageOf(r *http.Request) (page planetsPage, ok bool, err error) {
+	query := r.URL.Query()
+	_, hasLimit := query["limit"]
+	_, hasAfter := query["after"]
+	if !hasLimit && !hasAfter {
+		return planetsPage{}, false, nil
+	}
+	page = planetsPage{After: query.Get("after"), Limit: defaultPlanetsLimit}
+	if hasLimit {
+		n, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || n < 1 || n > maxPlanetsLimit {
+			return planetsPage{}, false, errInvalidLimit
+		}
+		page.Limit = n
+	}
+	return page, true, nil
+}
+
+// nextLink is the Link header value which points to the page after the last planet
+func nextLink(r *http.Request, page planetsPage, lastID string) string {
+	query := r.URL.Query()
+	query.Set("after", lastID)
+	query.Set("limit", strconv.Itoa(page.Limit))
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return "<" + next.String() + `>; rel="next"`
+}
+
+var errInvalidLimit = errors.Errorf("The limit must be between 1 and %d", maxPlanetsLimit)
+
+// listPlanetPageHandler serves a page of the planets, a full page links to the next one
+func (s *Server) listPlanetPageHandler(w http.ResponseWriter, r *http.Request, page planetsPage, view planetView) {
+	ctx := r.Context()
+	planets, err := s.PlanetRepository.FindPage(ctx, page.After, page.Limit, view.repositoryFields()...)
+	if err == nil {
+		planets, err = s.fillAll(ctx, planets, view)
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the planets", err)
+		return
+	}
+	if len(planets) == page.Limit {
+		w.Header().Set("Link", nextLink(r, page, planets[len(planets)-1].ID))
+	}
+	writeResponse(w, r, http.StatusOK, view.render(planets))
+}
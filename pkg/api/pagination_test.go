@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPaginationServer() http.Handler {
+	s, _ := newTestServer(config.Config{OpenAPIValidateResponses: true},
+		planet.Planet{Name: "Tatooine"},
+		planet.Planet{Name: "Alderaan"},
+		planet.Planet{Name: "Hoth"},
+	)
+	return s.handler()
+}
+
+func TestListPlanetsPages(t *testing.T) {
+	h := newPaginationServer()
+
+	rec := doRequest(h, http.MethodGet, "/planets?limit=2", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page []planet.Planet
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Len(t, page, 2)
+	assert.Equal(t, "Tatooine", page[0].Name)
+	assert.Equal(t, 5, page[0].NumberOfAppearancesOnMovies)
+	assert.Equal(t, "Alderaan", page[1].Name)
+	assert.Equal(t, `</planets?after=2&limit=2>; rel="next"`, rec.Header().Get("Link"))
+
+	rec = doRequest(h, http.MethodGet, "/planets?after=2&limit=2", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page = nil
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Len(t, page, 1)
+	assert.Equal(t, "Hoth", page[0].Name)
+	assert.Empty(t, rec.Header().Get("Link"), "The last page should not link to another")
+}
+
+func TestListPlanetsPageWithTheDefaultLimit(t *testing.T) {
+	rec := doRequest(newPaginationServer(), http.MethodGet, "/planets?after=1", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page []planet.Planet
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Len(t, page, 2)
+	assert.Empty(t, rec.Header().Get("Link"))
+}
+
+func TestListPlanetsWithoutAPageReturnsEveryPlanet(t *testing.T) {
+	rec := doRequest(newPaginationServer(), http.MethodGet, "/planets", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var planets []planet.Planet
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&planets))
+	assert.Len(t, planets, 3)
+	assert.Empty(t, rec.Header().Get("Link"))
+}
+
+func TestListPlanetsWithAnInvalidLimit(t *testing.T) {
+	for _, limit := range []string{"0", "501", "ten"} {
+		rec := doRequest(newPaginationServer(), http.MethodGet, "/planets?limit="+limit, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, limit)
+		assert.Contains(t, rec.Body.String(), "The limit must be between 1 and 500", limit)
+	}
+}
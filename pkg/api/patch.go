@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// errUnprocessablePatch marks a well formed patch which can not be applied to the planet
+var errUnprocessablePatch = errors.New("The patch can not be applied to the planet")
+
+// patchableFields are the JSON members of a planet which a patch may change
+var patchableFields = map[string]bool{"name": true, "climate": true, "terrain": true}
+
+func (s *Server) patchPlanetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	version, ok := s.ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	var update planet.Update
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchContentType:
+		update, err = mergePatchUpdate(r.Body)
+	case jsonPatchContentType:
+		var current planet.Planet
+		current, err = s.PlanetRepository.FindByID(ctx, id)
+		if err != nil {
+			handleContextError(ctx, w, http.StatusNotFound, "Error retriving the planet to patch", err)
+			return
+		}
+		if version != 0 && version != current.Version {
+			handleError(w, http.StatusPreconditionFailed, repository.ErrVersionMismatch.Error())
+			return
+		}
+		// the operations were checked against this version, so the patch must not apply over a newer one
+		version = current.Version
+		update, err = jsonPatchUpdate(r.Body, current)
+	default:
+		w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		handleError(w, http.StatusUnsupportedMediaType, "PATCH accepts "+mergePatchContentType+" or "+jsonPatchContentType)
+		return
+	}
+	if errors.Cause(err) == errUnprocessablePatch {
+		handleError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println("Error Decoding the patch", err)
+		handleError(w, http.StatusBadRequest, "Patch JSON is Invalid")
+		return
+	}
+
+	update.Version = version
+	patched, err := s.PlanetRepository.Patch(ctx, id, update)
+	if handleVersionError(w, err) {
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error patching the planet", err)
+		return
+	}
+	w.Header().Set("ETag", etag(patched.Version))
+	writeResponse(w, r, http.StatusOK, patched)
+}
+
+// mergePatchUpdate reads a JSON Merge Patch (RFC 7386), a null member clears the field
+func mergePatchUpdate(body io.Reader) (planet.Update, error) {
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&members); err != nil {
+		return planet.Update{}, err
+	}
+	var update planet.Update
+	for name, raw := range members {
+		if !patchableFields[name] {
+			return planet.Update{}, errors.Wrapf(errUnprocessablePatch, "the %s member can not be patched", name)
+		}
+		var value *string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return planet.Update{}, errors.Wrapf(errUnprocessablePatch, "the %s member must be a string or null", name)
+		}
+		if value == nil {
+			empty := ""
+			value = &empty
+		}
+		setField(&update, name, *value)
+	}
+	return update, nil
+}
+
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// jsonPatchUpdate applies a JSON Patch (RFC 6902) to the current planet and returns the fields it changed,
+// all operations are applied or none is
+func jsonPatchUpdate(body io.Reader, current planet.Planet) (planet.Update, error) {
+	var operations []jsonPatchOperation
+	if err := json.NewDecoder(body).Decode(&operations); err != nil {
+		return planet.Update{}, err
+	}
+	doc := map[string]string{"name": current.Name, "climate": current.Climate, "terrain": current.Terrain}
+	for i, op := range operations {
+		if err := applyOperation(doc, op); err != nil {
+			return planet.Update{}, errors.Wrapf(err, "operation %d", i)
+		}
+	}
+
+	var update planet.Update
+	original := map[string]string{"name": current.Name, "climate": current.Climate, "terrain": current.Terrain}
+	for name, value := range doc {
+		if value != original[name] {
+			setField(&update, name, value)
+		}
+	}
+	return update, nil
+}
+
+func applyOperation(doc map[string]string, op jsonPatchOperation) error {
+	field, err := pointerField(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return errors.Errorf("the %s operation requires a value", op.Op)
+		}
+		var value string
+		if err := json.Unmarshal(*op.Value, &value); err != nil {
+			return errors.Wrapf(errUnprocessablePatch, "%s must be a string", op.Path)
+		}
+		if op.Op == "test" {
+			if doc[field] != value {
+				return errors.Wrapf(errUnprocessablePatch, "test failed for %s", op.Path)
+			}
+			return nil
+		}
+		doc[field] = value
+	case "remove":
+		doc[field] = ""
+	case "copy", "move":
+		from, err := pointerField(op.From)
+		if err != nil {
+			return err
+		}
+		doc[field] = doc[from]
+		if op.Op == "move" && from != field {
+			doc[from] = ""
+		}
+	default:
+		return errors.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// pointerField resolves the JSON Pointer of a patchable planet field
+func pointerField(pointer string) (string, error) {
+	if len(pointer) < 2 || pointer[0] != '/' || !patchableFields[pointer[1:]] {
+		return "", errors.Wrapf(errUnprocessablePatch, "the path %q can not be patched", pointer)
+	}
+	return pointer[1:], nil
+}
+
+func setField(u *planet.Update, name, value string) {
+	switch name {
+	case "name":
+		u.Name = &value
+	case "climate":
+		u.Climate = &value
+	case "terrain":
+		u.Terrain = &value
+	}
+}
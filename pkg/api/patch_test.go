@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchPlanet(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		expected    planet.Planet
+	}{
+		{
+			name:        "MergePatchSetsAndClearsFields",
+			contentType: "application/merge-patch+json",
+			body:        `{"climate": "frozen", "terrain": null}`,
+			status:      http.StatusOK,
+			expected:    planet.Planet{ID: "1", Name: "Hoth", Climate: "frozen", Terrain: ""},
+		},
+		{
+			name:        "MergePatchWithReadOnlyMember",
+			contentType: "application/merge-patch+json",
+			body:        `{"numberOfAppearancesOnMovies": 3}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "JSONPatch",
+			contentType: "application/json-patch+json; charset=utf-8",
+			body: `[
+				{"op": "test", "path": "/name", "value": "Hoth"},
+				{"op": "copy", "from": "/climate", "path": "/terrain"},
+				{"op": "replace", "path": "/climate", "value": "frozen"}
+			]`,
+			status:   http.StatusOK,
+			expected: planet.Planet{ID: "1", Name: "Hoth", Climate: "frozen", Terrain: "cold"},
+		},
+		{
+			name:        "JSONPatchWithFailedTest",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "replace", "path": "/climate", "value": "frozen"}, {"op": "test", "path": "/name", "value": "Tatooine"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "JSONPatchWithUnknownPath",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "remove", "path": "/id"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "InvalidJSON",
+			contentType: "application/merge-patch+json",
+			body:        `{`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "UnsupportedContentType",
+			contentType: "application/json",
+			body:        `{"climate": "frozen"}`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Hoth", Climate: "cold", Terrain: "tundra"})
+			s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+			req := httptest.NewRequest(http.MethodPatch, "/planets/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			stored, _ := repo.FindByID(req.Context(), "1")
+			if tt.status != http.StatusOK {
+				assert.Equal(t, "cold", stored.Climate, "A rejected patch should change nothing")
+				return
+			}
+			var response planet.Planet
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			assert.Equal(t, tt.expected, response)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+			assert.Equal(t, int64(2), stored.Version, "The patch should increment the version")
+			stored.Version = 0
+			assert.Equal(t, tt.expected, stored)
+		})
+	}
+}
+
+func TestPatchUnknownPlanet(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	req := httptest.NewRequest(http.MethodPatch, "/planets/42", strings.NewReader(`{"climate": "frozen"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
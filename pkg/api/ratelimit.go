@@ -0,0 +1,64 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/ratelimit"
+)
+
+// rateLimit applies the limiter to the handler, the buckets are keyed by the authenticated principal or by the client IP,
+// nothing is limited when the limiter is nil
+func rateLimit(l *ratelimit.Limiter, h http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := l.Allow(rateLimitKey(r))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		if !result.Allowed {
+			tooManyRequests(w, result)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// tooManyRequests refuses the request until the bucket has a token again
+func tooManyRequests(w http.ResponseWriter, result ratelimit.Result) {
+	w.Header().Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+	handleError(w, http.StatusTooManyRequests, "Rate limit exceeded, retry in "+strconv.Itoa(seconds(result.RetryAfter))+" seconds")
+}
+
+// newLimiter creates a limiter for the configured rate, a non positive rate disables the limit
+func newLimiter(rate float64, burst int) *ratelimit.Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return ratelimit.New(rate, burst)
+}
+
+func rateLimitKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.ID
+	}
+	return clientIPKey(r)
+}
+
+func clientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPerAPIKey(t *testing.T) {
+	first, firstToken, _ := apikey.Generate("first", []string{auth.ScopePlanetsRead})
+	second, secondToken, _ := apikey.Generate("second", []string{auth.ScopePlanetsRead})
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		KeyStore:         apikey.NewMemoryStore(first, second),
+		Cfg:              config.Config{RateLimitReadRPS: 0.1, RateLimitReadBurst: 2},
+	}
+	h := s.handler()
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/planets", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(firstToken)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, get(firstToken).Code)
+
+	rec = get(firstToken)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.NotEmpty(t, body["error"])
+
+	assert.Equal(t, http.StatusOK, get(secondToken).Code)
+}
+
+func TestRateLimitSeparatesReadsAndWritesPerIP(t *testing.T) {
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		Cfg: config.Config{
+			AllowInsecureNoAuth: true,
+			RateLimitReadRPS:    0.1,
+			RateLimitReadBurst:  1,
+			RateLimitWriteRPS:   0.1,
+			RateLimitWriteBurst: 1,
+		},
+	}
+	h := s.handler()
+	do := func(method, remoteAddr string) int {
+		req := httptest.NewRequest(method, "/planets", strings.NewReader(`{"name":"Hoth"}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "10.0.0.1:4321"))
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "10.0.0.1:4321"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "10.0.0.2:1234"))
+}
+
+// countingKeyStore counts the lookups which reach the API key store
+type countingKeyStore struct {
+	apikey.Store
+	lookups int
+}
+
+func (s *countingKeyStore) FindByHash(ctx context.Context, hash string) (apikey.Key, error) {
+	s.lookups++
+	return s.Store.FindByHash(ctx, hash)
+}
+
+func TestRateLimitFailedAuthenticationsPerIP(t *testing.T) {
+	reader, token, _ := apikey.Generate("reader", []string{auth.ScopePlanetsRead})
+	store := &countingKeyStore{Store: apikey.NewMemoryStore(reader)}
+	s := Server{
+		PlanetRepository: newRepositoryMock(planet.Planet{Name: "Tatooine"}),
+		CountRetriever:   staticCounterMock{},
+		KeyStore:         store,
+		Cfg:              config.Config{RateLimitAuthFailureRPS: 0.1, RateLimitAuthFailureBurst: 2},
+	}
+	h := s.handler()
+	get := func(token, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/planets", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, get(token, "10.0.0.1:1234").Code, "A valid key should not spend the failures")
+	assert.Equal(t, http.StatusUnauthorized, get("", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("wrong", "10.0.0.1:1234").Code)
+	assert.Equal(t, 2, store.lookups)
+
+	rec := get("wrong", "10.0.0.1:4321")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, get(token, "10.0.0.1:1234").Code)
+	assert.Equal(t, 2, store.lookups, "The refused requests should not reach the key store")
+
+	assert.Equal(t, http.StatusUnauthorized, get("wrong", "10.0.0.2:1234").Code)
+}
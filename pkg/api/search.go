@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var errInvalidSearchLimit = errors.Errorf("The limit must be between 1 and %d", maxSearchLimit)
+
+// searchHit is a planet found by the search with the relevance the results are ordered by
+type searchHit struct {
+	planet.Planet
+	Score float64 `json:"score"`
+}
+
+// searchPlanetsHandler ranks the planets whose name, climate or terrain words match the q query parameter
+func (s *Server) searchPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		handleError(w, http.StatusBadRequest, "The q query parameter is required")
+		return
+	}
+	limit := defaultSearchLimit
+	if _, ok := query["limit"]; ok {
+		n, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || n < 1 || n > maxSearchLimit {
+			handleError(w, http.StatusBadRequest, errInvalidSearchLimit.Error())
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+	results, err := s.PlanetRepository.Search(ctx, q, limit)
+	planets := make([]planet.Planet, len(results))
+	for i, result := range results {
+		planets[i] = result.Planet
+	}
+	if err == nil {
+		planets, err = s.fillAll(ctx, planets, planetView{})
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error searching the planets", err)
+		return
+	}
+	hits := make([]searchHit, len(planets))
+	for i, p := range planets {
+		hits[i] = searchHit{Planet: p, Score: results[i].Score}
+	}
+	writeResponse(w, r, http.StatusOK, hits)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSearchServer() *Server {
+	s, _ := newTestServer(config.Config{OpenAPIValidateRequests: true, OpenAPIValidateResponses: true},
+		planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+		planet.Planet{Name: "Kashyyyk", Climate: "tropical", Terrain: "jungle, forests"},
+		planet.Planet{Name: "Jungle", Climate: "humid", Terrain: "rainforests"},
+	)
+	return s
+}
+
+func TestSearchPlanets(t *testing.T) {
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/planets/search?q=tatooine", `[{"id":"1","name":"Tatooine","climate":"arid","terrain":"desert","numberOfAppearancesOnMovies":5,"score":10}]`},
+		{"/planets/search?q=Jungle", `[
+			{"id":"3","name":"Jungle","climate":"humid","terrain":"rainforests","numberOfAppearancesOnMovies":0,"score":10},
+			{"id":"2","name":"Kashyyyk","climate":"tropical","terrain":"jungle, forests","numberOfAppearancesOnMovies":0,"score":1}
+		]`},
+		{"/planets/search?q=jungle&limit=1", `[{"id":"3","name":"Jungle","climate":"humid","terrain":"rainforests","numberOfAppearancesOnMovies":0,"score":10}]`},
+		{"/planets/search?q=Tatoóine", `[{"id":"1","name":"Tatooine","climate":"arid","terrain":"desert","numberOfAppearancesOnMovies":5,"score":10}]`},
+		{"/planets/search?q=coruscant", `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newSearchServer().handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.JSONEq(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestSearchPlanetsWithATypo(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newSearchServer().handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/planets/search?q=kashyyk", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"name":"Kashyyyk"`)
+}
+
+func TestSearchPlanetsBadRequests(t *testing.T) {
+	for _, target := range []string{
+		"/planets/search",
+		"/planets/search?q=%20",
+		"/planets/search?q=hoth&limit=0",
+		"/planets/search?q=hoth&limit=101",
+	} {
+		s := newSearchServer()
+		s.Cfg.OpenAPIValidateRequests = false
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
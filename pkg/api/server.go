@@ -1,13 +1,23 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/auth/jwt"
 	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/idempotency"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
 	"github.com/rafaelreinert/stars/pkg/planet/repository"
 	"github.com/rafaelreinert/stars/pkg/planet/retriever"
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+	"github.com/rafaelreinert/stars/pkg/ratelimit"
+	"github.com/rafaelreinert/stars/pkg/tlsreload"
+	"github.com/rafaelreinert/stars/pkg/webhook"
 )
 
 // Server is the struct which  initializes and control the HTTP server and all API handles
@@ -15,10 +25,49 @@ type Server struct {
 	PlanetRepository repository.PlanetRepository
 	CountRetriever   retriever.PlanetAppearancesOnMoviesCounter
 	Cfg              config.Config
+	// KeyStore authenticates the API keys, every protected route is refused when it is nil
+	// unless Cfg.AllowInsecureNoAuth is set
+	KeyStore apikey.Store
+	// TokenVerifier validates the JWTs issued by the platform, JWTs are not accepted when it is nil
+	TokenVerifier *jwt.Verifier
+	// IdempotencyStore keeps the responses of the POST requests sent with an Idempotency-Key, the header is ignored when it is nil
+	IdempotencyStore idempotency.Store
+	// AuditLog serves the planet history, it must be the store of the audit.Repository decorating PlanetRepository
+	AuditLog audit.Store
+	// Events streams the changes published by the event.Repository decorating PlanetRepository
+	Events *event.Bus
+	// Webhooks keeps the webhook subscriptions and their delivery log, the webhook routes answer 404 when it is nil
+	Webhooks webhook.Store
+	// PlanetSource lists the SWAPI planets copied by the sync, the sync route answers 404 when it is nil
+	PlanetSource swapisync.Source
+	// FilmsLister lists the SWAPI films of the planets asked with expand=films, which answers 400 when it is nil
+	FilmsLister retriever.PlanetFilmsLister
+
+	readLimiter        *ratelimit.Limiter
+	writeLimiter       *ratelimit.Limiter
+	authFailureLimiter *ratelimit.Limiter
+}
+
+// Handler returns the API handler, so the API can be served by another server, like the httptest one
+func (s *Server) Handler() http.Handler {
+	return s.handler()
 }
 
-// ListenAndServe starts an HTTP server with API handler loaded, it uses PORT env variable or port 8080
+// ListenAndServe starts an HTTP server with API handler loaded, it uses PORT env variable or port 8080,
+// the server speaks HTTPS when a TLS certificate is configured
 func (s *Server) ListenAndServe() {
-	log.Println("Listening ", fmt.Sprintf(":%d", s.Cfg.Port))
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", s.Cfg.Port), s.handler()))
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", s.Cfg.Port), Handler: s.handler()}
+	if !s.Cfg.TLSEnabled() {
+		log.Println("Listening ", srv.Addr)
+		log.Fatal(srv.ListenAndServe())
+	}
+
+	reloader, err := tlsreload.New(s.Cfg.TLSCertFile, s.Cfg.TLSKeyFile, s.Cfg.TLSClientCAFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go reloader.Watch(context.Background(), s.Cfg.TLSReloadInterval)
+	srv.TLSConfig = reloader.ServerConfig(tlsreload.Versions[s.Cfg.TLSMinVersion], tlsreload.ClientAuthTypes[s.Cfg.TLSClientAuth])
+	log.Println("Listening with TLS ", srv.Addr)
+	log.Fatal(srv.ListenAndServeTLS("", ""))
 }
@@ -32,7 +32,7 @@ func TestServer(t *testing.T) {
 	s := Server{
 		PlanetRepository: mongorep.NewMongoRepository(mongoClient.Database("starwars")),
 		CountRetriever:   swapi.SWAPI{APIURL: swapiServer.URL},
-		Cfg:              config.Config{Port: 8080},
+		Cfg:              config.Config{Port: 8080, AllowInsecureNoAuth: true},
 	}
 
 	go s.ListenAndServe()
@@ -235,7 +235,8 @@ func initMongoClient() (*mongo.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	err = client.Connect(ctx)
 	if err != nil {
 		return nil, err
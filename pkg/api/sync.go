@@ -0,0 +1,43 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+)
+
+// syncResponse is the body of POST /planets:sync, Error tells why the sync stopped before the last SWAPI planet
+type syncResponse struct {
+	swapisync.Report
+	Error string `json:"error,omitempty"`
+}
+
+// syncPlanetsHandler creates and updates the local planets out of the SWAPI catalogue, merge is the default mode
+func (s *Server) syncPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.PlanetSource == nil {
+		handleError(w, http.StatusNotFound, "The SWAPI sync is not enabled")
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = swapisync.ModeMerge
+	}
+	if !swapisync.ValidMode(mode) {
+		handleError(w, http.StatusBadRequest, "The mode must be merge, overwrite or report")
+		return
+	}
+
+	report, err := swapisync.Sync(ctx, s.PlanetRepository, s.PlanetSource, mode)
+	if err != nil && ctx.Err() != nil {
+		handleContextError(ctx, w, http.StatusBadGateway, "Error syncing the planets", err)
+		return
+	}
+	if err != nil {
+		log.Println("Error syncing the planets", err)
+		writeResponse(w, r, http.StatusBadGateway, syncResponse{Report: report, Error: err.Error()})
+		return
+	}
+	writeResponse(w, r, http.StatusOK, syncResponse{Report: report})
+}
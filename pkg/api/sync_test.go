@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+	"github.com/rafaelreinert/stars/pkg/swapi"
+	"github.com/rafaelreinert/stars/pkg/swapi/swapitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncPlanets(t *testing.T) {
+	fake := swapitest.NewServer(
+		planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+		planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"},
+	)
+	defer fake.Close()
+
+	tests := []struct {
+		target  string
+		report  swapisync.Report
+		planets int
+	}{
+		{"/planets:sync", swapisync.Report{Mode: "merge", Created: 1, Updated: 1}, 2},
+		{"/planets:sync?mode=overwrite", swapisync.Report{Mode: "overwrite", Created: 1, Updated: 1}, 2},
+		{"/planets:sync?mode=report", swapisync.Report{Mode: "report", Created: 1, Updated: 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.report.Mode, func(t *testing.T) {
+			repo := newRepositoryMock(planet.Planet{Name: "Tatooine", Climate: "hot"})
+			s := Server{
+				PlanetRepository: repo,
+				CountRetriever:   staticCounterMock{},
+				PlanetSource:     swapi.SWAPI{APIURL: fake.URL},
+				Cfg:              config.Config{AllowInsecureNoAuth: true, OpenAPIValidateResponses: true},
+			}
+
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var report swapisync.Report
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Equal(t, tt.report.Mode, report.Mode)
+			assert.Equal(t, tt.report.Created, report.Created)
+			assert.Equal(t, tt.report.Updated, report.Updated)
+			assert.Len(t, report.Changes, 2)
+			assert.Len(t, repo.planets, tt.planets)
+		})
+	}
+}
+
+func TestSyncPlanetsWhenSWAPIFails(t *testing.T) {
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer fake.Close()
+	s := Server{
+		PlanetRepository: newRepositoryMock(),
+		CountRetriever:   staticCounterMock{},
+		PlanetSource:     swapi.SWAPI{APIURL: fake.URL},
+		Cfg:              config.Config{AllowInsecureNoAuth: true, OpenAPIValidateResponses: true},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/planets:sync", nil))
+
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	var response syncResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, "reading the SWAPI planets page 1: SWAPI answered 503", response.Error)
+}
+
+func TestSyncPlanetsRejectsInvalidRequests(t *testing.T) {
+	s := Server{PlanetRepository: newRepositoryMock(), CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/planets:sync", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "The sync should be disabled without a source")
+
+	s.PlanetSource = swapi.SWAPI{APIURL: "http://localhost:1"}
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/planets:sync?mode=replace", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// listDeletedPlanetsHandler lists the deleted planets which can still be restored
+func (s *Server) listDeletedPlanetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	planets, err := s.PlanetRepository.FindDeleted(ctx)
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the deleted planets", err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, planets)
+}
+
+// restorePlanetHandler brings back a deleted planet which was not purged yet
+func (s *Server) restorePlanetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	restored, err := s.PlanetRepository.Restore(ctx, mux.Vars(r)["id"])
+	if handleVersionError(w, err) {
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error restoring the planet", err)
+		return
+	}
+	w.Header().Set("ETag", etag(restored.Version))
+	writeResponse(w, r, http.StatusOK, restored)
+}
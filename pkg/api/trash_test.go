@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAndRestorePlanet(t *testing.T) {
+	repo := newRepositoryMock(planet.Planet{Name: "Hoth"}, planet.Planet{Name: "Naboo"})
+	s := Server{PlanetRepository: repo, CountRetriever: staticCounterMock{}, Cfg: config.Config{AllowInsecureNoAuth: true}}
+	h := s.handler()
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/planets/1").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/planets/1").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/planets?name=Hoth").Code)
+	planets, _ := repo.FindAll(context.Background())
+	assert.Len(t, planets, 1)
+
+	rec := do(http.MethodGet, "/planets:deleted")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var deleted []planet.Planet
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&deleted))
+	if assert.Len(t, deleted, 1) {
+		assert.Equal(t, "Hoth", deleted[0].Name)
+		assert.NotNil(t, deleted[0].DeletedAt)
+	}
+
+	rec = do(http.MethodPost, "/planets/1:restore")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/planets/1").Code)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/planets/1:restore").Code, "Only deleted planets can be restored")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/planets/42:restore").Code)
+}
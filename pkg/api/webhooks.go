@@ -0,0 +1,229 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rafaelreinert/stars/pkg/webhook"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// webhookRequest is the body of the webhook create and update, a webhook is active unless told otherwise
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (req webhookRequest) apply(sub webhook.Subscription) webhook.Subscription {
+	sub.URL = req.URL
+	sub.Events = req.Events
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	sub.Active = req.Active == nil || *req.Active
+	return sub
+}
+
+// hideSecret removes the secret, it is only shown when the webhook is created
+func hideSecret(sub webhook.Subscription) webhook.Subscription {
+	sub.Secret = ""
+	return sub
+}
+
+// webhooksEnabled answers 404 when no webhook store is configured
+func (s *Server) webhooksEnabled(w http.ResponseWriter) bool {
+	if s.Webhooks == nil {
+		handleError(w, http.StatusNotFound, "The webhooks are not enabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) decodeWebhook(w http.ResponseWriter, r *http.Request) (webhookRequest, bool) {
+	var req webhookRequest
+	if err := decodeBody(r, &req); err != nil {
+		handleDecodeError(w, err, "Error decoding the webhook: "+err.Error())
+		return webhookRequest{}, false
+	}
+	if err := req.apply(webhook.Subscription{}).Validate(); err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+		return webhookRequest{}, false
+	}
+	return req, true
+}
+
+// createWebhookHandler subscribes a URL to the planet events, the response carries the signing secret only this time
+func (s *Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	req, ok := s.decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, "Error generating the webhook secret")
+		return
+	}
+	sub := req.apply(webhook.Subscription{Secret: secret, CreatedAt: time.Now().UTC()})
+	created, err := s.Webhooks.CreateSubscription(ctx, sub)
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error creating the webhook", err)
+		return
+	}
+	w.Header().Set("Location", "/webhooks/"+created.ID)
+	writeResponse(w, r, http.StatusCreated, created)
+}
+
+func (s *Server) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	subs, err := s.Webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the webhooks", err)
+		return
+	}
+	for i := range subs {
+		subs[i] = hideSecret(subs[i])
+	}
+	writeResponse(w, r, http.StatusOK, subs)
+}
+
+func (s *Server) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	sub, err := s.Webhooks.FindSubscription(ctx, mux.Vars(r)["id"])
+	if err == webhook.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the webhook", err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, hideSecret(sub))
+}
+
+// updateWebhookHandler replaces the url, events and active flag of the webhook, the secret is kept
+func (s *Server) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	req, ok := s.decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+	sub, err := s.Webhooks.FindSubscription(ctx, mux.Vars(r)["id"])
+	if err == nil {
+		sub, err = s.Webhooks.UpdateSubscription(ctx, req.apply(sub))
+	}
+	if err == webhook.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error updating the webhook", err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, hideSecret(sub))
+}
+
+// deleteWebhookHandler removes the webhook, its pending deliveries go to the dead letter
+func (s *Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	err := s.Webhooks.DeleteSubscription(ctx, mux.Vars(r)["id"])
+	if err == webhook.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error deleting the webhook", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// listDeliveriesHandler serves the delivery log of the webhook, the newest first
+func (s *Server) listDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	limit := defaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			handleError(w, http.StatusBadRequest, "The limit must be between 1 and "+strconv.Itoa(maxDeliveriesLimit))
+			return
+		}
+		limit = n
+	}
+	id := mux.Vars(r)["id"]
+	_, err := s.Webhooks.FindSubscription(ctx, id)
+	if err == webhook.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the webhook", err)
+		return
+	}
+	deliveries, err := s.Webhooks.ListDeliveries(ctx, id, limit)
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the webhook deliveries", err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, deliveries)
+}
+
+// retryDeliveryHandler takes a delivery out of the dead letter, it gets all its attempts again
+func (s *Server) retryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	vars := mux.Vars(r)
+	delivery, err := s.Webhooks.FindDelivery(ctx, vars["deliveryId"])
+	if err == nil && delivery.SubscriptionID != vars["id"] {
+		err = webhook.ErrNotFound
+	}
+	if err == webhook.ErrNotFound {
+		handleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retriving the webhook delivery", err)
+		return
+	}
+	if delivery.Status != webhook.StatusDead {
+		handleError(w, http.StatusConflict, "Only the dead deliveries can be retried, the delivery is "+delivery.Status)
+		return
+	}
+	now := time.Now().UTC()
+	delivery.Status = webhook.StatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := s.Webhooks.UpdateDelivery(ctx, delivery); err != nil {
+		handleContextError(ctx, w, http.StatusBadRequest, "Error retrying the webhook delivery", err)
+		return
+	}
+	writeResponse(w, r, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebhookServer(store webhook.Store) http.Handler {
+	s, _ := newTestServer(config.Config{})
+	s.Webhooks = store
+	return s.handler()
+}
+
+func doRequest(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	store := webhook.NewMemoryStore()
+	h := newWebhookServer(store)
+
+	rec := doRequest(h, http.MethodPost, "/webhooks", `{"url": "https://example.com/hook", "events": ["planet.created"]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created webhook.Subscription
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, "/webhooks/"+created.ID, rec.Header().Get("Location"))
+	assert.True(t, strings.HasPrefix(created.Secret, "whsec_"), "The secret should be shown on the creation")
+	assert.True(t, created.Active)
+
+	rec = doRequest(h, http.MethodGet, "/webhooks/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret")
+	rec = doRequest(h, http.MethodGet, "/webhooks", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "whsec_")
+
+	rec = doRequest(h, http.MethodPut, "/webhooks/"+created.ID, `{"url": "https://example.com/other", "active": false}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	stored, _ := store.FindSubscription(context.Background(), created.ID)
+	assert.Equal(t, "https://example.com/other", stored.URL)
+	assert.Equal(t, []string{}, stored.Events)
+	assert.False(t, stored.Active)
+	assert.Equal(t, created.Secret, stored.Secret, "The update should keep the secret")
+
+	assert.Equal(t, http.StatusOK, doRequest(h, http.MethodDelete, "/webhooks/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodDelete, "/webhooks/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodGet, "/webhooks/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodPut, "/webhooks/"+created.ID, `{"url": "https://example.com"}`).Code)
+}
+
+func TestWebhookValidation(t *testing.T) {
+	h := newWebhookServer(webhook.NewMemoryStore())
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"InvalidJSON", `{"url":`},
+		{"RelativeURL", `{"url": "/hook"}`},
+		{"UnknownEvent", `{"url": "https://example.com", "events": ["planet.renamed"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodPost, "/webhooks", tt.body).Code)
+		})
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	store := webhook.NewMemoryStore()
+	h := newWebhookServer(store)
+	sub, _ := store.CreateSubscription(ctx, webhook.Subscription{URL: "https://example.com", Active: true})
+	other, _ := store.CreateSubscription(ctx, webhook.Subscription{URL: "https://example.com", Active: true})
+	now := time.Now().UTC()
+	dead, _ := store.CreateDelivery(ctx, webhook.Delivery{SubscriptionID: sub.ID, Status: webhook.StatusDead, Attempts: 8, CreatedAt: now})
+	succeeded, _ := store.CreateDelivery(ctx, webhook.Delivery{SubscriptionID: sub.ID, Status: webhook.StatusSucceeded, Attempts: 1, CreatedAt: now.Add(time.Second)})
+
+	rec := doRequest(h, http.MethodGet, "/webhooks/"+sub.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var deliveries []webhook.Delivery
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&deliveries))
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, succeeded.ID, deliveries[0].ID)
+	}
+	assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodGet, "/webhooks/"+sub.ID+"/deliveries?limit=0", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodGet, "/webhooks/42/deliveries", "").Code)
+
+	rec = doRequest(h, http.MethodPost, "/webhooks/"+sub.ID+"/deliveries/"+dead.ID+":retry", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	retried, _ := store.FindDelivery(ctx, dead.ID)
+	assert.Equal(t, webhook.StatusPending, retried.Status)
+	assert.Equal(t, 0, retried.Attempts)
+
+	assert.Equal(t, http.StatusConflict, doRequest(h, http.MethodPost, "/webhooks/"+sub.ID+"/deliveries/"+succeeded.ID+":retry", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodPost, "/webhooks/"+other.ID+"/deliveries/"+dead.ID+":retry", "").Code)
+}
+
+func TestWebhooksDisabled(t *testing.T) {
+	h := newWebhookServer(nil)
+
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodGet, "/webhooks", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodPost, "/webhooks", `{"url": "https://example.com"}`).Code)
+}
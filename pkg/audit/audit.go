@@ -0,0 +1,50 @@
+// Package audit keeps an immutable trail of the changes made to the planets
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+)
+
+// Action is the kind of change recorded by an Entry
+type Action string
+
+// The audited actions
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionRestore Action = "restore"
+)
+
+// ErrNotFound is returned when the planet has no audit entry
+var ErrNotFound = errors.New("no audit entry for the planet")
+
+// Actor is the struct which identifies who made a change, it is the authenticated principal
+type Actor struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Entry is the struct which records one change of a planet, Before is nil on creation and After is nil on deletion
+type Entry struct {
+	ID        string         `json:"id"`
+	PlanetID  string         `json:"planetId"`
+	Action    Action         `json:"action"`
+	Actor     Actor          `json:"actor"`
+	RequestID string         `json:"requestId,omitempty"`
+	At        time.Time      `json:"at"`
+	Version   int64          `json:"version"`
+	Before    *planet.Planet `json:"before"`
+	After     *planet.Planet `json:"after"`
+}
+
+// Store is the interface used to keep the audit entries, entries can only be appended
+type Store interface {
+	Append(ctx context.Context, e Entry) error
+	// FindByPlanet returns the entries of the planet, the oldest first
+	FindByPlanet(ctx context.Context, planetID string) ([]Entry, error)
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore is a Store which keeps the entries in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds the entry with a sequential id
+func (s *MemoryStore) Append(ctx context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = strconv.Itoa(len(s.entries) + 1)
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// FindByPlanet returns the entries of the planet in the order they were appended
+func (s *MemoryStore) FindByPlanet(ctx context.Context, planetID string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []Entry
+	for _, e := range s.entries {
+		if e.PlanetID == planetID {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return entries, nil
+}
@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	assert.NoError(t, s.Append(ctx, Entry{PlanetID: "p1", Action: ActionCreate}))
+	assert.NoError(t, s.Append(ctx, Entry{PlanetID: "p2", Action: ActionCreate}))
+	assert.NoError(t, s.Append(ctx, Entry{PlanetID: "p1", Action: ActionDelete}))
+
+	entries, err := s.FindByPlanet(ctx, "p1")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "1", entries[0].ID)
+		assert.Equal(t, ActionDelete, entries[1].Action)
+	}
+	_, err = s.FindByPlanet(ctx, "p3")
+	assert.Equal(t, ErrNotFound, err)
+}
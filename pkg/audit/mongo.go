@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type snapshotMongoModel struct {
+	Name      string     `bson:"name"`
+	Climate   string     `bson:"climate"`
+	Terrain   string     `bson:"terrain"`
+	Version   int64      `bson:"version"`
+	DeletedAt *time.Time `bson:"deletedAt,omitempty"`
+}
+
+type entryMongoModel struct {
+	ID        primitive.ObjectID  `bson:"_id"`
+	PlanetID  string              `bson:"planetId"`
+	Action    Action              `bson:"action"`
+	ActorID   string              `bson:"actorId"`
+	ActorName string              `bson:"actorName,omitempty"`
+	RequestID string              `bson:"requestId,omitempty"`
+	At        time.Time           `bson:"at"`
+	Version   int64               `bson:"version"`
+	Before    *snapshotMongoModel `bson:"before"`
+	After     *snapshotMongoModel `bson:"after"`
+}
+
+func newSnapshot(p *planet.Planet) *snapshotMongoModel {
+	if p == nil {
+		return nil
+	}
+	return &snapshotMongoModel{Name: p.Name, Climate: p.Climate, Terrain: p.Terrain, Version: p.Version, DeletedAt: p.DeletedAt}
+}
+
+func (s *snapshotMongoModel) toPlanet(id string) *planet.Planet {
+	if s == nil {
+		return nil
+	}
+	return &planet.Planet{ID: id, Name: s.Name, Climate: s.Climate, Terrain: s.Terrain, Version: s.Version, DeletedAt: s.DeletedAt}
+}
+
+func (m entryMongoModel) toEntry() Entry {
+	return Entry{
+		ID:        m.ID.Hex(),
+		PlanetID:  m.PlanetID,
+		Action:    m.Action,
+		Actor:     Actor{ID: m.ActorID, Name: m.ActorName},
+		RequestID: m.RequestID,
+		At:        m.At,
+		Version:   m.Version,
+		Before:    m.Before.toPlanet(m.PlanetID),
+		After:     m.After.toPlanet(m.PlanetID),
+	}
+}
+
+// MongoStore is a Store which keeps the entries on the audit MongoDB collection, it only ever inserts
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore creates a Store instance to append audit entries on MongoDB, it ensures the index used by the planet history
+func NewMongoStore(ctx context.Context, db *mongo.Database) (MongoStore, error) {
+	s := MongoStore{Collection: db.Collection("audit")}
+	_, err := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "planetId", Value: 1}, {Key: "at", Value: 1}},
+	})
+	if err != nil {
+		return MongoStore{}, errors.Wrap(err, "Error creating the audit planet index")
+	}
+	return s, nil
+}
+
+// Append inserts the entry on Mongo
+func (s MongoStore) Append(ctx context.Context, e Entry) error {
+	_, err := s.Collection.InsertOne(ctx, entryMongoModel{
+		ID:        primitive.NewObjectID(),
+		PlanetID:  e.PlanetID,
+		Action:    e.Action,
+		ActorID:   e.Actor.ID,
+		ActorName: e.Actor.Name,
+		RequestID: e.RequestID,
+		At:        e.At.UTC(),
+		Version:   e.Version,
+		Before:    newSnapshot(e.Before),
+		After:     newSnapshot(e.After),
+	})
+	return err
+}
+
+// FindByPlanet finds the entries of the planet on Mongo, the oldest first
+func (s MongoStore) FindByPlanet(ctx context.Context, planetID string) ([]Entry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.Collection.Find(ctx, bson.M{"planetId": planetID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var models []entryMongoModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, ErrNotFound
+	}
+	entries := make([]Entry, len(models))
+	for i, m := range models {
+		entries[i] = m.toEntry()
+	}
+	return entries, nil
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase keeps the store tests away from the starwars database used by the other packages
+const testDatabase = "starwars_audit_test"
+
+func TestMongoStore(t *testing.T) {
+	client, err := connectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database(testDatabase).Drop(context.Background())
+
+	ctx := context.Background()
+	store, err := NewMongoStore(ctx, client.Database(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().UTC().Truncate(time.Millisecond)
+	created := Entry{
+		PlanetID:  "p1",
+		Action:    ActionCreate,
+		Actor:     Actor{ID: "k1", Name: "ops"},
+		RequestID: "req-1",
+		At:        at,
+		Version:   1,
+		After:     &planet.Planet{ID: "p1", Name: "Hoth", Climate: "frozen", Version: 1},
+	}
+	deleted := Entry{
+		PlanetID: "p1",
+		Action:   ActionDelete,
+		Actor:    Actor{ID: "k1"},
+		At:       at.Add(time.Second),
+		Version:  2,
+		Before:   created.After,
+	}
+
+	assert.NoError(t, store.Append(ctx, deleted))
+	assert.NoError(t, store.Append(ctx, created))
+	entries, err := store.FindByPlanet(ctx, "p1")
+
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.NotEmpty(t, entries[0].ID)
+		created.ID = entries[0].ID
+		assert.Equal(t, created, entries[0], "The entries should be sorted by time")
+		assert.Equal(t, ActionDelete, entries[1].Action)
+		assert.Nil(t, entries[1].After)
+	}
+	_, err = store.FindByPlanet(ctx, "p2")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func connectMongoClient() (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.Database(testDatabase).Drop(context.Background())
+	return client, nil
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/requestid"
+)
+
+// maxAttempts bounds the retries of an unconditional change which raced with another change
+const maxAttempts = 3
+
+// Repository is a PlanetRepository decorator which appends an audit entry for every change made through it,
+// the finders are served by the decorated repository
+type Repository struct {
+	repository.PlanetRepository
+	Store Store
+}
+
+// NewRepository decorates the repository with the audit trail kept on the store
+func NewRepository(rep repository.PlanetRepository, store Store) Repository {
+	return Repository{PlanetRepository: rep, Store: store}
+}
+
+// Create creates the planet and records it
+func (r Repository) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	created, err := r.PlanetRepository.Create(ctx, p)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	r.record(ctx, ActionCreate, created.ID, nil, &created)
+	return created, nil
+}
+
+// Update updates the planet and records the replaced and the new planet
+func (r Repository) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	var updated planet.Planet
+	before, err := r.change(ctx, p.ID, p.Version, func(version int64) error {
+		p.Version = version
+		var err error
+		updated, err = r.PlanetRepository.Update(ctx, p)
+		return err
+	})
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	r.record(ctx, ActionUpdate, updated.ID, before, &updated)
+	return updated, nil
+}
+
+// Patch patches the planet and records it as an update
+func (r Repository) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	var patched planet.Planet
+	before, err := r.change(ctx, id, u.Version, func(version int64) error {
+		u.Version = version
+		var err error
+		patched, err = r.PlanetRepository.Patch(ctx, id, u)
+		return err
+	})
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	r.record(ctx, ActionUpdate, id, before, &patched)
+	return patched, nil
+}
+
+// Delete deletes the planet and records the deleted planet, deleting an unknown planet records nothing
+func (r Repository) Delete(ctx context.Context, id string, version int64) error {
+	before, err := r.change(ctx, id, version, func(version int64) error {
+		return r.PlanetRepository.Delete(ctx, id, version)
+	})
+	if err != nil || before == nil {
+		return err
+	}
+	r.record(ctx, ActionDelete, id, before, nil)
+	return nil
+}
+
+// Restore restores the planet and records the restored planet
+func (r Repository) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	restored, err := r.PlanetRepository.Restore(ctx, id)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	r.record(ctx, ActionRestore, id, nil, &restored)
+	return restored, nil
+}
+
+// Bulk runs the operations and records the successful ones, the snapshots before the changes are read
+// right before the bulk so, unlike the single changes, a concurrent change may be missing from them
+func (r Repository) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	befores := make([]*planet.Planet, len(ops))
+	for i, op := range ops {
+		if op.Type == repository.BulkCreate {
+			continue
+		}
+		if current, err := r.PlanetRepository.FindByID(ctx, op.Planet.ID); err == nil {
+			befores[i] = &current
+		}
+	}
+
+	results, err := r.PlanetRepository.Bulk(ctx, ops, ordered)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		after := ops[i].Planet
+		after.ID = result.ID
+		switch ops[i].Type {
+		case repository.BulkCreate:
+			after.Version = 1
+			r.record(ctx, ActionCreate, result.ID, nil, &after)
+		case repository.BulkUpdate:
+			if befores[i] != nil {
+				after.Version = befores[i].Version + 1
+			}
+			r.record(ctx, ActionUpdate, result.ID, befores[i], &after)
+		case repository.BulkDelete:
+			if befores[i] != nil {
+				r.record(ctx, ActionDelete, result.ID, befores[i], nil)
+			}
+		}
+	}
+	return results, nil
+}
+
+// change reads the planet and runs fn on the version it read, so the before snapshot is exactly the planet fn replaced.
+// An unconditional change which raced with another change is retried, a planet which can not be read is changed without snapshot.
+func (r Repository) change(ctx context.Context, id string, version int64, fn func(version int64) error) (*planet.Planet, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := r.PlanetRepository.FindByID(ctx, id)
+		if err != nil {
+			return nil, fn(version)
+		}
+		expected := version
+		if expected == 0 {
+			expected = current.Version
+		}
+		err = fn(expected)
+		if err == repository.ErrVersionMismatch && version == 0 && attempt < maxAttempts {
+			continue
+		}
+		return &current, err
+	}
+}
+
+// record appends the entry with the principal and request id of the context, a failure is logged
+// since the change itself was already made
+func (r Repository) record(ctx context.Context, action Action, id string, before, after *planet.Planet) {
+	e := Entry{
+		PlanetID:  id,
+		Action:    action,
+		Actor:     actor(ctx),
+		RequestID: requestid.FromContext(ctx),
+		At:        time.Now().UTC(),
+		Before:    before,
+		After:     after,
+	}
+	switch {
+	case after != nil:
+		e.Version = after.Version
+	case before != nil:
+		e.Version = before.Version + 1
+	}
+
+	// the entry must be saved even when the client went away right after the change
+	storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Store.Append(storeCtx, e); err != nil {
+		log.Println("Error appending the audit entry of", action, id, e.RequestID, err)
+	}
+}
+
+func actor(ctx context.Context) Actor {
+	if p, ok := auth.FromContext(ctx); ok {
+		return Actor{ID: p.ID, Name: p.Name}
+	}
+	return Actor{ID: "anonymous"}
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+// repositoryFake keeps versioned planets in memory, races makes the next conditional changes fail as if another change won
+type repositoryFake struct {
+	repository.PlanetRepository
+	planets map[string]planet.Planet
+	races   int
+}
+
+func newRepositoryFake() *repositoryFake {
+	return &repositoryFake{planets: map[string]planet.Planet{}}
+}
+
+func (r *repositoryFake) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	p.ID = strconv.Itoa(len(r.planets) + 1)
+	p.Version = 1
+	r.planets[p.ID] = p
+	return p, nil
+}
+
+func (r *repositoryFake) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
+	p, ok := r.planets[id]
+	if !ok {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r *repositoryFake) check(id string, version int64) (planet.Planet, error) {
+	p, ok := r.planets[id]
+	if !ok {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if r.races > 0 {
+		r.races--
+		p.Version++
+		r.planets[id] = p
+	}
+	if version != 0 && version != p.Version {
+		return planet.Planet{}, repository.ErrVersionMismatch
+	}
+	return p, nil
+}
+
+func (r *repositoryFake) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	current, err := r.check(p.ID, p.Version)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	p.Version = current.Version + 1
+	r.planets[p.ID] = p
+	return p, nil
+}
+
+func (r *repositoryFake) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	p, err := r.check(id, u.Version)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	p = u.Apply(p)
+	p.Version++
+	r.planets[id] = p
+	return p, nil
+}
+
+func (r *repositoryFake) Delete(ctx context.Context, id string, version int64) error {
+	if _, err := r.check(id, version); err != nil {
+		if err == repository.ErrNotFound && version == 0 {
+			return nil
+		}
+		return err
+	}
+	delete(r.planets, id)
+	return nil
+}
+
+func TestRepositoryRecordsTheChanges(t *testing.T) {
+	store := NewMemoryStore()
+	repo := NewRepository(newRepositoryFake(), store)
+	ctx := requestid.NewContext(auth.NewContext(context.Background(), auth.Principal{ID: "k1", Name: "ops"}), "req-1")
+
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen"})
+	created.Climate = "cold"
+	updated, _ := repo.Update(ctx, created)
+	climate := "hot"
+	patched, _ := repo.Patch(ctx, created.ID, planet.Update{Climate: &climate})
+	assert.NoError(t, repo.Delete(ctx, created.ID, 0))
+	assert.NoError(t, repo.Delete(ctx, "42", 0))
+
+	entries, err := store.FindByPlanet(ctx, created.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+	assert.Equal(t, []Action{ActionCreate, ActionUpdate, ActionUpdate, ActionDelete},
+		[]Action{entries[0].Action, entries[1].Action, entries[2].Action, entries[3].Action})
+	for i, e := range entries {
+		assert.Equal(t, Actor{ID: "k1", Name: "ops"}, e.Actor)
+		assert.Equal(t, "req-1", e.RequestID)
+		assert.Equal(t, int64(i+1), e.Version)
+		assert.False(t, e.At.IsZero())
+	}
+	assert.Nil(t, entries[0].Before)
+	assert.Equal(t, "frozen", entries[1].Before.Climate)
+	assert.Equal(t, updated, *entries[1].After)
+	assert.Equal(t, "cold", entries[2].Before.Climate)
+	assert.Equal(t, patched, *entries[2].After)
+	assert.Equal(t, patched, *entries[3].Before)
+	assert.Nil(t, entries[3].After)
+
+	_, err = store.FindByPlanet(ctx, "42")
+	assert.Equal(t, ErrNotFound, err, "Deleting an unknown planet should record nothing")
+}
+
+func TestRepositoryRetriesTheRacedUnconditionalChanges(t *testing.T) {
+	fake := newRepositoryFake()
+	store := NewMemoryStore()
+	repo := NewRepository(fake, store)
+	ctx := context.Background()
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+
+	fake.races = 1
+	climate := "hot"
+	patched, err := repo.Patch(ctx, created.ID, planet.Update{Climate: &climate})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), patched.Version)
+
+	fake.races = 1
+	_, err = repo.Patch(ctx, created.ID, planet.Update{Version: patched.Version, Climate: &climate})
+	assert.Equal(t, repository.ErrVersionMismatch, err, "A conditional change should not be retried")
+
+	entries, _ := store.FindByPlanet(ctx, created.ID)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, Actor{ID: "anonymous"}, entries[1].Actor)
+		assert.Equal(t, int64(2), entries[1].Before.Version, "The before snapshot should be the replaced version")
+	}
+}
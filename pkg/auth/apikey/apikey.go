@@ -0,0 +1,99 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/auth"
+)
+
+// tokenPrefix makes the stars API keys easy to recognize on logs and secret scanners
+const tokenPrefix = "stars_"
+
+var (
+	// ErrNotFound is returned when the store has no key matching the lookup
+	ErrNotFound = errors.New("API key not found")
+	// ErrInvalidKey is returned when the token does not match an active key
+	ErrInvalidKey = errors.New("API key is invalid or revoked")
+)
+
+// Key is an API key, only the hash of the token is kept
+type Key struct {
+	ID        string
+	Name      string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Revoked reports whether the key was revoked
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Store is the interface used to persist the API keys
+type Store interface {
+	Create(ctx context.Context, k Key) error
+	FindByHash(ctx context.Context, hash string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+// Generate creates a new Key and returns it with the plain token, which must be handed to the client since it can not be recovered
+func Generate(name string, scopes []string) (Key, string, error) {
+	for _, s := range scopes {
+		if !auth.IsValidScope(s) {
+			return Key{}, "", errors.Errorf("Unknown scope %q", s)
+		}
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return Key{}, "", err
+	}
+	token := tokenPrefix + secret
+	return Key{
+		ID:        id,
+		Name:      name,
+		Hash:      Hash(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}, token, nil
+}
+
+// Hash returns the hash used to store and look up a token
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate looks up the token on the store and returns the principal it represents
+func Authenticate(ctx context.Context, store Store, token string) (auth.Principal, error) {
+	k, err := store.FindByHash(ctx, Hash(token))
+	if err == ErrNotFound {
+		return auth.Principal{}, ErrInvalidKey
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if k.Revoked() {
+		return auth.Principal{}, ErrInvalidKey
+	}
+	return auth.Principal{ID: "apikey:" + k.ID, Name: k.Name, Scopes: k.Scopes}, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "Error generating random bytes")
+	}
+	return hex.EncodeToString(b), nil
+}
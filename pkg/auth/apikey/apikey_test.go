@@ -0,0 +1,54 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	k, token, err := Generate("ops", []string{auth.ScopePlanetsRead})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "stars_"))
+	assert.Equal(t, Hash(token), k.Hash)
+	assert.NotContains(t, k.Hash, token)
+	assert.Equal(t, "ops", k.Name)
+	assert.False(t, k.Revoked())
+}
+
+func TestGenerateWithAnUnknownScope(t *testing.T) {
+	_, _, err := Generate("ops", []string{"planets:owner"})
+
+	assert.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	k, token, _ := Generate("ops", []string{auth.ScopePlanetsWrite})
+	store := NewMemoryStore(k)
+
+	p, err := Authenticate(context.Background(), store, token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey:"+k.ID, p.ID)
+	assert.True(t, p.HasScope(auth.ScopePlanetsWrite))
+}
+
+func TestAuthenticateWithAnUnknownToken(t *testing.T) {
+	_, err := Authenticate(context.Background(), NewMemoryStore(), "stars_unknown")
+
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestAuthenticateWithARevokedKey(t *testing.T) {
+	k, token, _ := Generate("ops", []string{auth.ScopePlanetsWrite})
+	store := NewMemoryStore(k)
+	store.Revoke(context.Background(), k.ID)
+
+	_, err := Authenticate(context.Background(), store, token)
+
+	assert.Equal(t, ErrInvalidKey, err)
+}
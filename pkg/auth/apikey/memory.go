@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/auth"
+)
+
+// MemoryStore is a Store which keeps the keys in memory, it is meant for keys given by configuration
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore creates a MemoryStore filled with the given keys
+func NewMemoryStore(keys ...Key) *MemoryStore {
+	s := &MemoryStore{keys: map[string]Key{}}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s
+}
+
+// ParseKeys parses keys in the "name:token:scope,scope" format used by the API_KEYS configuration,
+// the errors only mention the entry position so the tokens never reach the logs
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	names := map[string]bool{}
+	for i, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("Invalid API key entry at position %d, the expected format is name:token:scope,scope", i)
+		}
+		if names[parts[0]] {
+			return nil, errors.Errorf("Duplicated API key name %q at position %d", parts[0], i)
+		}
+		names[parts[0]] = true
+		scopes := strings.Split(parts[2], ",")
+		for _, s := range scopes {
+			if !auth.IsValidScope(s) {
+				return nil, errors.Errorf("Unknown scope %q on API key %q", s, parts[0])
+			}
+		}
+		keys = append(keys, Key{
+			ID:        parts[0],
+			Name:      parts[0],
+			Hash:      Hash(parts[1]),
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	return keys, nil
+}
+
+// Create stores a new key
+func (s *MemoryStore) Create(ctx context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	return nil
+}
+
+// FindByHash finds the key with the given token hash
+func (s *MemoryStore) FindByHash(ctx context.Context, hash string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return Key{}, ErrNotFound
+}
+
+// List returns all keys ordered by creation
+func (s *MemoryStore) List(ctx context.Context) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// Revoke marks the key as revoked
+func (s *MemoryStore) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	s.keys[id] = k
+	return nil
+}
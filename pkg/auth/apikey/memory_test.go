@@ -0,0 +1,53 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys([]string{"reader:secret1:planets:read", " writer:secret2:planets:read,planets:write", ""})
+
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "reader", keys[0].Name)
+		assert.Equal(t, Hash("secret1"), keys[0].Hash)
+		assert.Equal(t, []string{"planets:read"}, keys[0].Scopes)
+		assert.Equal(t, []string{"planets:read", "planets:write"}, keys[1].Scopes)
+	}
+}
+
+func TestParseKeysWithAnInvalidEntry(t *testing.T) {
+	_, err := ParseKeys([]string{"stars_secret_token"})
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "stars_secret_token")
+	}
+
+	_, err = ParseKeys([]string{"reader:secret:planets:owner"})
+	assert.Error(t, err)
+}
+
+func TestParseKeysWithDuplicatedNames(t *testing.T) {
+	_, err := ParseKeys([]string{"ops:secret1:planets:read", "ops:secret2:planets:write"})
+
+	assert.Error(t, err)
+}
+
+func TestMemoryStoreRevokeUnknownKey(t *testing.T) {
+	err := NewMemoryStore().Revoke(context.Background(), "unknown")
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	store := NewMemoryStore()
+	k, _, _ := Generate("ops", []string{"planets:read"})
+	store.Create(context.Background(), k)
+
+	keys, err := store.List(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{k}, keys)
+}
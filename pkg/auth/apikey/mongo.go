@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type keyMongoModel struct {
+	ID        string     `bson:"_id"`
+	Name      string     `bson:"name"`
+	Hash      string     `bson:"hash"`
+	Scopes    []string   `bson:"scopes"`
+	CreatedAt time.Time  `bson:"createdAt"`
+	RevokedAt *time.Time `bson:"revokedAt,omitempty"`
+}
+
+func (m keyMongoModel) toKey() Key {
+	return Key{
+		ID:        m.ID,
+		Name:      m.Name,
+		Hash:      m.Hash,
+		Scopes:    m.Scopes,
+		CreatedAt: m.CreatedAt,
+		RevokedAt: m.RevokedAt,
+	}
+}
+
+// MongoStore is a Store which keeps the keys on the apikey MongoDB collection
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore creates a Store instance to manipulate API keys on MongoDB, it ensures the unique index used to look up the tokens
+func NewMongoStore(ctx context.Context, db *mongo.Database) (MongoStore, error) {
+	s := MongoStore{Collection: db.Collection("apikey")}
+	_, err := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return MongoStore{}, errors.Wrap(err, "Error creating the apikey hash index")
+	}
+	return s, nil
+}
+
+// Create stores a new key on Mongo
+func (s MongoStore) Create(ctx context.Context, k Key) error {
+	_, err := s.Collection.InsertOne(ctx, keyMongoModel{
+		ID:        k.ID,
+		Name:      k.Name,
+		Hash:      k.Hash,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	})
+	return err
+}
+
+// FindByHash finds the key with the given token hash on Mongo
+func (s MongoStore) FindByHash(ctx context.Context, hash string) (Key, error) {
+	var model keyMongoModel
+	err := s.Collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return Key{}, ErrNotFound
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return model.toKey(), nil
+}
+
+// List returns all keys on Mongo ordered by creation
+func (s MongoStore) List(ctx context.Context) ([]Key, error) {
+	cursor, err := s.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var models []keyMongoModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, len(models))
+	for i, m := range models {
+		keys[i] = m.toKey()
+	}
+	return keys, nil
+}
+
+// Revoke marks the key as revoked on Mongo
+func (s MongoStore) Revoke(ctx context.Context, id string) error {
+	result, err := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase keeps the store tests away from the starwars database used by the other packages
+const testDatabase = "starwars_apikey_test"
+
+func TestMongoStore(t *testing.T) {
+	client, err := connectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database(testDatabase).Drop(context.Background())
+
+	ctx := context.Background()
+	store, err := NewMongoStore(ctx, client.Database(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, token, _ := Generate("ops", []string{"planets:read"})
+
+	assert.NoError(t, store.Create(ctx, k))
+
+	found, err := store.FindByHash(ctx, Hash(token))
+	assert.NoError(t, err)
+	assert.Equal(t, k.ID, found.ID)
+	assert.Equal(t, k.Scopes, found.Scopes)
+
+	assert.NoError(t, store.Revoke(ctx, k.ID))
+	_, err = Authenticate(ctx, store, token)
+	assert.Equal(t, ErrInvalidKey, err)
+
+	keys, err := store.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.True(t, keys[0].Revoked())
+	}
+
+	assert.Equal(t, ErrNotFound, store.Revoke(ctx, "unknown"))
+	_, err = store.FindByHash(ctx, Hash("unknown"))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func connectMongoClient() (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.Database(testDatabase).Drop(context.Background())
+	return client, nil
+}
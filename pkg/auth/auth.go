@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+)
+
+const (
+	// ScopePlanetsRead allows the principal to list and retrieve planets
+	ScopePlanetsRead = "planets:read"
+	// ScopePlanetsWrite allows the principal to create, update and delete planets
+	ScopePlanetsWrite = "planets:write"
+	// ScopePlanetsAdmin allows the principal to see the deleted planets
+	ScopePlanetsAdmin = "planets:admin"
+)
+
+// Scopes lists every scope known by the system
+var Scopes = []string{ScopePlanetsRead, ScopePlanetsWrite, ScopePlanetsAdmin}
+
+// Principal represents the authenticated caller of a request
+type Principal struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted the scope
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidScope reports whether the scope is known by the system
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
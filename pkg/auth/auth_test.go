@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasScope(t *testing.T) {
+	p := Principal{Scopes: []string{ScopePlanetsRead}}
+
+	assert.True(t, p.HasScope(ScopePlanetsRead))
+	assert.False(t, p.HasScope(ScopePlanetsWrite))
+}
+
+func TestIsValidScope(t *testing.T) {
+	assert.True(t, IsValidScope("planets:write"))
+	assert.True(t, IsValidScope("planets:admin"))
+	assert.False(t, IsValidScope("planets:owner"))
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := NewContext(context.Background(), Principal{ID: "apikey:1", Name: "ops"})
+	p, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "ops", p.Name)
+}
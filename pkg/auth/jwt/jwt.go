@@ -0,0 +1,225 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/auth"
+)
+
+// ErrInvalidToken is returned when the token is malformed, badly signed or its claims are not accepted
+var ErrInvalidToken = errors.New("Bearer token is invalid")
+
+// Options are the rules a token must follow to be accepted by the Verifier
+type Options struct {
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew on the exp and nbf claims
+	Leeway time.Duration
+	// RolesClaim is the claim carrying the roles, a dotted path reaches nested claims like realm_access.roles
+	RolesClaim string
+	// RoleScopes maps each role to the scopes it grants
+	RoleScopes map[string][]string
+}
+
+// Verifier validates the JWTs issued by the platform and turns them into principals
+type Verifier struct {
+	keys    KeySet
+	options Options
+	now     func() time.Time
+}
+
+// NewVerifier creates a Verifier which accepts the tokens signed by one of the keys
+func NewVerifier(keys KeySet, options Options) (*Verifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("At least one JWT key is required")
+	}
+	if options.Issuer == "" || options.Audience == "" {
+		return nil, errors.New("The JWT issuer and audience are required")
+	}
+	if options.RolesClaim == "" {
+		options.RolesClaim = "roles"
+	}
+	return &Verifier{keys: keys, options: options, now: time.Now}, nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// audience accepts the aud claim both as a string and as an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// LooksLikeJWT reports whether the bearer token has the three segments of a JWS compact serialization
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the token signature, issuer, audience and validity period, then maps its roles to scopes
+func (v *Verifier) Verify(token string) (auth.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	if !v.verifySignature(h, parts[0]+"."+parts[1], signature) {
+		return auth.Principal{}, ErrInvalidToken
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	if err := v.validateClaims(c); err != nil {
+		return auth.Principal{}, err
+	}
+
+	return auth.Principal{
+		ID:     "jwt:" + c.Subject,
+		Name:   c.Subject,
+		Scopes: v.scopes(roles(raw, v.options.RolesClaim)),
+	}, nil
+}
+
+// verifySignature only accepts the algorithm bound to the key, so an HMAC token can never be checked against a public RSA key
+func (v *Verifier) verifySignature(h header, signingInput string, signature []byte) bool {
+	for _, k := range v.keys.candidates(h.KeyID) {
+		switch key := k.Key.(type) {
+		case []byte:
+			if h.Algorithm != "HS256" {
+				continue
+			}
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if h.Algorithm != "RS256" {
+				continue
+			}
+			digest := sha256.Sum256([]byte(signingInput))
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *Verifier) validateClaims(c claims) error {
+	now := v.now()
+	if c.Issuer != v.options.Issuer {
+		return errors.Wrap(ErrInvalidToken, "unexpected issuer")
+	}
+	if !c.Audience.contains(v.options.Audience) {
+		return errors.Wrap(ErrInvalidToken, "unexpected audience")
+	}
+	if c.ExpiresAt == nil || now.After(time.Unix(*c.ExpiresAt, 0).Add(v.options.Leeway)) {
+		return errors.Wrap(ErrInvalidToken, "token expired")
+	}
+	if c.NotBefore != nil && now.Before(time.Unix(*c.NotBefore, 0).Add(-v.options.Leeway)) {
+		return errors.Wrap(ErrInvalidToken, "token not valid yet")
+	}
+	if c.Subject == "" {
+		return errors.Wrap(ErrInvalidToken, "missing subject")
+	}
+	return nil
+}
+
+func (v *Verifier) scopes(roles []string) []string {
+	granted := map[string]bool{}
+	var scopes []string
+	for _, role := range roles {
+		for _, s := range v.options.RoleScopes[role] {
+			if !granted[s] {
+				granted[s] = true
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+func (a audience) contains(aud string) bool {
+	for _, value := range a {
+		if value == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// roles reads the roles claim, which can be an array or a space separated string
+func roles(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
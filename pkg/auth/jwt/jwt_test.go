@@ -0,0 +1,174 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var testRoleScopes = map[string][]string{
+	"planets-editor": {"planets:read", "planets:write"},
+	"planets-reader": {"planets:read"},
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v := newTestVerifier(t, KeySet{HMACKey("secret")})
+	token := signHS256(t, []byte("secret"), validClaims())
+
+	p, err := v.Verify(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt:alice", p.ID)
+	assert.Equal(t, []string{"planets:read", "planets:write"}, p.Scopes)
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := newTestVerifier(t, KeySet{{ID: "k1", Key: &key.PublicKey}})
+	token := signRS256(t, key, "k1", validClaims())
+
+	p, err := v.Verify(token)
+
+	assert.NoError(t, err)
+	assert.True(t, p.HasScope("planets:write"))
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := newTestVerifier(t, KeySet{{ID: "k1", Key: &key.PublicKey}})
+	publicDER := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		if value == nil {
+			delete(c, name)
+		} else {
+			c[name] = value
+		}
+		return c
+	}
+	tests := map[string]string{
+		"Malformed":           "not.a.token",
+		"WrongKey":            signRS256(t, otherKey, "k1", validClaims()),
+		"AlgorithmConfusion":  signHS256(t, publicDER, validClaims()),
+		"UnsignedToken":       encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + ".",
+		"Expired":             signRS256(t, key, "k1", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"MissingExpiration":   signRS256(t, key, "k1", with("exp", nil)),
+		"NotValidYet":         signRS256(t, key, "k1", with("nbf", time.Now().Add(time.Hour).Unix())),
+		"WrongIssuer":         signRS256(t, key, "k1", with("iss", "https://evil.example.com")),
+		"WrongAudience":       signRS256(t, key, "k1", with("aud", "other-service")),
+		"MissingSubject":      signRS256(t, key, "k1", with("sub", nil)),
+		"TamperedPayload":     signRS256(t, key, "k1", validClaims())[:10] + "x" + signRS256(t, key, "k1", validClaims())[11:],
+		"UnknownKeyIDWithKey": signRS256(t, otherKey, "k2", validClaims()),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := v.Verify(token)
+			assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+		})
+	}
+}
+
+func TestVerifyAcceptsAudienceArraysAndLeeway(t *testing.T) {
+	v, _ := NewVerifier(KeySet{HMACKey("secret")}, Options{
+		Issuer:     "https://idp.example.com",
+		Audience:   "stars",
+		Leeway:     time.Minute,
+		RoleScopes: testRoleScopes,
+	})
+	c := validClaims()
+	c["aud"] = []string{"other-service", "stars"}
+	c["exp"] = time.Now().Add(-30 * time.Second).Unix()
+
+	_, err := v.Verify(signHS256(t, []byte("secret"), c))
+
+	assert.NoError(t, err)
+}
+
+func TestVerifyReadsNestedRoles(t *testing.T) {
+	v, _ := NewVerifier(KeySet{HMACKey("secret")}, Options{
+		Issuer:     "https://idp.example.com",
+		Audience:   "stars",
+		RolesClaim: "realm_access.roles",
+		RoleScopes: testRoleScopes,
+	})
+	c := validClaims()
+	delete(c, "roles")
+	c["realm_access"] = map[string]interface{}{"roles": []string{"planets-reader", "unknown"}}
+
+	p, err := v.Verify(signHS256(t, []byte("secret"), c))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"planets:read"}, p.Scopes)
+}
+
+func TestNewVerifierRequiresKeysIssuerAndAudience(t *testing.T) {
+	_, err := NewVerifier(nil, Options{Issuer: "a", Audience: "b"})
+	assert.Error(t, err)
+
+	_, err = NewVerifier(KeySet{HMACKey("secret")}, Options{Audience: "b"})
+	assert.Error(t, err)
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	assert.True(t, LooksLikeJWT("a.b.c"))
+	assert.False(t, LooksLikeJWT("stars_0123456789abcdef"))
+}
+
+func newTestVerifier(t *testing.T, keys KeySet) *Verifier {
+	v, err := NewVerifier(keys, Options{
+		Issuer:     "https://idp.example.com",
+		Audience:   "stars",
+		RoleScopes: testRoleScopes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://idp.example.com",
+		"aud":   "stars",
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"planets-editor"},
+	}
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	input := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encodeSegment(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
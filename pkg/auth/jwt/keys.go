@@ -0,0 +1,150 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/auth"
+)
+
+// Key is a verification key, Key holds a []byte for HS256 or an *rsa.PublicKey for RS256
+type Key struct {
+	ID  string
+	Key interface{}
+}
+
+// KeySet is the set of keys trusted by the Verifier
+type KeySet []Key
+
+// candidates returns the keys which may have signed a token with the given kid
+func (ks KeySet) candidates(kid string) []Key {
+	if kid == "" {
+		return ks
+	}
+	var keys []Key
+	for _, k := range ks {
+		if k.ID == kid || k.ID == "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// HMACKey creates a HS256 key from a shared secret
+func HMACKey(secret string) Key {
+	return Key{Key: []byte(secret)}
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key, in PKIX or PKCS1 form, or a certificate
+func LoadRSAPublicKey(path string) (Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "Error reading the RSA public key")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.Errorf("No PEM data found on %s", path)
+	}
+	var pub interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return Key{}, errors.Wrap(err, "Error parsing the certificate")
+		}
+		pub = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, errors.Wrap(err, "Error parsing the RSA public key")
+	}
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return Key{}, errors.Errorf("The key on %s is not an RSA public key", path)
+	}
+	return Key{Key: rsaKey}, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		K   string `json:"k"`
+	} `json:"keys"`
+}
+
+// LoadJWKS reads the RSA and symmetric signature keys of a local JWKS file
+func LoadJWKS(path string) (KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading the JWKS file")
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "Error parsing the JWKS file")
+	}
+	var keys KeySet
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch strings.ToUpper(k.Kty) {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 {
+				return nil, errors.Errorf("Invalid RSA key at position %d of the JWKS file", i)
+			}
+			keys = append(keys, Key{ID: k.Kid, Key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}})
+		case "OCT":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return nil, errors.Errorf("Invalid symmetric key at position %d of the JWKS file", i)
+			}
+			keys = append(keys, Key{ID: k.Kid, Key: secret})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("No signature keys found on %s", path)
+	}
+	return keys, nil
+}
+
+// ParseRoleScopes parses the "role=scope,scope" entries used by the JWT_ROLE_SCOPES configuration
+func ParseRoleScopes(entries []string) (map[string][]string, error) {
+	roleScopes := map[string][]string{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("Invalid role mapping %q, the expected format is role=scope,scope", entry)
+		}
+		scopes := strings.Split(parts[1], ",")
+		for _, s := range scopes {
+			if !auth.IsValidScope(s) {
+				return nil, errors.Errorf("Unknown scope %q on role %q", s, parts[0])
+			}
+		}
+		roleScopes[parts[0]] = scopes
+	}
+	return roleScopes, nil
+}
@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRSAPublicKey(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	path := writeTempFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	k, err := LoadRSAPublicKey(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, k.Key)
+}
+
+func TestLoadRSAPublicKeyWithoutPEM(t *testing.T) {
+	path := writeTempFile(t, "key.pem", []byte("not a key"))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := LoadRSAPublicKey(path)
+
+	assert.Error(t, err)
+}
+
+func TestLoadJWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "k1", "use": "sig", "n": %q, "e": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": %q, "e": %q},
+		{"kty": "oct", "kid": "k2", "k": %q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString([]byte("secret")))
+	path := writeTempFile(t, "jwks.json", []byte(jwks))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	keys, err := LoadJWKS(path)
+
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "k1", keys[0].ID)
+		assert.Equal(t, &key.PublicKey, keys[0].Key)
+		assert.Equal(t, []byte("secret"), keys[1].Key)
+	}
+
+	v := newTestVerifier(t, keys)
+	_, err = v.Verify(signRS256(t, key, "k1", validClaims()))
+	assert.NoError(t, err)
+}
+
+func TestParseRoleScopes(t *testing.T) {
+	roleScopes, err := ParseRoleScopes([]string{"reader=planets:read", " editor=planets:read,planets:write", ""})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"reader": {"planets:read"},
+		"editor": {"planets:read", "planets:write"},
+	}, roleScopes)
+
+	_, err = ParseRoleScopes([]string{"reader"})
+	assert.Error(t, err)
+	_, err = ParseRoleScopes([]string{"reader=planets:owner"})
+	assert.Error(t, err)
+}
+
+func writeTempFile(t *testing.T, name string, data []byte) string {
+	dir, err := ioutil.TempDir("", "stars-jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
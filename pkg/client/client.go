@@ -0,0 +1,202 @@
+// Package client is the Go client of the stars API, it speaks the same planet.Planet type as the server
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const userAgent = "stars-client"
+
+// Options is the struct which carries the optional settings of the Client
+type Options struct {
+	// Token is sent as the bearer credential, an API key or a JWT
+	Token string
+	// Transport sends the requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+	// Timeout bounds each request besides the context deadline, zero means no timeout.
+	// The event streams and the imports are not bounded by it.
+	Timeout time.Duration
+}
+
+// Client is the struct which calls the stars API, it is safe for concurrent use
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	stream  *http.Client
+}
+
+// New creates a Client of the API served at baseURL, like https://stars.example.com
+func New(baseURL string, options Options) (*Client, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "the base URL is invalid")
+	}
+	if !u.IsAbs() {
+		return nil, errors.Errorf("the base URL %q must be absolute", baseURL)
+	}
+	transport := options.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Client{
+		baseURL: baseURL,
+		token:   options.Token,
+		http:    &http.Client{Transport: transport, Timeout: options.Timeout},
+		stream:  &http.Client{Transport: transport},
+	}, nil
+}
+
+// Error is the error answered by the API, Message is the error member of the body
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return "stars: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	}
+	return "stars: " + strconv.Itoa(e.StatusCode) + " " + e.Message
+}
+
+// IsNotFound reports whether err is an API answer with the 404 status
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsPreconditionFailed reports whether err is an API answer with the 412 status, the planet changed meanwhile
+func IsPreconditionFailed(err error) bool {
+	return hasStatus(err, http.StatusPreconditionFailed)
+}
+
+func hasStatus(err error, status int) bool {
+	e, ok := errors.Cause(err).(*Error)
+	return ok && e.StatusCode == status
+}
+
+// request is the description of a call, the body is encoded as JSON unless it is a reader
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        interface{}
+	contentType string
+}
+
+// newRequest builds the HTTP request, the path segments are escaped by the caller
+func (c *Client) newRequest(ctx context.Context, req request) (*http.Request, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	contentType := req.contentType
+	switch b := req.body.(type) {
+	case nil:
+	case io.Reader:
+		body = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if key := idempotencyKey(ctx); key != "" && req.method == http.MethodPost {
+		httpReq.Header.Set("Idempotency-Key", key)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", userAgent)
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return httpReq, nil
+}
+
+// do sends the request and decodes the JSON body into out, unless it is nil.
+// An answer out of the 2xx range is returned as an *Error.
+func (c *Client) do(ctx context.Context, req request, out interface{}) (*http.Response, error) {
+	httpReq, err := c.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return resp, err
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(ioutil.Discard, resp.Body)
+		return resp, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+		return resp, errors.Wrapf(err, "decoding the response of %s %s", req.method, req.path)
+	}
+	return resp, nil
+}
+
+// checkResponse turns an answer out of the 2xx range into an *Error with the message of the body
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context whose POST requests carry the key, so a retried creation is only made once
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+func idempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// OpenAPI returns the OpenAPI document of the API
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/openapi.json"}, &doc)
+	return doc, err
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/api"
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/auth/apikey"
+	"github.com/rafaelreinert/stars/pkg/config"
+	"github.com/rafaelreinert/stars/pkg/idempotency"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/rafaelreinert/stars/pkg/swapi"
+	"github.com/rafaelreinert/stars/pkg/swapi/swapitest"
+	"github.com/rafaelreinert/stars/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticCounter map[string]int
+
+func (c staticCounter) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	return c[name], nil
+}
+
+// newTestServer serves the whole API over the memory stores and a fake SWAPI, the responses are checked against the OpenAPI document
+func newTestServer(t *testing.T, keys ...apikey.Key) *httptest.Server {
+	fakeSWAPI := swapitest.NewServer(
+		planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+		planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"},
+	)
+	t.Cleanup(fakeSWAPI.Close)
+	auditLog := audit.NewMemoryStore()
+	bus := event.NewBus(100)
+	s := &api.Server{
+		PlanetRepository: event.NewRepository(audit.NewRepository(memrep.NewMemoryRepository(), auditLog), bus),
+		CountRetriever:   staticCounter{"Tatooine": 5},
+		Cfg: config.Config{
+			AllowInsecureNoAuth:      len(keys) == 0,
+			OpenAPIValidateRequests:  true,
+			OpenAPIValidateResponses: true,
+			IdempotencyWindow:        time.Hour,
+			EventsHeartbeat:          time.Second,
+		},
+		IdempotencyStore: idempotency.NewMemoryStore(),
+		AuditLog:         auditLog,
+		Events:           bus,
+		Webhooks:         webhook.NewMemoryStore(),
+		PlanetSource:     swapi.SWAPI{APIURL: fakeSWAPI.URL},
+	}
+	if len(keys) > 0 {
+		s.KeyStore = apikey.NewMemoryStore(keys...)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestClient(t *testing.T) *Client {
+	c, err := New(newTestServer(t).URL, Options{})
+	assert.NoError(t, err)
+	return c
+}
+
+func TestNewRequiresAnAbsoluteURL(t *testing.T) {
+	_, err := New("stars.example.com", Options{})
+	assert.Error(t, err)
+	_, err = New("https://stars.example.com/", Options{})
+	assert.NoError(t, err)
+}
+
+func TestTheErrorIsDecodedFromTheBody(t *testing.T) {
+	c := newTestClient(t)
+
+	_, err := c.GetPlanet(context.Background(), "missing")
+
+	assert.True(t, IsNotFound(err))
+	apiErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "planet not found", apiErr.Message)
+	assert.Equal(t, "stars: 404 planet not found", err.Error())
+}
+
+func TestTheTokenIsSent(t *testing.T) {
+	keys, err := apikey.ParseKeys([]string{"ops:s3cr3t:planets:read,planets:write"})
+	assert.NoError(t, err)
+	ts := newTestServer(t, keys...)
+
+	anonymous, _ := New(ts.URL, Options{})
+	_, err = anonymous.ListPlanets(context.Background())
+	assert.True(t, hasStatus(err, http.StatusUnauthorized), "The request without a token should be refused")
+
+	c, _ := New(ts.URL, Options{Token: "s3cr3t"})
+	_, err = c.CreatePlanet(context.Background(), planet.Planet{Name: "Hoth"})
+	assert.NoError(t, err)
+	_, err = c.ListDeletedPlanets(context.Background())
+	assert.True(t, hasStatus(err, http.StatusForbidden), "The admin routes should need the admin scope")
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestTheTransportIsPluggable(t *testing.T) {
+	transport := &countingTransport{}
+	c, _ := New(newTestServer(t).URL, Options{Transport: transport})
+
+	_, err := c.ListPlanets(context.Background())
+	assert.NoError(t, err)
+	_, err = c.OpenAPI(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&transport.requests))
+}
+
+func TestTheContextCancelsTheRequest(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.ListPlanets(ctx)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+}
+
+func TestTheIdempotencyKeyIsSent(t *testing.T) {
+	c := newTestClient(t)
+	ctx := WithIdempotencyKey(context.Background(), "create-hoth")
+
+	first, err := c.CreatePlanet(ctx, planet.Planet{Name: "Hoth"})
+	assert.NoError(t, err)
+	second, err := c.CreatePlanet(ctx, planet.Planet{Name: "Hoth"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, first.ID, second.ID, "The retried creation should be replayed")
+	planets, _ := c.ListPlanets(context.Background())
+	assert.Len(t, planets, 1)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+)
+
+// EventReset is the type of the event sent when the missed events are gone, the planets must be reloaded
+const EventReset = "reset"
+
+// EventStream is the stream of the planet changes, it must be closed
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	lastID  string
+}
+
+// StreamEvents opens the stream of the planet changes, the events after lastEventID are replayed first
+// when the server still has them, otherwise the stream starts with an EventReset
+func (c *Client) StreamEvents(ctx context.Context, lastEventID string) (*EventStream, error) {
+	req, err := c.newRequest(ctx, request{method: http.MethodGet, path: "/planets/events"})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &EventStream{body: resp.Body, scanner: newEventScanner(resp.Body), lastID: lastEventID}, nil
+}
+
+// newEventScanner reads the stream line by line, a line carries at most one event data of up to 1MB
+func newEventScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	return scanner
+}
+
+// Next blocks until the next event, it returns io.EOF when the server ends the stream,
+// then the stream can be opened again with LastEventID
+func (s *EventStream) Next() (event.Event, error) {
+	var e event.Event
+	var eventType, data string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if eventType == "" && data == "" {
+				continue
+			}
+			if eventType == EventReset {
+				return event.Event{Type: EventReset}, nil
+			}
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				return event.Event{}, errors.Wrap(err, "decoding the event")
+			}
+			if e.ID != "" {
+				s.lastID = e.ID
+			}
+			return e, nil
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if data != "" {
+				data += "\n"
+			}
+			data += value
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{}, io.EOF
+}
+
+// LastEventID is the id of the last event read, to resume the stream
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Close ends the stream
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
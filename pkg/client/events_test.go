@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamEvents(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := c.StreamEvents(ctx, "")
+	assert.NoError(t, err)
+	defer stream.Close()
+	created, err := c.CreatePlanet(ctx, planet.Planet{Name: "Hoth"})
+	assert.NoError(t, err)
+
+	e, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, event.TypeCreated, e.Type)
+	assert.Equal(t, created.ID, e.PlanetID)
+	assert.Equal(t, "Hoth", e.Planet.Name)
+	assert.Equal(t, e.ID, stream.LastEventID())
+}
+
+func TestStreamEventsResumesAfterTheLastEvent(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, _ := c.StreamEvents(ctx, "")
+	c.CreatePlanet(ctx, planet.Planet{Name: "Hoth"})
+	first, _ := stream.Next()
+	stream.Close()
+
+	c.CreatePlanet(ctx, planet.Planet{Name: "Dagobah"})
+	stream, err := c.StreamEvents(ctx, first.ID)
+	assert.NoError(t, err)
+	defer stream.Close()
+
+	e, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "Dagobah", e.Planet.Name)
+}
+
+func TestStreamEventsTellsTheEventsWereLost(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := c.StreamEvents(ctx, "unknown-1")
+	assert.NoError(t, err)
+	defer stream.Close()
+
+	e, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, EventReset, e.Type)
+}
+
+func TestEventStreamParsing(t *testing.T) {
+	body := "retry: 3000\n\n: ping\n\nid: 7\nevent: planet.deleted\ndata: {\"id\":\"7\",\"type\":\"planet.deleted\",\n" +
+		"data: \"planetId\":\"42\"}\n\n"
+	stream := &EventStream{body: ioutil.NopCloser(strings.NewReader(body))}
+	stream.scanner = newEventScanner(stream.body)
+
+	e, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, event.Event{ID: "7", Type: event.TypeDeleted, PlanetID: "42"}, e)
+
+	_, err = stream.Next()
+	assert.Equal(t, io.EOF, err)
+}
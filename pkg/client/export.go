@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ExportOptions is the struct which configures ExportPlanets
+type ExportOptions struct {
+	// Format is exporter.FormatNDJSON, the default, or exporter.FormatCSV
+	Format string
+	// Counts fills the number of appearances on movies from SWAPI, which is slower
+	Counts bool
+}
+
+// ExportPlanets streams every planet in the format, the body must be closed.
+// The transport asks for gzip and decompresses it, the exports are not bounded by Options.Timeout, only by the context.
+func (c *Client) ExportPlanets(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	if options.Format != "" {
+		query.Set("format", options.Format)
+	}
+	if options.Counts {
+		query.Set("counts", "true")
+	}
+	req, err := c.newRequest(ctx, request{method: http.MethodGet, path: "/planets:export", query: query})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson, text/csv, application/json")
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+)
+
+// importContentTypes is the Content-Type sent with each import format
+var importContentTypes = map[string]string{
+	importer.FormatJSON:   "application/json",
+	importer.FormatNDJSON: "application/x-ndjson",
+	importer.FormatCSV:    "text/csv",
+}
+
+// ImportOptions is the struct which configures ImportPlanets
+type ImportOptions struct {
+	// Format is the format of the file: importer.FormatJSON, importer.FormatNDJSON or importer.FormatCSV
+	Format string
+	// DryRun validates and counts the rows without writing them
+	DryRun bool
+	// Upsert updates the planet which already has the row name instead of creating another one
+	Upsert bool
+	// Columns maps the CSV header names to the name, climate and terrain fields
+	Columns map[string]string
+}
+
+// ImportPlanets streams the file to the API, which writes it a batch at a time, the failed rows are on the report.
+// When the import stops before the end the error is an *Error and the report has the rows written before it.
+// The imports are not bounded by Options.Timeout, only by the context.
+func (c *Client) ImportPlanets(ctx context.Context, r io.Reader, options ImportOptions) (importer.Report, error) {
+	contentType, ok := importContentTypes[options.Format]
+	if !ok {
+		return importer.Report{}, errors.Errorf("unknown import format %q, use json, ndjson or csv", options.Format)
+	}
+	query := url.Values{}
+	if options.DryRun {
+		query.Set("dryRun", "true")
+	}
+	if options.Upsert {
+		query.Set("mode", "upsert")
+	}
+	if len(options.Columns) > 0 {
+		mapping := make([]string, 0, len(options.Columns))
+		for column, field := range options.Columns {
+			mapping = append(mapping, column+":"+field)
+		}
+		sort.Strings(mapping)
+		query.Set("columns", strings.Join(mapping, ","))
+	}
+
+	req, err := c.newRequest(ctx, request{method: http.MethodPost, path: "/planets:import", query: query, body: r, contentType: contentType})
+	if err != nil {
+		return importer.Report{}, err
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return importer.Report{}, err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		importer.Report
+		Error string `json:"error"`
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnprocessableEntity {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return importer.Report{}, err
+		}
+		if err := json.Unmarshal(data, &response); err != nil && resp.StatusCode == http.StatusOK {
+			return importer.Report{}, errors.Wrap(err, "decoding the import report")
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	return response.Report, checkResponse(resp)
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+// BatchOperation is one change of a Batch, ID is required by the updates and the deletes
+type BatchOperation struct {
+	Op     repository.BulkOperationType `json:"op"`
+	ID     string                       `json:"id,omitempty"`
+	Planet planet.Planet                `json:"planet"`
+}
+
+// BatchResult is the outcome of the BatchOperation on the same position, Status is the one of the single request
+type BatchResult struct {
+	Op     repository.BulkOperationType `json:"op"`
+	ID     string                       `json:"id,omitempty"`
+	Status int                          `json:"status"`
+	Error  string                       `json:"error,omitempty"`
+}
+
+func planetPath(id string) string {
+	return "/planets/" + url.PathEscape(id)
+}
+
+// ListPlanets returns every planet with its appearances on movies, use Planets to walk a large catalogue by pages
+func (c *Client) ListPlanets(ctx context.Context) ([]planet.Planet, error) {
+	var planets []planet.Planet
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/planets"}, &planets)
+	return planets, err
+}
+
+// ListPlanetsPage returns up to limit planets after the given id, ordered by id, and the id to ask the next page with.
+// The next id is empty on the last page.
+func (c *Client) ListPlanetsPage(ctx context.Context, after string, limit int) ([]planet.Planet, string, error) {
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if after != "" {
+		query.Set("after", after)
+	}
+	var planets []planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/planets", query: query}, &planets)
+	if err != nil {
+		return nil, "", err
+	}
+	return planets, nextAfter(resp.Header.Get("Link")), nil
+}
+
+var nextLinkPattern = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// nextAfter reads the after parameter of the next page out of the Link header
+func nextAfter(link string) string {
+	match := nextLinkPattern.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	next, err := url.Parse(match[1])
+	if err != nil {
+		return ""
+	}
+	return next.Query().Get("after")
+}
+
+// FindPlanetByName returns the planet with the exact name
+func (c *Client) FindPlanetByName(ctx context.Context, name string) (planet.Planet, error) {
+	var p planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/planets", query: url.Values{"name": {name}}}, &p)
+	return withVersion(p, resp, err)
+}
+
+// GetPlanet returns the planet, its Version is read from the ETag so it can be given back to the conditional changes
+func (c *Client) GetPlanet(ctx context.Context, id string) (planet.Planet, error) {
+	var p planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: planetPath(id)}, &p)
+	return withVersion(p, resp, err)
+}
+
+// CreatePlanet creates the planet and returns it with its id, see WithIdempotencyKey to retry it safely
+func (c *Client) CreatePlanet(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	var created planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodPost, path: "/planets", body: p}, &created)
+	return withVersion(created, resp, err)
+}
+
+// UpdatePlanet replaces the planet, it is only applied while the planet is on p.Version unless it is zero
+func (c *Client) UpdatePlanet(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	var updated planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodPut, path: planetPath(p.ID), header: ifMatch(p.Version), body: p}, &updated)
+	return withVersion(updated, resp, err)
+}
+
+// PatchPlanet changes only the fields set on the update, as a JSON merge patch, under the same version rule as UpdatePlanet
+func (c *Client) PatchPlanet(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	patch := map[string]*string{}
+	if u.Name != nil {
+		patch["name"] = u.Name
+	}
+	if u.Climate != nil {
+		patch["climate"] = u.Climate
+	}
+	if u.Terrain != nil {
+		patch["terrain"] = u.Terrain
+	}
+	var patched planet.Planet
+	req := request{method: http.MethodPatch, path: planetPath(id), header: ifMatch(u.Version), body: patch, contentType: "application/merge-patch+json"}
+	resp, err := c.do(ctx, req, &patched)
+	return withVersion(patched, resp, err)
+}
+
+// DeletePlanet deletes the planet, it is only deleted while it is on the version unless it is zero
+func (c *Client) DeletePlanet(ctx context.Context, id string, version int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: planetPath(id), header: ifMatch(version)}, nil)
+	return err
+}
+
+// ListDeletedPlanets returns the deleted planets which can still be restored, the last deleted first
+func (c *Client) ListDeletedPlanets(ctx context.Context) ([]planet.Planet, error) {
+	var planets []planet.Planet
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/planets:deleted"}, &planets)
+	return planets, err
+}
+
+// RestorePlanet brings back a deleted planet
+func (c *Client) RestorePlanet(ctx context.Context, id string) (planet.Planet, error) {
+	var restored planet.Planet
+	resp, err := c.do(ctx, request{method: http.MethodPost, path: planetPath(id) + ":restore"}, &restored)
+	return withVersion(restored, resp, err)
+}
+
+// PlanetHistory returns the audit trail of the planet, the oldest change first
+func (c *Client) PlanetHistory(ctx context.Context, id string) ([]audit.Entry, error) {
+	var entries []audit.Entry
+	_, err := c.do(ctx, request{method: http.MethodGet, path: planetPath(id) + "/history"}, &entries)
+	return entries, err
+}
+
+// Batch runs up to 1000 operations in one request, when ordered it stops on the first failure.
+// Each operation failure is on its result, the error is only returned when the whole batch failed.
+func (c *Client) Batch(ctx context.Context, ops []BatchOperation, ordered bool) ([]BatchResult, error) {
+	body := struct {
+		Ordered    bool             `json:"ordered"`
+		Operations []BatchOperation `json:"operations"`
+	}{ordered, ops}
+	var response struct {
+		Results []BatchResult `json:"results"`
+	}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/planets:batch", body: body}, &response)
+	return response.Results, err
+}
+
+// ifMatch is the header of a change conditioned to the version, none when the version is zero
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+// withVersion sets the planet version out of the ETag of the response
+func withVersion(p planet.Planet, resp *http.Response, err error) (planet.Planet, error) {
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	tag := strings.Trim(strings.TrimPrefix(resp.Header.Get("ETag"), "W/"), `"`)
+	if version, err := strconv.ParseInt(tag, 10, 64); err == nil {
+		p.Version = version
+	}
+	return p, nil
+}
+
+// PlanetIterator walks the planets page by page, it fetches the next page when the current one is consumed:
+//
+//	it := c.Planets(ctx, 100)
+//	for it.Next() {
+//		p := it.Planet()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type PlanetIterator struct {
+	ctx      context.Context
+	client   *Client
+	pageSize int
+	page     []planet.Planet
+	current  planet.Planet
+	after    string
+	last     bool
+	err      error
+}
+
+// Planets returns an iterator over every planet, ordered by id, fetched pageSize at a time
+func (c *Client) Planets(ctx context.Context, pageSize int) *PlanetIterator {
+	return &PlanetIterator{ctx: ctx, client: c, pageSize: pageSize}
+}
+
+// Next advances to the next planet, it returns false when there is no planet left or a page failed
+func (it *PlanetIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.page, it.after, it.err = it.client.ListPlanetsPage(it.ctx, it.after, it.pageSize)
+		it.last = it.after == ""
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Planet returns the planet Next advanced to
+func (it *PlanetIterator) Planet() planet.Planet {
+	return it.current
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *PlanetIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/audit"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/exporter"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	created, err := c.CreatePlanet(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, int64(1), created.Version, "The version should be read from the ETag")
+
+	found, err := c.GetPlanet(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, found.NumberOfAppearancesOnMovies)
+	assert.Equal(t, int64(1), found.Version)
+	found, err = c.FindPlanetByName(ctx, "Tatooine")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID)
+
+	found.Climate = "hot"
+	updated, err := c.UpdatePlanet(ctx, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "hot", updated.Climate)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = c.UpdatePlanet(ctx, found)
+	assert.True(t, IsPreconditionFailed(err), "The update of a stale version should be refused")
+
+	terrain := "dunes"
+	patched, err := c.PatchPlanet(ctx, created.ID, planet.Update{Version: updated.Version, Terrain: &terrain})
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{ID: created.ID, Name: "Tatooine", Climate: "hot", Terrain: "dunes", Version: 3}, patched)
+
+	assert.True(t, IsPreconditionFailed(c.DeletePlanet(ctx, created.ID, 1)))
+	assert.NoError(t, c.DeletePlanet(ctx, created.ID, patched.Version))
+	_, err = c.GetPlanet(ctx, created.ID)
+	assert.True(t, IsNotFound(err))
+
+	deleted, err := c.ListDeletedPlanets(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	restored, err := c.RestorePlanet(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), restored.Version)
+
+	history, err := c.PlanetHistory(ctx, created.ID)
+	assert.NoError(t, err)
+	actions := make([]audit.Action, len(history))
+	for i, e := range history {
+		actions[i] = e.Action
+	}
+	assert.Equal(t, []audit.Action{audit.ActionCreate, audit.ActionUpdate, audit.ActionUpdate, audit.ActionDelete, audit.ActionRestore}, actions)
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	existing, _ := c.CreatePlanet(ctx, planet.Planet{Name: "Alderaan"})
+
+	results, err := c.Batch(ctx, []BatchOperation{
+		{Op: repository.BulkCreate, Planet: planet.Planet{Name: "Hoth"}},
+		{Op: repository.BulkDelete, ID: existing.ID},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, BatchResult{Op: repository.BulkDelete, ID: existing.ID, Status: http.StatusOK}, results[1])
+	planets, _ := c.ListPlanets(ctx)
+	assert.Len(t, planets, 1)
+	assert.Equal(t, "Hoth", planets[0].Name)
+
+	_, err = c.Batch(ctx, nil, true)
+	assert.True(t, hasStatus(err, http.StatusBadRequest), "An empty batch should be refused")
+}
+
+func TestPlanetsIteratesEveryPage(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	var names []string
+	for i := 0; i < 7; i++ {
+		name := "Planet " + strconv.Itoa(i)
+		names = append(names, name)
+		_, err := c.CreatePlanet(ctx, planet.Planet{Name: name})
+		assert.NoError(t, err)
+	}
+
+	it := c.Planets(ctx, 3)
+	var iterated []string
+	for it.Next() {
+		iterated = append(iterated, it.Planet().Name)
+	}
+
+	assert.NoError(t, it.Err())
+	assert.Equal(t, names, iterated)
+}
+
+func TestPlanetsIteratesAnExactNumberOfPages(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	c.CreatePlanet(ctx, planet.Planet{Name: "Tatooine"})
+	c.CreatePlanet(ctx, planet.Planet{Name: "Hoth"})
+
+	page, next, err := c.ListPlanetsPage(ctx, "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	assert.Equal(t, page[1].ID, next, "A full page should link to the next one")
+	page, next, err = c.ListPlanetsPage(ctx, next, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+	assert.Empty(t, next)
+
+	it := c.Planets(ctx, 2)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, 2, count)
+}
+
+func TestPlanetsStopsOnTheFirstError(t *testing.T) {
+	it := newTestClient(t).Planets(context.Background(), 1000)
+
+	assert.False(t, it.Next())
+	assert.True(t, hasStatus(it.Err(), http.StatusBadRequest), "The page size is above the server limit")
+	assert.False(t, it.Next())
+}
+
+func TestNextAfter(t *testing.T) {
+	assert.Equal(t, "5", nextAfter(`</planets?after=5&limit=2>; rel="next"`))
+	assert.Equal(t, "a b", nextAfter(`<https://stars.example.com/planets?after=a+b>;rel=next`))
+	assert.Empty(t, nextAfter(`</planets?after=5>; rel="prev"`))
+	assert.Empty(t, nextAfter(""))
+}
+
+func TestImportPlanets(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	c.CreatePlanet(ctx, planet.Planet{Name: "Hoth", Climate: "temperate"})
+
+	report, err := c.ImportPlanets(ctx, strings.NewReader("Planet,Weather\nHoth,frozen\nDagobah,murky\n,arid\n"), ImportOptions{
+		Format:  importer.FormatCSV,
+		Upsert:  true,
+		Columns: map[string]string{"Planet": "name", "Weather": "climate"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, importer.Report{Created: 1, Updated: 1, Failed: 1, Errors: []importer.RowError{{Row: 3, Error: "the planet name is required"}}}, report)
+	hoth, _ := c.FindPlanetByName(ctx, "Hoth")
+	assert.Equal(t, "frozen", hoth.Climate)
+}
+
+func TestImportPlanetsReturnsTheReportOfAStoppedImport(t *testing.T) {
+	c := newTestClient(t)
+
+	report, err := c.ImportPlanets(context.Background(), strings.NewReader("{\"name\": \"Hoth\"}\n"+strings.Repeat("x", 2<<20)), ImportOptions{Format: importer.FormatNDJSON})
+
+	assert.Error(t, err)
+	assert.True(t, hasStatus(err, http.StatusUnprocessableEntity))
+	assert.Equal(t, 0, report.Created)
+
+	_, err = c.ImportPlanets(context.Background(), strings.NewReader(""), ImportOptions{Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestExportPlanets(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	hoth, _ := c.CreatePlanet(ctx, planet.Planet{Name: "Hoth", Climate: "frozen"})
+	tatooine, _ := c.CreatePlanet(ctx, planet.Planet{Name: "Tatooine"})
+
+	body, err := c.ExportPlanets(ctx, ExportOptions{Format: exporter.FormatCSV, Counts: true})
+	assert.NoError(t, err)
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,climate,terrain,version,numberOfAppearancesOnMovies\n"+
+		hoth.ID+",Hoth,frozen,,1,0\n"+
+		tatooine.ID+",Tatooine,,,1,5\n", string(data))
+
+	_, err = c.ExportPlanets(ctx, ExportOptions{Format: "xml"})
+	assert.True(t, hasStatus(err, http.StatusBadRequest))
+}
+
+func TestSyncSWAPI(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	tatooine, _ := c.CreatePlanet(ctx, planet.Planet{Name: "Tatooine", Climate: "hot"})
+
+	report, err := c.SyncSWAPI(ctx, swapisync.ModeReport)
+	assert.NoError(t, err)
+	assert.Equal(t, swapisync.Report{Mode: swapisync.ModeReport, Created: 1, Updated: 1, Changes: []swapisync.Change{
+		{Name: "Tatooine", ID: tatooine.ID, Action: swapisync.ActionUpdate, Fields: []swapisync.FieldDiff{
+			{Field: "climate", Local: "hot", SWAPI: "arid"},
+			{Field: "terrain", SWAPI: "desert"},
+		}},
+		{Name: "Hoth", Action: swapisync.ActionCreate},
+	}}, report)
+
+	report, err = c.SyncSWAPI(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, swapisync.ModeMerge, report.Mode)
+	tatooine, _ = c.FindPlanetByName(ctx, "Tatooine")
+	assert.Equal(t, "hot", tatooine.Climate)
+	assert.Equal(t, "desert", tatooine.Terrain)
+	_, err = c.FindPlanetByName(ctx, "Hoth")
+	assert.NoError(t, err)
+
+	_, err = c.SyncSWAPI(ctx, "replace")
+	assert.True(t, hasStatus(err, http.StatusBadRequest))
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet/swapisync"
+)
+
+// SyncSWAPI creates and updates the local planets out of the SWAPI catalogue, the mode is swapisync.ModeMerge when empty.
+// When SWAPI could not be read the error is an *Error and the report has the changes made before it.
+// The syncs are not bounded by Options.Timeout, only by the context.
+func (c *Client) SyncSWAPI(ctx context.Context, mode string) (swapisync.Report, error) {
+	query := url.Values{}
+	if mode != "" {
+		query.Set("mode", mode)
+	}
+	req, err := c.newRequest(ctx, request{method: http.MethodPost, path: "/planets:sync", query: query})
+	if err != nil {
+		return swapisync.Report{}, err
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return swapisync.Report{}, err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		swapisync.Report
+		Error string `json:"error"`
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusBadGateway {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return swapisync.Report{}, err
+		}
+		if err := json.Unmarshal(data, &response); err != nil && resp.StatusCode == http.StatusOK {
+			return swapisync.Report{}, errors.Wrap(err, "decoding the sync report")
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	return response.Report, checkResponse(resp)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/rafaelreinert/stars/pkg/webhook"
+)
+
+func webhookPath(id string) string {
+	return "/webhooks/" + url.PathEscape(id)
+}
+
+// webhookRequest is the body of the webhook create and update
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
+
+func newWebhookRequest(sub webhook.Subscription) webhookRequest {
+	return webhookRequest{URL: sub.URL, Events: sub.Events, Active: sub.Active}
+}
+
+// CreateWebhook subscribes the URL to the event types of sub, no type means every type.
+// The returned subscription carries the signing secret, which is never shown again.
+func (c *Client) CreateWebhook(ctx context.Context, sub webhook.Subscription) (webhook.Subscription, error) {
+	var created webhook.Subscription
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks", body: newWebhookRequest(sub)}, &created)
+	return created, err
+}
+
+// ListWebhooks returns the webhook subscriptions, without their secrets
+func (c *Client) ListWebhooks(ctx context.Context) ([]webhook.Subscription, error) {
+	var subs []webhook.Subscription
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks"}, &subs)
+	return subs, err
+}
+
+// GetWebhook returns the webhook subscription, without its secret
+func (c *Client) GetWebhook(ctx context.Context, id string) (webhook.Subscription, error) {
+	var sub webhook.Subscription
+	_, err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id)}, &sub)
+	return sub, err
+}
+
+// UpdateWebhook replaces the URL, the event types and the active flag of the subscription, the secret is kept
+func (c *Client) UpdateWebhook(ctx context.Context, sub webhook.Subscription) (webhook.Subscription, error) {
+	var updated webhook.Subscription
+	_, err := c.do(ctx, request{method: http.MethodPut, path: webhookPath(sub.ID), body: newWebhookRequest(sub)}, &updated)
+	return updated, err
+}
+
+// DeleteWebhook removes the subscription, its pending deliveries go to the dead letter
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: webhookPath(id)}, nil)
+	return err
+}
+
+// ListDeliveries returns up to limit deliveries of the subscription, the newest first, zero asks the server default
+func (c *Client) ListDeliveries(ctx context.Context, id string, limit int) ([]webhook.Delivery, error) {
+	var query url.Values
+	if limit > 0 {
+		query = url.Values{"limit": {strconv.Itoa(limit)}}
+	}
+	var deliveries []webhook.Delivery
+	_, err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id) + "/deliveries", query: query}, &deliveries)
+	return deliveries, err
+}
+
+// RetryDelivery takes a dead delivery out of the dead letter, it gets all its attempts again
+func (c *Client) RetryDelivery(ctx context.Context, id, deliveryID string) (webhook.Delivery, error) {
+	var delivery webhook.Delivery
+	path := webhookPath(id) + "/deliveries/" + url.PathEscape(deliveryID) + ":retry"
+	_, err := c.do(ctx, request{method: http.MethodPost, path: path}, &delivery)
+	return delivery, err
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	created, err := c.CreateWebhook(ctx, webhook.Subscription{URL: "https://example.com/hook", Events: []string{"planet.created"}, Active: true})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Secret, "whsec_"), "The secret should be shown on the creation")
+
+	found, err := c.GetWebhook(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Secret)
+	assert.Equal(t, []string{"planet.created"}, found.Events)
+
+	found.Active = false
+	found.URL = "https://example.com/other"
+	updated, err := c.UpdateWebhook(ctx, found)
+	assert.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.Equal(t, "https://example.com/other", updated.URL)
+
+	subs, err := c.ListWebhooks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, subs, 1)
+
+	deliveries, err := c.ListDeliveries(ctx, created.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+	_, err = c.RetryDelivery(ctx, created.ID, "missing")
+	assert.True(t, IsNotFound(err))
+
+	assert.NoError(t, c.DeleteWebhook(ctx, created.ID))
+	_, err = c.GetWebhook(ctx, created.ID)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestCreateWebhookWithAnInvalidURL(t *testing.T) {
+	_, err := newTestClient(t).CreateWebhook(context.Background(), webhook.Subscription{URL: "not a url", Active: true})
+
+	assert.True(t, hasStatus(err, http.StatusBadRequest))
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/caarlos0/env"
 	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/tlsreload"
 )
 
 // Config is the struct which carries all configurable values to startup the application
@@ -10,6 +15,76 @@ type Config struct {
 	Port     int    `env:"PORT" envDefault:"8080"`
 	DBURI    string `env:"DB_URI" envDefault:"mongodb://localhost:27017"`
 	SWAPIURL string `env:"SWAPI_URL" envDefault:"https://swapi.dev/api"`
+	// ReadHandlerTimeout is the time budget of the handlers which only read planets, including the SWAPI lookups
+	ReadHandlerTimeout time.Duration `env:"HANDLER_READ_TIMEOUT" envDefault:"20s"`
+	// WriteHandlerTimeout is the time budget of the handlers which create, update or delete planets
+	WriteHandlerTimeout time.Duration `env:"HANDLER_WRITE_TIMEOUT" envDefault:"20s"`
+	// ImportHandlerTimeout is the time budget of the imports, which read the whole file, and of the SWAPI syncs
+	ImportHandlerTimeout time.Duration `env:"HANDLER_IMPORT_TIMEOUT" envDefault:"10m"`
+	// APIKeyStore selects where the API keys are kept: mongo, memory or none
+	APIKeyStore string   `env:"API_KEY_STORE" envDefault:"mongo"`
+	APIKeys     []string `env:"API_KEYS" envSeparator:";"`
+	// JWTHS256Secret, JWTRS256PublicKeyFile and JWTJWKSFile are the keys trusted to sign JWTs, JWTs are refused when none is set
+	JWTHS256Secret        string        `env:"JWT_HS256_SECRET"`
+	JWTRS256PublicKeyFile string        `env:"JWT_RS256_PUBLIC_KEY_FILE"`
+	JWTJWKSFile           string        `env:"JWT_JWKS_FILE"`
+	JWTIssuer             string        `env:"JWT_ISSUER"`
+	JWTAudience           string        `env:"JWT_AUDIENCE"`
+	JWTLeeway             time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`
+	JWTRolesClaim         string        `env:"JWT_ROLES_CLAIM" envDefault:"roles"`
+	// JWTRoleScopes maps the token roles to scopes, in the "role=scope,scope" format separated by ";"
+	JWTRoleScopes []string `env:"JWT_ROLE_SCOPES" envSeparator:";" envDefault:"planets-reader=planets:read;planets-editor=planets:read,planets:write;planets-admin=planets:read,planets:write,planets:admin"`
+	// RateLimitReadRPS and RateLimitWriteRPS are the requests per second allowed to each API key or client IP, zero disables the limit
+	RateLimitReadRPS    float64 `env:"RATE_LIMIT_READ_RPS" envDefault:"10"`
+	RateLimitReadBurst  int     `env:"RATE_LIMIT_READ_BURST" envDefault:"20"`
+	RateLimitWriteRPS   float64 `env:"RATE_LIMIT_WRITE_RPS" envDefault:"2"`
+	RateLimitWriteBurst int     `env:"RATE_LIMIT_WRITE_BURST" envDefault:"5"`
+	// RateLimitAuthFailureRPS and RateLimitAuthFailureBurst are the failed authentications allowed to each client IP, zero disables the limit
+	RateLimitAuthFailureRPS   float64 `env:"RATE_LIMIT_AUTH_FAILURE_RPS" envDefault:"0.2"`
+	RateLimitAuthFailureBurst int     `env:"RATE_LIMIT_AUTH_FAILURE_BURST" envDefault:"10"`
+	// CORSAllowedOrigins lists the browser origins allowed to call the API, CORS is disabled when it is empty
+	CORSAllowedOrigins   []string `env:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string `env:"CORS_ALLOWED_METHODS" envDefault:"GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS"`
+	CORSAllowedHeaders   []string `env:"CORS_ALLOWED_HEADERS" envDefault:"Authorization,Content-Type,X-Requested-With,If-Match,If-None-Match,Idempotency-Key,X-Request-ID,Last-Event-ID"`
+	CORSExposedHeaders   []string `env:"CORS_EXPOSED_HEADERS" envDefault:"RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After,ETag,Idempotent-Replayed,X-Request-ID,Link"`
+	CORSAllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS" envDefault:"false"`
+	// CORSMaxAge is how long, in seconds, browsers may cache a preflight response, browsers cap it at 600
+	CORSMaxAge int `env:"CORS_MAX_AGE" envDefault:"600"`
+	// TLSCertFile and TLSKeyFile enable HTTPS, the files are reloaded every TLSReloadInterval when they change
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSClientCAFile   string        `env:"TLS_CLIENT_CA_FILE"`
+	TLSMinVersion     string        `env:"TLS_MIN_VERSION" envDefault:"1.2"`
+	TLSClientAuth     string        `env:"TLS_CLIENT_AUTH" envDefault:"none"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" envDefault:"30s"`
+	// RequireIfMatch refuses PUT, PATCH and DELETE without an If-Match header, so no change can overwrite an unseen one
+	RequireIfMatch bool `env:"REQUIRE_IF_MATCH" envDefault:"false"`
+	// IdempotencyWindow is how long the response of a POST sent with an Idempotency-Key is replayed, zero disables the header
+	IdempotencyWindow time.Duration `env:"IDEMPOTENCY_WINDOW" envDefault:"24h"`
+	// SoftDeleteRetention is how long a deleted planet can be restored before the purge removes it, zero disables the purge
+	SoftDeleteRetention time.Duration `env:"SOFT_DELETE_RETENTION" envDefault:"720h"`
+	PurgeInterval       time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+	// EventsBuffer is how many of the last planet events are kept to resume the streams with Last-Event-ID
+	EventsBuffer int `env:"EVENTS_BUFFER" envDefault:"1000"`
+	// EventsHeartbeat is the interval of the comments which keep the idle event streams open through proxies
+	EventsHeartbeat time.Duration `env:"EVENTS_HEARTBEAT" envDefault:"15s"`
+	// OutboxEnabled writes the planet events with the changes in a MongoDB transaction, so no event is lost on a crash.
+	// It requires MongoDB running as a replica set.
+	OutboxEnabled      bool          `env:"OUTBOX_ENABLED" envDefault:"false"`
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	// WebhookMaxAttempts is how many times a webhook delivery is sent before it goes to the dead letter
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	// WebhookBackoff is the wait after the first failed delivery, it doubles on each failure up to WebhookMaxBackoff
+	WebhookBackoff      time.Duration `env:"WEBHOOK_BACKOFF" envDefault:"30s"`
+	WebhookMaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" envDefault:"1h"`
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"5s"`
+	// OpenAPIValidateRequests refuses the requests which do not match the OpenAPI document served at /openapi.json
+	OpenAPIValidateRequests bool `env:"OPENAPI_VALIDATE_REQUESTS" envDefault:"false"`
+	// OpenAPIValidateResponses replaces the responses which do not match the OpenAPI document by a 500, for tests and development
+	OpenAPIValidateResponses bool `env:"OPENAPI_VALIDATE_RESPONSES" envDefault:"false"`
+	// AllowInsecureNoAuth must be set to run the API without authentication, API_KEY_STORE=none is refused otherwise
+	AllowInsecureNoAuth bool `env:"ALLOW_INSECURE_NO_AUTH" envDefault:"false"`
 }
 
 // New return a New Config struct filled with the environment variables values or default values
@@ -18,5 +93,107 @@ func New() (Config, error) {
 	if err := env.Parse(&cfg); err != nil {
 		return Config{}, errors.Wrap(err, "Error during the environment variables parse")
 	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
+
+// Validate checks the combinations of values which can not work together
+func (c Config) Validate() error {
+	if c.JWTEnabled() && (c.JWTIssuer == "" || c.JWTAudience == "") {
+		return errors.New("JWT_ISSUER and JWT_AUDIENCE are required when a JWT key is configured")
+	}
+	if c.APIKeyStore == "none" && !c.JWTEnabled() && !c.AllowInsecureNoAuth {
+		return errors.New("API_KEY_STORE=none without a JWT key disables the authentication, set ALLOW_INSECURE_NO_AUTH=true to confirm it")
+	}
+	if c.IdempotencyWindow < 0 {
+		return errors.New("IDEMPOTENCY_WINDOW can not be negative")
+	}
+	if c.EventsBuffer < 0 {
+		return errors.New("EVENTS_BUFFER can not be negative")
+	}
+	if c.SoftDeleteRetention < 0 {
+		return errors.New("SOFT_DELETE_RETENTION can not be negative")
+	}
+	if c.SoftDeleteRetention > 0 && c.PurgeInterval <= 0 {
+		return errors.New("PURGE_INTERVAL must be positive while SOFT_DELETE_RETENTION is set")
+	}
+	if c.OutboxEnabled && c.OutboxPollInterval <= 0 {
+		return errors.New("OUTBOX_POLL_INTERVAL must be positive while OUTBOX_ENABLED is set")
+	}
+	if c.WebhookMaxAttempts < 0 || c.WebhookBackoff < 0 || c.WebhookMaxBackoff < 0 || c.WebhookTimeout < 0 || c.WebhookPollInterval < 0 {
+		return errors.New("The WEBHOOK_* values can not be negative")
+	}
+	if err := c.validateCORS(); err != nil {
+		return err
+	}
+	return c.validateTLS()
+}
+
+func (c Config) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if !c.TLSEnabled() {
+		if c.TLSClientCAFile != "" || (c.TLSClientAuth != "" && c.TLSClientAuth != "none") {
+			return errors.New("Client certificates require TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil
+	}
+	if _, ok := tlsreload.Versions[c.TLSMinVersion]; !ok {
+		return errors.Errorf("Invalid TLS_MIN_VERSION %q, use 1.2 or 1.3", c.TLSMinVersion)
+	}
+	if _, ok := tlsreload.ClientAuthTypes[c.TLSClientAuth]; !ok {
+		return errors.Errorf("Invalid TLS_CLIENT_AUTH %q, use none, request, verify or require", c.TLSClientAuth)
+	}
+	if (c.TLSClientAuth == "verify" || c.TLSClientAuth == "require") && c.TLSClientCAFile == "" {
+		return errors.New("TLS_CLIENT_CA_FILE is required to verify the client certificates")
+	}
+	if c.TLSReloadInterval <= 0 {
+		return errors.New("TLS_RELOAD_INTERVAL must be positive")
+	}
+	return nil
+}
+
+// TLSEnabled reports whether the API must be served over HTTPS
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+func (c Config) validateCORS() error {
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if len(c.CORSAllowedOrigins) > 1 {
+				return errors.New("CORS_ALLOWED_ORIGINS can not mix * with explicit origins")
+			}
+			if c.CORSAllowCredentials {
+				return errors.New("CORS_ALLOW_CREDENTIALS can not be used with CORS_ALLOWED_ORIGINS=*, browsers reject credentials for any origin")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return errors.Errorf("Invalid CORS origin %q, the expected format is scheme://host[:port]", origin)
+		}
+	}
+	for _, method := range c.CORSAllowedMethods {
+		if method == "" || strings.ToUpper(method) != method || strings.ContainsAny(method, " \t,") {
+			return errors.Errorf("Invalid CORS method %q, methods must be upper case HTTP methods", method)
+		}
+	}
+	for _, header := range c.CORSAllowedHeaders {
+		if header == "*" {
+			return errors.New("CORS_ALLOWED_HEADERS must list the headers explicitly")
+		}
+	}
+	if c.CORSMaxAge < 0 || c.CORSMaxAge > 600 {
+		return errors.New("CORS_MAX_AGE must be between 0 and 600 seconds")
+	}
+	return nil
+}
+
+// JWTEnabled reports whether a key was configured to validate JWTs
+func (c Config) JWTEnabled() bool {
+	return c.JWTHS256Secret != "" || c.JWTRS256PublicKeyFile != "" || c.JWTJWKSFile != ""
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,202 @@ func TestNewWithOneFromDefaultValueAndOneFromEnvVar(t *testing.T) {
 	}
 }
 
+func TestNewWithTimeoutsFromEnvVar(t *testing.T) {
+	os.Setenv("HANDLER_READ_TIMEOUT", "5s")
+	defer os.Unsetenv("HANDLER_READ_TIMEOUT")
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 5*time.Second, conf.ReadHandlerTimeout)
+		assert.Equal(t, 20*time.Second, conf.WriteHandlerTimeout)
+	}
+}
+
+func TestNewWithIdempotencyWindow(t *testing.T) {
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 24*time.Hour, conf.IdempotencyWindow)
+	}
+
+	os.Setenv("IDEMPOTENCY_WINDOW", "-1h")
+	defer os.Unsetenv("IDEMPOTENCY_WINDOW")
+	_, err = New()
+	assert.Error(t, err)
+}
+
+func TestNewWithSoftDeleteRetention(t *testing.T) {
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 720*time.Hour, conf.SoftDeleteRetention)
+		assert.Equal(t, time.Hour, conf.PurgeInterval)
+	}
+
+	os.Setenv("PURGE_INTERVAL", "0s")
+	defer os.Unsetenv("PURGE_INTERVAL")
+	_, err = New()
+	assert.Error(t, err)
+
+	os.Setenv("SOFT_DELETE_RETENTION", "0s")
+	defer os.Unsetenv("SOFT_DELETE_RETENTION")
+	_, err = New()
+	assert.NoError(t, err, "The interval is not used when the purge is disabled")
+}
+
+func TestNewWithEventsBuffer(t *testing.T) {
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1000, conf.EventsBuffer)
+		assert.Equal(t, 15*time.Second, conf.EventsHeartbeat)
+	}
+
+	os.Setenv("EVENTS_BUFFER", "-1")
+	defer os.Unsetenv("EVENTS_BUFFER")
+	_, err = New()
+	assert.Error(t, err)
+}
+
+func TestNewWithOutbox(t *testing.T) {
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.False(t, conf.OutboxEnabled)
+		assert.Equal(t, time.Second, conf.OutboxPollInterval)
+	}
+
+	os.Setenv("OUTBOX_ENABLED", "true")
+	os.Setenv("OUTBOX_POLL_INTERVAL", "0s")
+	defer os.Unsetenv("OUTBOX_ENABLED")
+	defer os.Unsetenv("OUTBOX_POLL_INTERVAL")
+	_, err = New()
+	assert.Error(t, err)
+}
+
+func TestNewWithWebhooks(t *testing.T) {
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 8, conf.WebhookMaxAttempts)
+		assert.Equal(t, 30*time.Second, conf.WebhookBackoff)
+		assert.Equal(t, time.Hour, conf.WebhookMaxBackoff)
+		assert.Equal(t, 10*time.Second, conf.WebhookTimeout)
+		assert.Equal(t, 5*time.Second, conf.WebhookPollInterval)
+	}
+
+	os.Setenv("WEBHOOK_BACKOFF", "-1s")
+	defer os.Unsetenv("WEBHOOK_BACKOFF")
+	_, err = New()
+	assert.Error(t, err)
+}
+
+func TestNewWithOpenAPIValidation(t *testing.T) {
+	os.Setenv("OPENAPI_VALIDATE_REQUESTS", "true")
+	defer os.Unsetenv("OPENAPI_VALIDATE_REQUESTS")
+
+	conf, err := New()
+
+	if assert.NoError(t, err) {
+		assert.True(t, conf.OpenAPIValidateRequests)
+		assert.False(t, conf.OpenAPIValidateResponses)
+	}
+}
+
+func TestNewRefusesDisabledAuthWithoutOptOut(t *testing.T) {
+	os.Setenv("API_KEY_STORE", "none")
+	defer os.Unsetenv("API_KEY_STORE")
+	_, err := New()
+	assert.Error(t, err)
+
+	os.Setenv("ALLOW_INSECURE_NO_AUTH", "true")
+	defer os.Unsetenv("ALLOW_INSECURE_NO_AUTH")
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.True(t, conf.AllowInsecureNoAuth)
+	}
+}
+
+func TestNewRequiresIssuerAndAudienceWithJWTKey(t *testing.T) {
+	os.Setenv("JWT_HS256_SECRET", "secret")
+	defer os.Unsetenv("JWT_HS256_SECRET")
+	_, err := New()
+	assert.Error(t, err)
+
+	os.Setenv("JWT_ISSUER", "https://idp.example.com")
+	os.Setenv("JWT_AUDIENCE", "stars")
+	defer os.Unsetenv("JWT_ISSUER")
+	defer os.Unsetenv("JWT_AUDIENCE")
+	conf, err := New()
+	if assert.NoError(t, err) {
+		assert.True(t, conf.JWTEnabled())
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	valid := Config{
+		APIKeyStore:        "mongo",
+		CORSAllowedOrigins: []string{"https://app.example.com", "http://localhost:3000"},
+		CORSAllowedMethods: []string{"GET", "POST"},
+		CORSMaxAge:         600,
+	}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]func(c *Config){
+		"WildcardWithCredentials": func(c *Config) {
+			c.CORSAllowedOrigins = []string{"*"}
+			c.CORSAllowCredentials = true
+		},
+		"WildcardMixedWithOrigins": func(c *Config) { c.CORSAllowedOrigins = []string{"*", "https://app.example.com"} },
+		"OriginWithPath":           func(c *Config) { c.CORSAllowedOrigins = []string{"https://app.example.com/app"} },
+		"OriginWithoutScheme":      func(c *Config) { c.CORSAllowedOrigins = []string{"app.example.com"} },
+		"LowerCaseMethod":          func(c *Config) { c.CORSAllowedMethods = []string{"get"} },
+		"WildcardHeader":           func(c *Config) { c.CORSAllowedHeaders = []string{"*"} },
+		"MaxAgeTooLong":            func(c *Config) { c.CORSMaxAge = 3600 },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			change(&c)
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	valid := Config{
+		APIKeyStore:       "mongo",
+		TLSCertFile:       "cert.pem",
+		TLSKeyFile:        "key.pem",
+		TLSClientCAFile:   "ca.pem",
+		TLSMinVersion:     "1.3",
+		TLSClientAuth:     "require",
+		TLSReloadInterval: time.Minute,
+	}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]func(c *Config){
+		"CertWithoutKey":          func(c *Config) { c.TLSKeyFile = "" },
+		"ClientCAWithoutTLS":      func(c *Config) { c.TLSCertFile, c.TLSKeyFile, c.TLSClientAuth = "", "", "none" },
+		"UnknownVersion":          func(c *Config) { c.TLSMinVersion = "1.0" },
+		"UnknownClientAuth":       func(c *Config) { c.TLSClientAuth = "always" },
+		"VerifyWithoutClientCA":   func(c *Config) { c.TLSClientCAFile = "" },
+		"NonPositiveReloadPeriod": func(c *Config) { c.TLSReloadInterval = 0 },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			change(&c)
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestNewWithInvalidCORSEnvVar(t *testing.T) {
+	os.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	os.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	defer os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	defer os.Unsetenv("CORS_ALLOW_CREDENTIALS")
+
+	_, err := New()
+
+	assert.Error(t, err)
+}
+
 func TestNewWithInvalidEnvVar(t *testing.T) {
 	os.Setenv("PORT", "abc")
 	_, err := New()
@@ -0,0 +1,29 @@
+// Package csvcell protects the CSV cells from the spreadsheet formula injection, a cell which a spreadsheet
+// would run as a formula is written with a leading quote, and the quote is removed when the CSV is read back
+package csvcell
+
+import "strings"
+
+// formulaStarts are the first characters which make a spreadsheet read a cell as a formula
+const formulaStarts = "=+-@\t\r"
+
+// Escape prefixes the cell with a quote when it starts with a formula character, after its own leading quotes,
+// so the cells which already started with a quote are read back unchanged too
+func Escape(cell string) string {
+	if isFormula(strings.TrimLeft(cell, "'")) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Unescape removes the quote added by Escape
+func Unescape(cell string) string {
+	if strings.HasPrefix(cell, "'") && isFormula(strings.TrimLeft(cell, "'")) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func isFormula(cell string) bool {
+	return cell != "" && strings.ContainsRune(formulaStarts, rune(cell[0]))
+}
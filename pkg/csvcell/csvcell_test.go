@@ -0,0 +1,30 @@
+package csvcell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		cell    string
+		escaped string
+	}{
+		{"Hoth", "Hoth"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"'=quoted", "''=quoted"},
+		{"'Hoth", "'Hoth"},
+		{"temperate, =arid", "temperate, =arid"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.escaped, Escape(tt.cell), tt.cell)
+		assert.Equal(t, tt.cell, Unescape(tt.escaped), "The cell should be read back unchanged")
+	}
+}
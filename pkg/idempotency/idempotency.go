@@ -0,0 +1,63 @@
+// Package idempotency keeps the responses of the requests sent with an Idempotency-Key,
+// so a retried request returns the original response instead of running again
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrInProgress is returned when another request with the same key did not finish yet
+var ErrInProgress = errors.New("a request with this idempotency key is in progress")
+
+// ErrMismatch is returned when the key was used with another request
+var ErrMismatch = errors.New("the idempotency key was used with another request")
+
+// Record is the struct which carries the request hash and the response saved for an idempotency key,
+// a record without status is still pending
+type Record struct {
+	Key         string
+	RequestHash string
+	Status      int
+	Header      http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Pending reports whether the request of the record is still running
+func (r Record) Pending() bool {
+	return r.Status == 0
+}
+
+// Store is the interface used to keep the idempotency records
+type Store interface {
+	// Reserve saves the pending record unless the key already has a record which did not expire, then it returns that record
+	Reserve(ctx context.Context, r Record) (existing Record, reserved bool, err error)
+	// Complete saves the response on the reserved record
+	Complete(ctx context.Context, r Record) error
+	// Release removes the reserved record, so the request can be retried with the same key
+	Release(ctx context.Context, key string) error
+}
+
+// HashRequest identifies the request sent with a key by its method, path and body
+func HashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Check compares a reserved key record with the request, it returns ErrInProgress or ErrMismatch when the record can not be replayed
+func Check(existing Record, requestHash string) error {
+	if existing.RequestHash != requestHash {
+		return ErrMismatch
+	}
+	if existing.Pending() {
+		return ErrInProgress
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package idempotency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRequest(t *testing.T) {
+	hash := HashRequest("POST", "/planets", []byte(`{"name": "Hoth"}`))
+
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashRequest("POST", "/planets", []byte(`{"name": "Hoth"}`)))
+	assert.NotEqual(t, hash, HashRequest("POST", "/planets", []byte(`{"name": "Naboo"}`)))
+	assert.NotEqual(t, hash, HashRequest("POST", "/planets:batch", []byte(`{"name": "Hoth"}`)))
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check(Record{RequestHash: "a", Status: 201}, "a"))
+	assert.Equal(t, ErrInProgress, Check(Record{RequestHash: "a"}, "a"))
+	assert.Equal(t, ErrMismatch, Check(Record{RequestHash: "a", Status: 201}, "b"))
+	assert.Equal(t, ErrMismatch, Check(Record{RequestHash: "a"}, "b"))
+}
@@ -0,0 +1,56 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store which keeps the records in memory, it only works with a single instance of the API
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}, now: time.Now}
+}
+
+// Reserve saves the pending record unless the key has a record which did not expire
+func (s *MemoryStore) Reserve(ctx context.Context, r Record) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired()
+	if existing, ok := s.records[r.Key]; ok {
+		return existing, false, nil
+	}
+	s.records[r.Key] = r
+	return r, true, nil
+}
+
+// Complete saves the response on the record
+func (s *MemoryStore) Complete(ctx context.Context, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[r.Key] = r
+	return nil
+}
+
+// Release removes the record
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) removeExpired() {
+	now := s.now()
+	for key, r := range s.records {
+		if !r.ExpiresAt.After(now) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	pending := Record{Key: "k", RequestHash: "a", ExpiresAt: now.Add(time.Minute)}
+
+	_, reserved, err := s.Reserve(ctx, pending)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	existing, reserved, err := s.Reserve(ctx, pending)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.True(t, existing.Pending())
+
+	done := pending
+	done.Status = 201
+	done.Body = []byte(`{"id": "1"}`)
+	assert.NoError(t, s.Complete(ctx, done))
+	existing, _, _ = s.Reserve(ctx, pending)
+	assert.Equal(t, done, existing)
+
+	now = now.Add(time.Minute)
+	_, reserved, _ = s.Reserve(ctx, pending)
+	assert.True(t, reserved, "An expired record should be replaced")
+
+	assert.NoError(t, s.Release(ctx, "k"))
+	_, reserved, _ = s.Reserve(ctx, pending)
+	assert.True(t, reserved, "A released key should be reserved again")
+}
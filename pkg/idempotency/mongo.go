@@ -0,0 +1,118 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type recordMongoModel struct {
+	Key         string              `bson:"_id"`
+	RequestHash string              `bson:"requestHash"`
+	Status      int                 `bson:"status"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	ExpiresAt   time.Time           `bson:"expiresAt"`
+}
+
+func (m recordMongoModel) toRecord() Record {
+	return Record{
+		Key:         m.Key,
+		RequestHash: m.RequestHash,
+		Status:      m.Status,
+		Header:      http.Header(m.Header),
+		Body:        m.Body,
+		ExpiresAt:   m.ExpiresAt,
+	}
+}
+
+func newRecordMongoModel(r Record) recordMongoModel {
+	return recordMongoModel{
+		Key:         r.Key,
+		RequestHash: r.RequestHash,
+		Status:      r.Status,
+		Header:      r.Header,
+		Body:        r.Body,
+		ExpiresAt:   r.ExpiresAt.UTC(),
+	}
+}
+
+// MongoStore is a Store which keeps the records on the idempotency MongoDB collection, the key is the document id
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore creates a Store instance to manipulate the idempotency records on MongoDB,
+// it ensures the TTL index which removes the expired records
+func NewMongoStore(ctx context.Context, db *mongo.Database) (MongoStore, error) {
+	s := MongoStore{Collection: db.Collection("idempotency")}
+	_, err := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return MongoStore{}, errors.Wrap(err, "Error creating the idempotency TTL index")
+	}
+	return s, nil
+}
+
+// Reserve inserts the pending record, the unique document id makes the concurrent requests with the same key see each other
+func (s MongoStore) Reserve(ctx context.Context, r Record) (Record, bool, error) {
+	// the TTL monitor runs about once a minute, so an expired record may still be there and is replaced once
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := s.Collection.InsertOne(ctx, newRecordMongoModel(r))
+		if err == nil {
+			return r, true, nil
+		}
+		if !isDuplicateKey(err) {
+			return Record{}, false, err
+		}
+
+		var existing recordMongoModel
+		err = s.Collection.FindOne(ctx, bson.M{"_id": r.Key}).Decode(&existing)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		if existing.ExpiresAt.After(time.Now()) {
+			return existing.toRecord(), false, nil
+		}
+		_, err = s.Collection.DeleteOne(ctx, bson.M{"_id": r.Key, "expiresAt": existing.ExpiresAt})
+		if err != nil {
+			return Record{}, false, err
+		}
+	}
+	return Record{}, false, ErrInProgress
+}
+
+// Complete replaces the pending record with the response
+func (s MongoStore) Complete(ctx context.Context, r Record) error {
+	_, err := s.Collection.ReplaceOne(ctx, bson.M{"_id": r.Key}, newRecordMongoModel(r))
+	return err
+}
+
+// Release removes the record
+func (s MongoStore) Release(ctx context.Context, key string) error {
+	_, err := s.Collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func isDuplicateKey(err error) bool {
+	writeErr, ok := err.(mongo.WriteException)
+	if !ok {
+		return false
+	}
+	for _, e := range writeErr.WriteErrors {
+		if e.Code == 11000 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase keeps the store tests away from the starwars database used by the other packages
+const testDatabase = "starwars_idempotency_test"
+
+func TestMongoStore(t *testing.T) {
+	client, err := connectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database(testDatabase).Drop(context.Background())
+
+	ctx := context.Background()
+	store, err := NewMongoStore(ctx, client.Database(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := Record{Key: "k", RequestHash: "a", ExpiresAt: time.Now().Add(time.Minute)}
+
+	_, reserved, err := store.Reserve(ctx, pending)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	existing, reserved, err := store.Reserve(ctx, pending)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.True(t, existing.Pending())
+
+	done := pending
+	done.Status = http.StatusCreated
+	done.Header = http.Header{"Content-Type": []string{"application/json"}}
+	done.Body = []byte(`{"id": "1"}`)
+	assert.NoError(t, store.Complete(ctx, done))
+	existing, _, _ = store.Reserve(ctx, pending)
+	assert.Equal(t, done.Status, existing.Status)
+	assert.Equal(t, done.Header, existing.Header)
+	assert.Equal(t, done.Body, existing.Body)
+
+	expired := Record{Key: "expired", RequestHash: "a", Status: http.StatusCreated, ExpiresAt: time.Now().Add(-time.Minute)}
+	_, _, _ = store.Reserve(ctx, expired)
+	_, reserved, err = store.Reserve(ctx, Record{Key: "expired", RequestHash: "b", ExpiresAt: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.True(t, reserved, "An expired record should be replaced before the TTL monitor removes it")
+
+	assert.NoError(t, store.Release(ctx, "k"))
+	_, reserved, _ = store.Reserve(ctx, pending)
+	assert.True(t, reserved, "A released key should be reserved again")
+}
+
+func connectMongoClient() (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.Database(testDatabase).Drop(context.Background())
+	return client, nil
+}
@@ -0,0 +1,132 @@
+// Package event is the in process bus of the planet change events, it keeps the last events so a subscriber can resume
+package event
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+)
+
+// The event types
+const (
+	TypeCreated = "planet.created"
+	TypeUpdated = "planet.updated"
+	TypeDeleted = "planet.deleted"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is the struct which describes a change of a planet, Planet is nil on deletion.
+// The ID is unique per process and increasing, so it can be used to resume a subscription.
+type Event struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	PlanetID string         `json:"planetId"`
+	Planet   *planet.Planet `json:"planet,omitempty"`
+	At       time.Time      `json:"at"`
+}
+
+// Bus fans the published events out to the subscribers and keeps the last ones in a ring buffer
+type Bus struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	ring        []Event
+	next        int
+	count       int
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus creates a Bus which keeps the last capacity events for the resumed subscriptions
+func NewBus(capacity int) *Bus {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Bus{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:        make([]Event, capacity),
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+// Publish sends the event of the planet change to every subscriber, a subscriber which fell behind is dropped
+// and must resume from its last event
+func (b *Bus) Publish(eventType, planetID string, p *planet.Planet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e := Event{ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10), Type: eventType, PlanetID: planetID, Planet: p, At: time.Now().UTC()}
+	b.ring[b.next] = e
+	b.next = (b.next + 1) % len(b.ring)
+	if b.count < len(b.ring) {
+		b.count++
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscription is the struct which carries the events missed since the last event id and the channel of the next ones.
+// Reset is set when the missed events are not kept anymore, the subscriber must reload the planets.
+type Subscription struct {
+	Replay []Event
+	Events <-chan Event
+	Reset  bool
+	bus    *Bus
+	ch     chan Event
+}
+
+// Close stops the subscription
+func (s Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s.ch]; ok {
+		delete(s.bus.subscribers, s.ch)
+		close(s.ch)
+	}
+}
+
+// Subscribe starts a subscription, the events after lastEventID are replayed when it is set
+func (b *Bus) Subscribe(lastEventID string) Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	s := Subscription{Events: ch, bus: b, ch: ch}
+	if lastEventID == "" {
+		return s
+	}
+
+	last, ok := b.sequence(lastEventID)
+	oldest := b.seq - uint64(b.count) + 1
+	if !ok || last > b.seq || (last+1 < oldest) {
+		s.Reset = true
+		return s
+	}
+	for i := 0; i < b.count; i++ {
+		e := b.ring[(b.next-b.count+i+len(b.ring))%len(b.ring)]
+		if seq, _ := b.sequence(e.ID); seq > last {
+			s.Replay = append(s.Replay, e)
+		}
+	}
+	return s
+}
+
+// sequence parses an event id of this bus, the ids of another process are refused
+func (b *Bus) sequence(id string) (uint64, bool) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 || parts[0] != b.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	return seq, err == nil
+}
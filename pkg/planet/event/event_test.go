@@ -0,0 +1,76 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusFansOutTheEvents(t *testing.T) {
+	b := NewBus(10)
+	first := b.Subscribe("")
+	second := b.Subscribe("")
+	defer first.Close()
+
+	b.Publish(TypeCreated, "1", &planet.Planet{ID: "1", Name: "Hoth"})
+
+	for _, s := range []Subscription{first, second} {
+		e := <-s.Events
+		assert.Equal(t, TypeCreated, e.Type)
+		assert.Equal(t, "1", e.PlanetID)
+		assert.Equal(t, "Hoth", e.Planet.Name)
+		assert.False(t, e.At.IsZero())
+	}
+
+	second.Close()
+	second.Close()
+	_, open := <-second.Events
+	assert.False(t, open, "A closed subscription should not receive events")
+}
+
+func TestBusResumesAfterTheLastEventID(t *testing.T) {
+	b := NewBus(3)
+	live := b.Subscribe("")
+	defer live.Close()
+	var ids []string
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		b.Publish(TypeUpdated, id, nil)
+		ids = append(ids, (<-live.Events).ID)
+	}
+
+	resumed := b.Subscribe(ids[1])
+	defer resumed.Close()
+	assert.False(t, resumed.Reset, "The events after the second are still kept")
+	if assert.Len(t, resumed.Replay, 3) {
+		assert.Equal(t, "3", resumed.Replay[0].PlanetID)
+		assert.Equal(t, "5", resumed.Replay[2].PlanetID)
+	}
+
+	upToDate := b.Subscribe(ids[4])
+	defer upToDate.Close()
+	assert.False(t, upToDate.Reset)
+	assert.Empty(t, upToDate.Replay)
+
+	for _, id := range []string{ids[0], "otherprocess-1", "invalid", b.epoch + "-99"} {
+		s := b.Subscribe(id)
+		assert.True(t, s.Reset, id)
+		assert.Empty(t, s.Replay, id)
+		s.Close()
+	}
+}
+
+func TestBusDropsTheSlowSubscribers(t *testing.T) {
+	b := NewBus(1)
+	slow := b.Subscribe("")
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(TypeDeleted, "1", nil)
+	}
+
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "The channel should be closed once the subscriber falls behind")
+	slow.Close()
+}
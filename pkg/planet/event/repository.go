@@ -0,0 +1,132 @@
+package event
+
+import (
+	"context"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+// maxAttempts bounds the retries of an unconditional delete which raced with another change
+const maxAttempts = 3
+
+// Repository is a PlanetRepository decorator which publishes an event for every change made through it,
+// so the events work with any backend
+type Repository struct {
+	repository.PlanetRepository
+	Bus *Bus
+}
+
+// NewRepository decorates the repository with the events published on the bus
+func NewRepository(rep repository.PlanetRepository, bus *Bus) Repository {
+	return Repository{PlanetRepository: rep, Bus: bus}
+}
+
+// Create creates the planet and publishes planet.created
+func (r Repository) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	created, err := r.PlanetRepository.Create(ctx, p)
+	if err == nil {
+		r.Bus.Publish(TypeCreated, created.ID, &created)
+	}
+	return created, err
+}
+
+// Update updates the planet and publishes planet.updated
+func (r Repository) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	updated, err := r.PlanetRepository.Update(ctx, p)
+	if err == nil {
+		r.Bus.Publish(TypeUpdated, updated.ID, &updated)
+	}
+	return updated, err
+}
+
+// Patch patches the planet and publishes planet.updated
+func (r Repository) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	patched, err := r.PlanetRepository.Patch(ctx, id, u)
+	if err == nil {
+		r.Bus.Publish(TypeUpdated, id, &patched)
+	}
+	return patched, err
+}
+
+// Delete deletes the planet and publishes planet.deleted, deleting an unknown or an already deleted planet publishes nothing
+func (r Repository) Delete(ctx context.Context, id string, version int64) error {
+	deleted, err := r.delete(ctx, id, version)
+	if deleted {
+		r.Bus.Publish(TypeDeleted, id, nil)
+	}
+	return err
+}
+
+// delete reports whether the planet was deleted. A conditional delete only succeeds when it deletes the planet,
+// an unconditional one is made on the version read first, so only the delete which tombstoned the planet reports it.
+func (r Repository) delete(ctx context.Context, id string, version int64) (bool, error) {
+	if version != 0 {
+		err := r.PlanetRepository.Delete(ctx, id, version)
+		return err == nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		current, err := r.PlanetRepository.FindByID(ctx, id, repository.FieldName)
+		if err == repository.ErrNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		err = r.PlanetRepository.Delete(ctx, id, current.Version)
+		switch {
+		case err == nil:
+			return true, nil
+		case err == repository.ErrNotFound:
+			// another request deleted the planet meanwhile and published its event
+			return false, nil
+		case err == repository.ErrVersionMismatch && attempt < maxAttempts:
+			continue
+		}
+		return false, err
+	}
+}
+
+// Restore restores the planet and publishes planet.created, since it is back on the listings
+func (r Repository) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	restored, err := r.PlanetRepository.Restore(ctx, id)
+	if err == nil {
+		r.Bus.Publish(TypeCreated, id, &restored)
+	}
+	return restored, err
+}
+
+// Bulk runs the operations and publishes the events of the successful ones. A successful delete only publishes
+// planet.deleted when the planet existed right before the bulk, so, unlike Delete, a concurrent delete may publish twice.
+func (r Repository) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	existed := make([]bool, len(ops))
+	for i, op := range ops {
+		if op.Type == repository.BulkDelete {
+			_, err := r.PlanetRepository.FindByID(ctx, op.Planet.ID, repository.FieldName)
+			existed[i] = err == nil
+		}
+	}
+
+	results, err := r.PlanetRepository.Bulk(ctx, ops, ordered)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		p := ops[i].Planet
+		p.ID = result.ID
+		switch ops[i].Type {
+		case repository.BulkCreate:
+			r.Bus.Publish(TypeCreated, result.ID, &p)
+		case repository.BulkUpdate:
+			r.Bus.Publish(TypeUpdated, result.ID, &p)
+		case repository.BulkDelete:
+			if existed[i] {
+				r.Bus.Publish(TypeDeleted, result.ID, nil)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,101 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// repositoryFake succeeds every change except the ones on the planet "missing"
+type repositoryFake struct {
+	repository.PlanetRepository
+}
+
+func (r repositoryFake) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	p.ID = "1"
+	return p, nil
+}
+
+func (r repositoryFake) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	if p.ID == "missing" {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r repositoryFake) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
+	if id == "missing" {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	return planet.Planet{ID: id, Version: 1}, nil
+}
+
+func (r repositoryFake) Delete(ctx context.Context, id string, version int64) error {
+	return nil
+}
+
+func (r repositoryFake) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	return []repository.BulkResult{{ID: "2"}, {ID: "3", Err: repository.ErrNotExecuted}}, nil
+}
+
+func TestRepositoryPublishesTheChanges(t *testing.T) {
+	bus := NewBus(10)
+	s := bus.Subscribe("")
+	defer s.Close()
+	repo := NewRepository(repositoryFake{}, bus)
+	ctx := context.Background()
+
+	repo.Create(ctx, planet.Planet{Name: "Hoth"})
+	repo.Update(ctx, planet.Planet{ID: "1", Name: "Hoth"})
+	repo.Update(ctx, planet.Planet{ID: "missing"})
+	repo.Delete(ctx, "1", 0)
+	repo.Bulk(ctx, []repository.BulkOperation{
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Naboo"}},
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: "3"}},
+	}, true)
+
+	var types, ids []string
+	for i := 0; i < 4; i++ {
+		e := <-s.Events
+		types = append(types, e.Type)
+		ids = append(ids, e.PlanetID)
+	}
+	assert.Equal(t, []string{TypeCreated, TypeUpdated, TypeDeleted, TypeCreated}, types)
+	assert.Equal(t, []string{"1", "1", "1", "2"}, ids)
+	assert.Empty(t, s.Events, "The failed changes should publish nothing")
+}
+
+func TestDeletingAnUnknownPlanetPublishesNothing(t *testing.T) {
+	bus := NewBus(10)
+	repo := NewRepository(memrep.NewMemoryRepository(), bus)
+	ctx := context.Background()
+	hoth, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+	naboo, _ := repo.Create(ctx, planet.Planet{Name: "Naboo"})
+	s := bus.Subscribe("")
+	defer s.Close()
+
+	assert.NoError(t, repo.Delete(ctx, "unknown", 0))
+	assert.NoError(t, repo.Delete(ctx, hoth.ID, 0))
+	assert.NoError(t, repo.Delete(ctx, hoth.ID, 0), "An unconditional delete of a deleted planet is not an error")
+	assert.Equal(t, repository.ErrNotFound, repo.Delete(ctx, hoth.ID, 1))
+	results, err := repo.Bulk(ctx, []repository.BulkOperation{
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: "unknown"}},
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: hoth.ID}},
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: naboo.ID}},
+	}, true)
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+
+	for _, id := range []string{hoth.ID, naboo.ID} {
+		e := <-s.Events
+		assert.Equal(t, TypeDeleted, e.Type)
+		assert.Equal(t, id, e.PlanetID)
+	}
+	assert.Empty(t, s.Events, "Only the planets which were deleted should publish planet.deleted")
+}
@@ -0,0 +1,149 @@
+// Package exporter streams the planets of a repository as NDJSON or CSV, a chunk at a time
+package exporter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/csvcell"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/retriever"
+)
+
+// The formats of the exported planets, both can be imported back
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// chunkSize is how many planets are held at a time, the SWAPI counts of a chunk are fetched concurrently
+const chunkSize = 100
+
+// Writer writes the planets one at a time, Flush must be called after the last one
+type Writer interface {
+	Write(p planet.Planet) error
+	Flush() error
+}
+
+// NewWriter creates the Writer of the format, the number of appearances on movies is written when counts is set
+func NewWriter(format string, w io.Writer, counts bool) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w), counts: counts}, nil
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w), counts: counts}, nil
+	}
+	return nil, errors.Errorf("unknown export format %q, use ndjson or csv", format)
+}
+
+// exportedPlanet is the NDJSON line of a planet, the version is exported since it is not on the planet JSON
+type exportedPlanet struct {
+	ID                          string `json:"id"`
+	Name                        string `json:"name"`
+	Climate                     string `json:"climate"`
+	Terrain                     string `json:"terrain"`
+	Version                     int64  `json:"version"`
+	NumberOfAppearancesOnMovies *int   `json:"numberOfAppearancesOnMovies,omitempty"`
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	counts  bool
+}
+
+func (w *ndjsonWriter) Write(p planet.Planet) error {
+	line := exportedPlanet{ID: p.ID, Name: p.Name, Climate: p.Climate, Terrain: p.Terrain, Version: p.Version}
+	if w.counts {
+		line.NumberOfAppearancesOnMovies = &p.NumberOfAppearancesOnMovies
+	}
+	return w.encoder.Encode(line)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes a header and then a record per planet, the header is written with the first planet.
+// The text cells are escaped with csvcell, since the planet names and climates come from the users.
+type csvWriter struct {
+	writer      *csv.Writer
+	counts      bool
+	wroteHeader bool
+}
+
+func (w *csvWriter) Write(p planet.Planet) error {
+	if !w.wroteHeader {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	record := []string{p.ID, csvcell.Escape(p.Name), csvcell.Escape(p.Climate), csvcell.Escape(p.Terrain), strconv.FormatInt(p.Version, 10)}
+	if w.counts {
+		record = append(record, strconv.Itoa(p.NumberOfAppearancesOnMovies))
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) writeHeader() error {
+	w.wroteHeader = true
+	header := []string{"id", "name", "climate", "terrain", "version"}
+	if w.counts {
+		header = append(header, "numberOfAppearancesOnMovies")
+	}
+	return w.writer.Write(header)
+}
+
+// Flush writes the buffered records, and the header when there was no planet
+func (w *csvWriter) Flush() error {
+	if !w.wroteHeader {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Export writes every planet which was not deleted, read from the repository cursor, and returns how many were written.
+// When counter is not nil the number of appearances on movies of each chunk is filled before it is written.
+func Export(ctx context.Context, rep repository.PlanetRepository, counter retriever.PlanetAppearancesOnMoviesCounter, w Writer) (int, error) {
+	written := 0
+	chunk := make([]planet.Planet, 0, chunkSize)
+	flush := func() error {
+		planets := chunk
+		if counter != nil {
+			var err error
+			if planets, err = retriever.FillAllNumberOfAppearancesOnMovies(ctx, chunk, counter); err != nil {
+				return err
+			}
+		}
+		for _, p := range planets {
+			if err := w.Write(p); err != nil {
+				return err
+			}
+			written++
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	err := rep.FindEach(ctx, func(p planet.Planet) error {
+		chunk = append(chunk, p)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	return written, err
+}
@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/importer"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticCounter map[string]int
+
+func (c staticCounter) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	return c[name], nil
+}
+
+func TestExportNDJSON(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	hoth, _ := rep.Create(context.Background(), planet.Planet{Name: "Hoth", Climate: "frozen"})
+	tatooine, _ := rep.Create(context.Background(), planet.Planet{Name: "Tatooine", Terrain: "desert"})
+	deleted, _ := rep.Create(context.Background(), planet.Planet{Name: "Alderaan"})
+	rep.Delete(context.Background(), deleted.ID, 0)
+	var out bytes.Buffer
+	w, _ := NewWriter(FormatNDJSON, &out, true)
+
+	n, err := Export(context.Background(), rep, staticCounter{"Tatooine": 5}, w)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, fmt.Sprintf(`{"id":"%s","name":"Hoth","climate":"frozen","terrain":"","version":1,"numberOfAppearancesOnMovies":0}
+{"id":"%s","name":"Tatooine","climate":"","terrain":"desert","version":1,"numberOfAppearancesOnMovies":5}
+`, hoth.ID, tatooine.ID), out.String())
+}
+
+func TestExportCSVWithoutCounts(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	hoth, _ := rep.Create(context.Background(), planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra, ice caves"})
+	var out bytes.Buffer
+	w, _ := NewWriter(FormatCSV, &out, false)
+
+	_, err := Export(context.Background(), rep, nil, w)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,climate,terrain,version\n"+hoth.ID+",Hoth,frozen,\"tundra, ice caves\",1\n", out.String())
+
+	out.Reset()
+	w, _ = NewWriter(FormatCSV, &out, true)
+	Export(context.Background(), memrep.NewMemoryRepository(), nil, w)
+	assert.Equal(t, "id,name,climate,terrain,version,numberOfAppearancesOnMovies\n", out.String(), "An empty export should still have the header")
+}
+
+func TestExportCSVEscapesTheFormulas(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	evil, _ := rep.Create(context.Background(), planet.Planet{Name: "=HYPERLINK(\"http://evil\")", Climate: "@SUM(A1)", Terrain: "-2+3"})
+	var out bytes.Buffer
+	w, _ := NewWriter(FormatCSV, &out, false)
+
+	_, err := Export(context.Background(), rep, nil, w)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,climate,terrain,version\n"+evil.ID+",\"'=HYPERLINK(\"\"http://evil\"\")\",'@SUM(A1),'-2+3,1\n", out.String())
+
+	reader, _ := importer.NewReader(FormatCSV, &out, nil)
+	p, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{Name: evil.Name, Climate: evil.Climate, Terrain: evil.Terrain}, p, "The import should remove the quotes")
+}
+
+func TestExportCanBeImportedBack(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	for i := 0; i < chunkSize+5; i++ {
+		rep.Create(context.Background(), planet.Planet{Name: fmt.Sprintf("Planet %d", i), Climate: "arid"})
+	}
+	for _, format := range []string{FormatNDJSON, FormatCSV} {
+		var out bytes.Buffer
+		w, _ := NewWriter(format, &out, true)
+		n, err := Export(context.Background(), rep, staticCounter{}, w)
+		assert.NoError(t, err)
+		assert.Equal(t, chunkSize+5, n)
+
+		reader, err := importer.NewReader(format, &out, nil)
+		assert.NoError(t, err)
+		read := 0
+		for {
+			p, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "arid", p.Climate)
+			read++
+		}
+		assert.Equal(t, chunkSize+5, read, format)
+	}
+}
+
+// failingWriter fails after the given number of planets
+type failingWriter struct {
+	left int
+}
+
+func (w *failingWriter) Write(p planet.Planet) error {
+	if w.left == 0 {
+		return errors.New("connection reset")
+	}
+	w.left--
+	return nil
+}
+
+func (w *failingWriter) Flush() error {
+	return nil
+}
+
+func TestExportStopsOnTheFirstError(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	for _, name := range []string{"Hoth", "Dagobah", "Naboo"} {
+		rep.Create(context.Background(), planet.Planet{Name: name})
+	}
+
+	n, err := Export(context.Background(), rep, nil, &failingWriter{left: 2})
+
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 2, n)
+}
+
+func TestNewWriterRefusesAnUnknownFormat(t *testing.T) {
+	_, err := NewWriter("xml", &strings.Builder{}, false)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+const (
+	defaultBatchSize = 500
+	defaultMaxErrors = 100
+)
+
+// Options is the struct which configures an Import
+type Options struct {
+	// DryRun reads and validates the rows without writing them, so an upsert does not see the earlier rows of the file
+	DryRun bool
+	// Upsert updates the planet which already has the row name instead of creating another one
+	Upsert bool
+	// BatchSize is how many rows are written together, 500 when zero
+	BatchSize int
+	// MaxErrors bounds the row errors kept on the report, 100 when zero, the failures are still counted
+	MaxErrors int
+}
+
+// RowError is the struct which describes a row which was not imported, Row is the position of the planet on the file starting at 1
+type RowError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// Report is the struct which carries the outcome of an Import, on a dry run Created and Updated are the changes it would make
+type Report struct {
+	Created         int        `json:"created"`
+	Updated         int        `json:"updated"`
+	Failed          int        `json:"failed"`
+	DryRun          bool       `json:"dryRun"`
+	Errors          []RowError `json:"errors"`
+	ErrorsTruncated bool       `json:"errorsTruncated,omitempty"`
+}
+
+// pendingRow is a row waiting for its batch to be written
+type pendingRow struct {
+	row int
+	op  repository.BulkOperation
+}
+
+// importer keeps the state of a running Import, only a batch of rows is held at a time
+type importer struct {
+	rep     repository.PlanetRepository
+	options Options
+	report  Report
+	batch   []pendingRow
+	// names has the planet.NameKey of the names on the batch, a repeated name flushes the batch so the upsert finds the planet
+	names map[string]bool
+}
+
+// Import reads every planet of the reader and writes them to the repository in unordered batches,
+// the rows which fail are on the report and do not stop the import.
+// The error is returned when the reading or a whole batch failed, the report still has the rows written before it.
+func Import(ctx context.Context, rep repository.PlanetRepository, reader Reader, options Options) (Report, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultBatchSize
+	}
+	if options.MaxErrors <= 0 {
+		options.MaxErrors = defaultMaxErrors
+	}
+	i := &importer{rep: rep, options: options, report: Report{DryRun: options.DryRun, Errors: []RowError{}}, names: map[string]bool{}}
+	for row := 1; ; row++ {
+		p, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if invalid, ok := err.(InvalidRowError); ok {
+			i.fail(row, "", invalid)
+			continue
+		}
+		if err != nil {
+			return i.report, errors.Wrapf(err, "reading the row %d", row)
+		}
+		if err := i.add(ctx, row, p); err != nil {
+			return i.report, err
+		}
+	}
+	if err := i.flush(ctx); err != nil {
+		return i.report, err
+	}
+	return i.report, nil
+}
+
+// add queues the row on the batch, writing the batch when it is full
+func (i *importer) add(ctx context.Context, row int, p planet.Planet) error {
+	p = planet.Planet{Name: strings.TrimSpace(p.Name), Climate: p.Climate, Terrain: p.Terrain}
+	if p.Name == "" {
+		i.fail(row, "", errors.New("the planet name is required"))
+		return nil
+	}
+	key := planet.NameKey(p.Name)
+	if i.options.Upsert && i.names[key] {
+		if err := i.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	op := repository.BulkOperation{Type: repository.BulkCreate, Planet: p}
+	if i.options.Upsert {
+		existing, err := i.rep.FindByName(ctx, p.Name)
+		switch {
+		case err == nil:
+			op.Type = repository.BulkUpdate
+			op.Planet.ID = existing.ID
+		case err != repository.ErrNotFound:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			i.fail(row, p.Name, err)
+			return nil
+		}
+	}
+	i.batch = append(i.batch, pendingRow{row: row, op: op})
+	i.names[key] = true
+	if len(i.batch) >= i.options.BatchSize {
+		return i.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the batch, on a dry run the rows are only counted
+func (i *importer) flush(ctx context.Context) error {
+	if len(i.batch) == 0 {
+		return nil
+	}
+	batch := i.batch
+	i.batch = nil
+	i.names = map[string]bool{}
+	if i.options.DryRun {
+		for _, pending := range batch {
+			i.succeed(pending.op.Type)
+		}
+		return nil
+	}
+
+	ops := make([]repository.BulkOperation, len(batch))
+	for j, pending := range batch {
+		ops[j] = pending.op
+	}
+	results, err := i.rep.Bulk(ctx, ops, false)
+	if err != nil {
+		return errors.Wrapf(err, "writing the rows %d to %d", batch[0].row, batch[len(batch)-1].row)
+	}
+	for j, result := range results {
+		if result.Err != nil {
+			i.fail(batch[j].row, batch[j].op.Planet.Name, result.Err)
+			continue
+		}
+		i.succeed(batch[j].op.Type)
+	}
+	return nil
+}
+
+func (i *importer) succeed(op repository.BulkOperationType) {
+	if op == repository.BulkUpdate {
+		i.report.Updated++
+		return
+	}
+	i.report.Created++
+}
+
+func (i *importer) fail(row int, name string, err error) {
+	i.report.Failed++
+	if len(i.report.Errors) >= i.options.MaxErrors {
+		i.report.ErrorsTruncated = true
+		return
+	}
+	i.report.Errors = append(i.report.Errors, RowError{Row: row, Name: name, Error: err.Error()})
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceReader reads the given planets, the errors are returned in their places
+type sliceReader struct {
+	rows []interface{}
+}
+
+func (r *sliceReader) Next() (planet.Planet, error) {
+	if len(r.rows) == 0 {
+		return planet.Planet{}, io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	if err, ok := row.(error); ok {
+		return planet.Planet{}, err
+	}
+	return row.(planet.Planet), nil
+}
+
+// bulkCounter counts the Bulk calls and their sizes
+type bulkCounter struct {
+	repository.PlanetRepository
+	sizes []int
+	err   error
+}
+
+func (r *bulkCounter) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	r.sizes = append(r.sizes, len(ops))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.PlanetRepository.Bulk(ctx, ops, ordered)
+}
+
+func TestImportCreatesThePlanetsInBatches(t *testing.T) {
+	rep := &bulkCounter{PlanetRepository: memrep.NewMemoryRepository()}
+	reader, _ := NewReader(FormatNDJSON, strings.NewReader(`{"name": "Hoth", "id": "1", "version": 7}
+{"name": "Dagobah"}
+{"name": " "}
+{"name": "Bespin"}
+`), nil)
+
+	report, err := Import(context.Background(), rep, reader, Options{BatchSize: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Created: 3, Failed: 1, Errors: []RowError{{Row: 3, Error: "the planet name is required"}}}, report)
+	assert.Equal(t, []int{2, 1}, rep.sizes)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 3)
+	assert.Equal(t, int64(1), all[0].Version, "The imported id and version should be ignored")
+}
+
+func TestImportUpsertsByName(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	hoth, _ := rep.Create(context.Background(), planet.Planet{Name: "Hoth", Climate: "temperate"})
+	reader := &sliceReader{rows: []interface{}{
+		planet.Planet{Name: "Hoth", Climate: "frozen"},
+		planet.Planet{Name: "Dagobah"},
+		planet.Planet{Name: "Dagobah", Terrain: "swamp"},
+	}}
+
+	report, err := Import(context.Background(), rep, reader, Options{Upsert: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Updated, "The repeated name should update the planet created by the earlier row")
+	updated, _ := rep.FindByID(context.Background(), hoth.ID)
+	assert.Equal(t, "frozen", updated.Climate)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 2)
+	dagobah, _ := rep.FindByName(context.Background(), "Dagobah")
+	assert.Equal(t, "swamp", dagobah.Terrain)
+}
+
+func TestImportWithoutUpsertFailsTheRepeatedNames(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	rep.Create(context.Background(), planet.Planet{Name: "Hoth"})
+	reader := &sliceReader{rows: []interface{}{planet.Planet{Name: "hoth"}, planet.Planet{Name: "Dagobah"}}}
+
+	report, err := Import(context.Background(), rep, reader, Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, repository.ErrDuplicateName.Error(), report.Errors[0].Error)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 2)
+}
+
+func TestImportWithUpsertMatchesTheNameKeys(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	rep.Create(context.Background(), planet.Planet{Name: "Hoth"})
+	reader := &sliceReader{rows: []interface{}{
+		planet.Planet{Name: " HOTH", Climate: "frozen"},
+		planet.Planet{Name: "Dagobah"},
+		planet.Planet{Name: "dagobah", Terrain: "swamp"},
+	}}
+
+	report, err := Import(context.Background(), rep, reader, Options{Upsert: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Updated)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 2)
+}
+
+func TestImportDryRunDoesNotWrite(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	rep.Create(context.Background(), planet.Planet{Name: "Hoth"})
+	reader := &sliceReader{rows: []interface{}{planet.Planet{Name: "Hoth", Climate: "frozen"}, planet.Planet{Name: "Dagobah"}}}
+
+	report, err := Import(context.Background(), rep, reader, Options{DryRun: true, Upsert: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Created: 1, Updated: 1, DryRun: true, Errors: []RowError{}}, report)
+	all, _ := rep.FindAll(context.Background())
+	assert.Equal(t, []planet.Planet{{ID: all[0].ID, Name: "Hoth", Version: 1}}, all)
+}
+
+func TestImportBoundsTheErrors(t *testing.T) {
+	reader := &sliceReader{rows: []interface{}{
+		InvalidRowError{errors.New("bad row")},
+		planet.Planet{},
+		planet.Planet{},
+		planet.Planet{Name: "Hoth"},
+	}}
+
+	report, err := Import(context.Background(), memrep.NewMemoryRepository(), reader, Options{MaxErrors: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Failed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, []RowError{{Row: 1, Error: "bad row"}, {Row: 2, Error: "the planet name is required"}}, report.Errors)
+	assert.True(t, report.ErrorsTruncated)
+}
+
+func TestImportStopsOnAFatalError(t *testing.T) {
+	reader := &sliceReader{rows: []interface{}{planet.Planet{Name: "Hoth"}, errors.New("connection reset"), planet.Planet{Name: "Dagobah"}}}
+
+	report, err := Import(context.Background(), memrep.NewMemoryRepository(), reader, Options{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "row 2")
+	assert.Equal(t, 0, report.Created, "The pending batch should not be written after a reading failure")
+
+	rep := &bulkCounter{PlanetRepository: memrep.NewMemoryRepository(), err: errors.New("database down")}
+	_, err = Import(context.Background(), rep, &sliceReader{rows: []interface{}{planet.Planet{Name: "Hoth"}}}, Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/csvcell"
+	"github.com/rafaelreinert/stars/pkg/planet"
+)
+
+// The formats of the imported planets
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// maxLine bounds a NDJSON line, so a stream without line breaks can not take the memory
+const maxLine = 1 << 20
+
+// InvalidRowError is returned by a Reader for a row which can not be read, the next rows can still be read
+type InvalidRowError struct {
+	Err error
+}
+
+func (e InvalidRowError) Error() string {
+	return e.Err.Error()
+}
+
+// Reader reads the planets one at a time, it returns io.EOF after the last one.
+// An InvalidRowError skips the row, any other error ends the reading.
+type Reader interface {
+	Next() (planet.Planet, error)
+}
+
+// NewReader creates the Reader of the format, columns maps the CSV header names to the planet fields
+func NewReader(format string, r io.Reader, columns map[string]string) (Reader, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONReader(r), nil
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	case FormatCSV:
+		return newCSVReader(r, columns)
+	}
+	return nil, errors.Errorf("unknown import format %q, use json, ndjson or csv", format)
+}
+
+// jsonReader reads the elements of a JSON array as they arrive
+type jsonReader struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	return &jsonReader{decoder: json.NewDecoder(r)}
+}
+
+func (r *jsonReader) Next() (planet.Planet, error) {
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err == io.EOF || (err == nil && token != json.Delim('[')) {
+			return planet.Planet{}, errors.New("the JSON import must be an array of planets")
+		}
+		if err != nil {
+			return planet.Planet{}, err
+		}
+		r.started = true
+	}
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return planet.Planet{}, err
+		}
+		return planet.Planet{}, io.EOF
+	}
+	var p planet.Planet
+	err := r.decoder.Decode(&p)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		// the decoder consumed the whole value, so the next element can still be read
+		return planet.Planet{}, InvalidRowError{err}
+	}
+	return p, err
+}
+
+// ndjsonReader reads a planet per line, the blank lines are skipped
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (r *ndjsonReader) Next() (planet.Planet, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var p planet.Planet
+		if err := json.Unmarshal(line, &p); err != nil {
+			return planet.Planet{}, InvalidRowError{err}
+		}
+		return p, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return planet.Planet{}, err
+	}
+	return planet.Planet{}, io.EOF
+}
+
+// csvReader reads a planet per record, the header names the column of each field,
+// the quote which the exports put before a formula is removed
+type csvReader struct {
+	reader *csv.Reader
+	// fields has the planet field of each column, empty for the ignored columns
+	fields []string
+}
+
+func newCSVReader(r io.Reader, columns map[string]string) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the CSV import has no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	mapping := map[string]string{}
+	for column, field := range columns {
+		mapping[strings.ToLower(strings.TrimSpace(column))] = strings.ToLower(strings.TrimSpace(field))
+	}
+	fields := make([]string, len(header))
+	hasName := false
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if field, ok := mapping[column]; ok {
+			column = field
+		}
+		switch column {
+		case "name", "climate", "terrain":
+			fields[i] = column
+			hasName = hasName || column == "name"
+		}
+	}
+	if !hasName {
+		return nil, errors.New("the CSV header has no name column")
+	}
+	return &csvReader{reader: reader, fields: fields}, nil
+}
+
+func (r *csvReader) Next() (planet.Planet, error) {
+	record, err := r.reader.Read()
+	if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
+		return planet.Planet{}, InvalidRowError{err}
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	var p planet.Planet
+	for i, value := range record {
+		value = csvcell.Unescape(value)
+		switch r.fields[i] {
+		case "name":
+			p.Name = value
+		case "climate":
+			p.Climate = value
+		case "terrain":
+			p.Terrain = value
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads the planets until the end or a fatal error, the invalid rows are counted
+func readAll(r Reader) ([]planet.Planet, int, error) {
+	var planets []planet.Planet
+	invalid := 0
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return planets, invalid, nil
+		}
+		if _, ok := err.(InvalidRowError); ok {
+			invalid++
+			continue
+		}
+		if err != nil {
+			return planets, invalid, err
+		}
+		planets = append(planets, p)
+	}
+}
+
+func TestJSONReader(t *testing.T) {
+	r, err := NewReader(FormatJSON, strings.NewReader(`[{"name": "Hoth", "climate": "frozen"}, {"name": 42}, {"name": "Dagobah", "terrain": "swamp"}]`), nil)
+	assert.NoError(t, err)
+
+	planets, invalid, err := readAll(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, invalid)
+	assert.Equal(t, []planet.Planet{{Name: "Hoth", Climate: "frozen"}, {Name: "Dagobah", Terrain: "swamp"}}, planets)
+}
+
+func TestJSONReaderRequiresAnArray(t *testing.T) {
+	for _, body := range []string{``, `{"name": "Hoth"}`} {
+		r, _ := NewReader(FormatJSON, strings.NewReader(body), nil)
+		_, _, err := readAll(r)
+		assert.Error(t, err, body)
+	}
+
+	r, _ := NewReader(FormatJSON, strings.NewReader(`[{"name": "Hoth"}, {"name": `), nil)
+	planets, _, err := readAll(r)
+	assert.Error(t, err, "A truncated array should end the reading")
+	assert.Len(t, planets, 1)
+}
+
+func TestNDJSONReader(t *testing.T) {
+	body := "{\"name\": \"Hoth\"}\n\n{\"name\": \n  {\"name\": \"Dagobah\"}  \n"
+	r, err := NewReader(FormatNDJSON, strings.NewReader(body), nil)
+	assert.NoError(t, err)
+
+	planets, invalid, err := readAll(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, invalid)
+	assert.Equal(t, []planet.Planet{{Name: "Hoth"}, {Name: "Dagobah"}}, planets)
+}
+
+func TestNDJSONReaderBoundsTheLine(t *testing.T) {
+	r, _ := NewReader(FormatNDJSON, strings.NewReader(strings.Repeat("x", maxLine+1)), nil)
+
+	_, _, err := readAll(r)
+
+	assert.Error(t, err)
+}
+
+func TestCSVReader(t *testing.T) {
+	body := "\ufeffName, Climate ,Terrain,Population\nHoth,frozen,tundra,0\nBespin,temperate\nDagobah,murky,\"swamp, jungles\",unknown\n"
+	r, err := NewReader(FormatCSV, strings.NewReader(body), nil)
+	assert.NoError(t, err)
+
+	planets, invalid, err := readAll(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, invalid)
+	assert.Equal(t, []planet.Planet{
+		{Name: "Hoth", Climate: "frozen", Terrain: "tundra"},
+		{Name: "Dagobah", Climate: "murky", Terrain: "swamp, jungles"},
+	}, planets)
+}
+
+func TestCSVReaderMapsTheColumns(t *testing.T) {
+	body := "planet,weather\nHoth,frozen\n"
+	r, err := NewReader(FormatCSV, strings.NewReader(body), map[string]string{"Planet": "name", "weather": "Climate"})
+	assert.NoError(t, err)
+
+	planets, _, err := readAll(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{{Name: "Hoth", Climate: "frozen"}}, planets)
+}
+
+func TestCSVReaderRequiresTheNameColumn(t *testing.T) {
+	_, err := NewReader(FormatCSV, strings.NewReader("planet,climate\nHoth,frozen\n"), nil)
+	assert.Error(t, err)
+
+	_, err = NewReader(FormatCSV, strings.NewReader(""), nil)
+	assert.Error(t, err)
+}
+
+func TestNewReaderRefusesAnUnknownFormat(t *testing.T) {
+	_, err := NewReader("xml", strings.NewReader(""), nil)
+
+	assert.Error(t, err)
+}
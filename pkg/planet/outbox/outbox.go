@@ -0,0 +1,85 @@
+// Package outbox relays the planet events which the repository writes in the same transaction as the change,
+// so no event is lost when the process stops between the change and its publication
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+)
+
+const defaultLease = time.Minute
+
+// Source is the interface of the outbox kept by the repository
+type Source interface {
+	// Claim takes the oldest entry which no relay claimed in the last lease
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (event.Event, bool, error)
+	// Ack removes the entry delivered to every sink
+	Ack(ctx context.Context, id string) error
+}
+
+// Sink receives the relayed events, an event is delivered at least once so the sinks must tolerate duplicates
+type Sink interface {
+	Deliver(ctx context.Context, e event.Event) error
+}
+
+// BusSink publishes the relayed events on the in process bus, which feeds the event streams
+type BusSink struct {
+	Bus *event.Bus
+}
+
+// Deliver publishes the event on the bus
+func (s BusSink) Deliver(ctx context.Context, e event.Event) error {
+	s.Bus.Publish(e.Type, e.PlanetID, e.Planet)
+	return nil
+}
+
+// Relay is the struct which moves the outbox entries to the sinks, an entry is only removed after every sink took it.
+// A failed entry is claimed again when its lease ends, so many relays can share the outbox.
+type Relay struct {
+	Source Source
+	Sinks  []Sink
+	// Lease is how long a claimed entry is hidden from the other relays, a minute when it is zero
+	Lease time.Duration
+}
+
+// Run relays the outbox entries, right away and then every interval, until ctx is done
+func (r Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.RelayPending(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Error relaying the planet events", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending delivers the claimable entries until none is left, it stops on the first failure
+func (r Relay) RelayPending(ctx context.Context) error {
+	lease := r.Lease
+	if lease <= 0 {
+		lease = defaultLease
+	}
+	for ctx.Err() == nil {
+		e, ok, err := r.Source.Claim(ctx, time.Now().UTC(), lease)
+		if err != nil || !ok {
+			return err
+		}
+		for _, sink := range r.Sinks {
+			if err := sink.Deliver(ctx, e); err != nil {
+				return err
+			}
+		}
+		if err := r.Source.Ack(ctx, e.ID); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// sourceMock is an outbox kept in memory, the claimed entries stay hidden until their lease ends
+type sourceMock struct {
+	mu      sync.Mutex
+	entries []event.Event
+	claimed map[string]time.Time
+}
+
+func newSourceMock(entries ...event.Event) *sourceMock {
+	return &sourceMock{entries: entries, claimed: map[string]time.Time{}}
+}
+
+func (s *sourceMock) Claim(ctx context.Context, now time.Time, lease time.Duration) (event.Event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if until, ok := s.claimed[e.ID]; !ok || !until.After(now) {
+			s.claimed[e.ID] = now.Add(lease)
+			return e, true, nil
+		}
+	}
+	return event.Event{}, false, nil
+}
+
+func (s *sourceMock) Ack(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("unknown entry")
+}
+
+// sinkMock keeps the delivered events and fails the ones listed in fail
+type sinkMock struct {
+	delivered []string
+	fail      map[string]bool
+}
+
+func (s *sinkMock) Deliver(ctx context.Context, e event.Event) error {
+	if s.fail[e.ID] {
+		return errors.New("sink is down")
+	}
+	s.delivered = append(s.delivered, e.ID)
+	return nil
+}
+
+func TestRelayPending(t *testing.T) {
+	source := newSourceMock(event.Event{ID: "1"}, event.Event{ID: "2"}, event.Event{ID: "3"})
+	first, second := &sinkMock{}, &sinkMock{}
+	r := Relay{Source: source, Sinks: []Sink{first, second}}
+
+	assert.NoError(t, r.RelayPending(context.Background()))
+
+	assert.Equal(t, []string{"1", "2", "3"}, first.delivered)
+	assert.Equal(t, []string{"1", "2", "3"}, second.delivered)
+	assert.Empty(t, source.entries, "The delivered entries should be acknowledged")
+}
+
+func TestRelayPendingRetriesFailedEntries(t *testing.T) {
+	source := newSourceMock(event.Event{ID: "1"}, event.Event{ID: "2"})
+	first, second := &sinkMock{}, &sinkMock{fail: map[string]bool{"1": true}}
+	r := Relay{Source: source, Sinks: []Sink{first, second}, Lease: time.Millisecond}
+
+	assert.Error(t, r.RelayPending(context.Background()))
+	assert.Len(t, source.entries, 2, "An entry should be kept until every sink takes it")
+
+	assert.NoError(t, r.RelayPending(context.Background()), "The next relay should skip the claimed entry")
+	assert.Equal(t, []string{"2"}, second.delivered)
+
+	second.fail = nil
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, r.RelayPending(context.Background()))
+	assert.Equal(t, []string{"1", "2", "1"}, first.delivered, "The first sink should get the retried entry again")
+	assert.Equal(t, []string{"2", "1"}, second.delivered)
+	assert.Empty(t, source.entries)
+}
+
+func TestRunStopsWithTheContext(t *testing.T) {
+	source := newSourceMock(event.Event{ID: "1"})
+	sink := &sinkMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		Relay{Source: source, Sinks: []Sink{sink}}.Run(ctx, time.Hour)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		source.mu.Lock()
+		defer source.mu.Unlock()
+		return len(source.entries) == 0
+	}, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run should return when the context is done")
+	}
+}
+
+func TestBusSink(t *testing.T) {
+	bus := event.NewBus(10)
+	sub := bus.Subscribe("")
+	defer sub.Close()
+
+	err := BusSink{Bus: bus}.Deliver(context.Background(), event.Event{ID: "5f0c", Type: event.TypeCreated, PlanetID: "p1", Planet: &planet.Planet{ID: "p1"}})
+
+	assert.NoError(t, err)
+	e := <-sub.Events
+	assert.Equal(t, event.TypeCreated, e.Type)
+	assert.Equal(t, "p1", e.PlanetID)
+}
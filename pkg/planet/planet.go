@@ -1,5 +1,13 @@
 package planet
 
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
 // Planet struct represents a planet for the system
 type Planet struct {
 	ID                          string `json:"id"`
@@ -7,4 +15,52 @@ type Planet struct {
 	Climate                     string `json:"climate"`
 	Terrain                     string `json:"terrain"`
 	NumberOfAppearancesOnMovies int    `json:"numberOfAppearancesOnMovies"`
+	// Version is incremented on every change, it is exposed as the ETag instead of the body
+	Version int64 `json:"-"`
+	// DeletedAt is the tombstone of a deleted planet, which is kept until the purge
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Films are the SWAPI films the planet appears on, they are only filled when asked since each one is a SWAPI request
+	Films []Film `json:"films,omitempty"`
+}
+
+// Film struct represents a StarWars movie the planet appears on
+type Film struct {
+	Title       string `json:"title"`
+	EpisodeID   int    `json:"episodeId"`
+	Director    string `json:"director"`
+	ReleaseDate string `json:"releaseDate"`
+}
+
+// Update is a partial update of a planet, only the non nil fields are changed and an empty string clears the field
+type Update struct {
+	// Version is the expected current version, the update is refused when it changed and ignored when it is zero
+	Version int64
+	Name    *string
+	Climate *string
+	Terrain *string
+}
+
+// IsEmpty reports whether the update changes no field
+func (u Update) IsEmpty() bool {
+	return u.Name == nil && u.Climate == nil && u.Terrain == nil
+}
+
+// Apply returns a copy of the planet with the update applied
+func (u Update) Apply(p Planet) Planet {
+	if u.Name != nil {
+		p.Name = *u.Name
+	}
+	if u.Climate != nil {
+		p.Climate = *u.Climate
+	}
+	if u.Terrain != nil {
+		p.Terrain = *u.Terrain
+	}
+	return p
+}
+
+// NameKey is the normalized planet name the lookups and the uniqueness compare, so "Alderaan", " alderaan" and
+// "ALDERAAN" are the same planet. The name is trimmed, composed and Unicode case folded, the diacritics are kept.
+func NameKey(name string) string {
+	return cases.Fold().String(norm.NFC.String(strings.TrimSpace(name)))
 }
@@ -0,0 +1,34 @@
+package planet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateApply(t *testing.T) {
+	name := "Tatooine"
+	empty := ""
+	p := Planet{ID: "1", Name: "Tatoine", Climate: "arid", Terrain: "desert"}
+
+	updated := Update{Name: &name, Terrain: &empty}.Apply(p)
+
+	assert.Equal(t, Planet{ID: "1", Name: "Tatooine", Climate: "arid", Terrain: ""}, updated)
+}
+
+func TestUpdateIsEmpty(t *testing.T) {
+	climate := "arid"
+
+	assert.True(t, Update{}.IsEmpty())
+	assert.False(t, Update{Climate: &climate}.IsEmpty())
+}
+
+func TestNameKey(t *testing.T) {
+	assert.Equal(t, "alderaan", NameKey("Alderaan"))
+	assert.Equal(t, "alderaan", NameKey("  ALDERAAN\t"))
+	assert.Equal(t, "yavin iv", NameKey("Yavin IV"))
+	assert.Equal(t, "kessel", NameKey("KeSSel"))
+	assert.Equal(t, NameKey("STRASSE"), NameKey("Straße"), "The case should be fully folded")
+	assert.Equal(t, NameKey("Tatoo\u00edne"), NameKey("Tatooi\u0301ne"), "The name should be composed")
+	assert.NotEqual(t, NameKey("Tatooine"), NameKey("Tatooíne"), "The diacritics should be kept")
+}
@@ -0,0 +1,33 @@
+// Package purger removes for good the planets which stayed deleted longer than the retention period
+package purger
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Purger defines the interface to remove the planets deleted before a given time
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Run purges the planets deleted longer than retention ago, right away and then every interval, until ctx is done
+func Run(ctx context.Context, p Purger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Println("Error purging the deleted planets", err)
+		} else if purged > 0 {
+			log.Println("Purged deleted planets:", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package purger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type purgerMock struct {
+	mu      sync.Mutex
+	befores []time.Time
+	err     error
+}
+
+func (p *purgerMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.befores = append(p.befores, before)
+	return 1, p.err
+}
+
+func (p *purgerMock) calls() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Time(nil), p.befores...)
+}
+
+func TestRunPurgesEveryInterval(t *testing.T) {
+	p := &purgerMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		Run(ctx, p, time.Hour, 10*time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return len(p.calls()) >= 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	before := p.calls()[0]
+	assert.WithinDuration(t, start.Add(-time.Hour), before, time.Second, "The purge should keep the planets deleted within the retention")
+}
+
+func TestRunKeepsGoingAfterAnError(t *testing.T) {
+	p := &purgerMock{err: errors.New("connection refused")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Run(ctx, p, time.Hour, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool { return len(p.calls()) >= 2 }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,276 @@
+// Package memrep keeps the planets in process memory, it serves the tools and the tests which run without MongoDB
+package memrep
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/search"
+)
+
+type planetMemoryRepositoryImpl struct {
+	mu      sync.Mutex
+	lastID  uint64
+	planets map[string]planet.Planet
+}
+
+// NewMemoryRepository creates an empty Repository which keeps the planets in memory,
+// the ids increase with the creation like the mongo ids
+func NewMemoryRepository() repository.PlanetRepository {
+	return &planetMemoryRepositoryImpl{planets: map[string]planet.Planet{}}
+}
+
+func (r *planetMemoryRepositoryImpl) newID() string {
+	r.lastID++
+	return fmt.Sprintf("%024x", r.lastID)
+}
+
+// Create a new planet in memory
+func (r *planetMemoryRepositoryImpl) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.create(p)
+}
+
+func (r *planetMemoryRepositoryImpl) create(p planet.Planet) (planet.Planet, error) {
+	if r.nameTaken(p.Name, "") {
+		return planet.Planet{}, repository.ErrDuplicateName
+	}
+	p = planet.Planet{ID: r.newID(), Name: p.Name, Climate: p.Climate, Terrain: p.Terrain, Version: 1}
+	r.planets[p.ID] = p
+	return p, nil
+}
+
+// nameTaken reports whether another planet which was not deleted has the same planet.NameKey
+func (r *planetMemoryRepositoryImpl) nameTaken(name, id string) bool {
+	key := planet.NameKey(name)
+	for _, p := range r.planets {
+		if p.ID != id && p.DeletedAt == nil && planet.NameKey(p.Name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// FindByID finds a planet which was not deleted using the id
+func (r *planetMemoryRepositoryImpl) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.planets[id]
+	if !ok || p.DeletedAt != nil {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	return repository.Project(p, fields), nil
+}
+
+// FindByName finds a planet which was not deleted using the planet.NameKey of the name
+func (r *planetMemoryRepositoryImpl) FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error) {
+	key := planet.NameKey(name)
+	for _, p := range r.find(func(p planet.Planet) bool { return p.DeletedAt == nil && planet.NameKey(p.Name) == key }) {
+		return repository.Project(p, fields), nil
+	}
+	return planet.Planet{}, repository.ErrNotFound
+}
+
+// FindAll finds all planets which were not deleted, ordered by id
+func (r *planetMemoryRepositoryImpl) FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error) {
+	return project(r.find(func(p planet.Planet) bool { return p.DeletedAt == nil }), fields), nil
+}
+
+// FindPage finds a page of the planets which were not deleted, ordered by id
+func (r *planetMemoryRepositoryImpl) FindPage(ctx context.Context, after string, limit int, fields ...string) ([]planet.Planet, error) {
+	planets := r.find(func(p planet.Planet) bool { return p.DeletedAt == nil && p.ID > after })
+	if len(planets) > limit {
+		planets = planets[:limit]
+	}
+	return project(planets, fields), nil
+}
+
+func project(planets []planet.Planet, fields []string) []planet.Planet {
+	for i, p := range planets {
+		planets[i] = repository.Project(p, fields)
+	}
+	return planets
+}
+
+// FindEach calls fn with a snapshot of the planets which were not deleted, ordered by id,
+// so fn may change the repository
+func (r *planetMemoryRepositoryImpl) FindEach(ctx context.Context, fn func(planet.Planet) error) error {
+	for _, p := range r.find(func(p planet.Planet) bool { return p.DeletedAt == nil }) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search ranks the planets which were not deleted in process, see search.Rank
+func (r *planetMemoryRepositoryImpl) Search(ctx context.Context, query string, limit int) ([]repository.SearchResult, error) {
+	return search.Rank(r.find(func(p planet.Planet) bool { return p.DeletedAt == nil }), query, limit), nil
+}
+
+// FindDeleted finds the deleted planets which were not purged yet, the last deleted first
+func (r *planetMemoryRepositoryImpl) FindDeleted(ctx context.Context) ([]planet.Planet, error) {
+	planets := r.find(func(p planet.Planet) bool { return p.DeletedAt != nil })
+	sort.SliceStable(planets, func(i, j int) bool { return planets[i].DeletedAt.After(*planets[j].DeletedAt) })
+	return planets, nil
+}
+
+// find returns the matching planets ordered by id
+func (r *planetMemoryRepositoryImpl) find(match func(planet.Planet) bool) []planet.Planet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	planets := []planet.Planet{}
+	for _, p := range r.planets {
+		if match(p) {
+			planets = append(planets, p)
+		}
+	}
+	sort.Slice(planets, func(i, j int) bool { return planets[i].ID < planets[j].ID })
+	return planets
+}
+
+// Update a planet in memory, it is created when it does not exist and no version is expected,
+// a deleted planet must be restored before it can be updated
+func (r *planetMemoryRepositoryImpl) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.update(p, p.Version == 0)
+}
+
+func (r *planetMemoryRepositoryImpl) update(p planet.Planet, upsert bool) (planet.Planet, error) {
+	current, err := r.current(p.ID, p.Version)
+	if err == repository.ErrNotFound && upsert {
+		if _, exists := r.planets[p.ID]; !exists {
+			current, err = planet.Planet{ID: p.ID}, nil
+		}
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	current.Name, current.Climate, current.Terrain = p.Name, p.Climate, p.Terrain
+	return r.save(current)
+}
+
+// Patch changes only the fields set on the update
+func (r *planetMemoryRepositoryImpl) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, err := r.current(id, u.Version)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	return r.save(u.Apply(current))
+}
+
+// Delete marks a planet as deleted, when a version is given the planet is only deleted if it is still on that version.
+// The planet is kept with the tombstone until Purge removes it.
+func (r *planetMemoryRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.delete(id, version)
+}
+
+// delete sets the tombstone, an unconditional delete of a missing planet is not an error
+func (r *planetMemoryRepositoryImpl) delete(id string, version int64) error {
+	current, err := r.current(id, version)
+	if err == repository.ErrNotFound && version == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	current.DeletedAt = &now
+	_, err = r.save(current)
+	return err
+}
+
+// Restore removes the tombstone of a deleted planet
+func (r *planetMemoryRepositoryImpl) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.planets[id]
+	if !ok || p.DeletedAt == nil {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	p.DeletedAt = nil
+	return r.save(p)
+}
+
+// Purge removes the planets deleted before the given time
+func (r *planetMemoryRepositoryImpl) Purge(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var purged int64
+	for id, p := range r.planets {
+		if p.DeletedAt != nil && p.DeletedAt.Before(before) {
+			delete(r.planets, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Bulk runs the operations one after the other under the same lock
+func (r *planetMemoryRepositoryImpl) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]repository.BulkResult, len(ops))
+	for i, op := range ops {
+		results[i].ID = op.Planet.ID
+		var err error
+		switch op.Type {
+		case repository.BulkCreate:
+			var created planet.Planet
+			created, err = r.create(op.Planet)
+			results[i].ID = created.ID
+		case repository.BulkUpdate:
+			op.Planet.Version = 0
+			_, err = r.update(op.Planet, true)
+		case repository.BulkDelete:
+			err = r.delete(op.Planet.ID, 0)
+		default:
+			err = errors.Errorf("unknown bulk operation %q", op.Type)
+		}
+		results[i].Err = err
+		if err != nil && ordered {
+			for j := range results[i+1:] {
+				results[i+1+j] = repository.BulkResult{ID: ops[i+1+j].Planet.ID, Err: repository.ErrNotExecuted}
+			}
+			break
+		}
+	}
+	return results, nil
+}
+
+// current returns the planet which was not deleted, when a version is given it must be the current one
+func (r *planetMemoryRepositoryImpl) current(id string, version int64) (planet.Planet, error) {
+	p, ok := r.planets[id]
+	if !ok || p.DeletedAt != nil {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if version != 0 && version != p.Version {
+		return planet.Planet{}, repository.ErrVersionMismatch
+	}
+	return p, nil
+}
+
+// save increments the version and keeps the planet, unless it would repeat the name of another planet
+func (r *planetMemoryRepositoryImpl) save(p planet.Planet) (planet.Planet, error) {
+	if p.DeletedAt == nil && r.nameTaken(p.Name, p.ID) {
+		return planet.Planet{}, repository.ErrDuplicateName
+	}
+	p.Version++
+	r.planets[p.ID] = p
+	return p, nil
+}
@@ -0,0 +1,254 @@
+package memrep
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndFind(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	created, err := repo.Create(ctx, planet.Planet{ID: "ignored", Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	assert.NoError(t, err)
+	assert.Len(t, created.ID, 24)
+	assert.Equal(t, int64(1), created.Version)
+
+	found, err := repo.FindByID(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, created, found)
+	found, err = repo.FindByName(ctx, "Tatooine")
+	assert.NoError(t, err)
+	assert.Equal(t, created, found)
+
+	_, err = repo.FindByID(ctx, "missing")
+	assert.Equal(t, repository.ErrNotFound, err)
+	_, err = repo.FindByName(ctx, "Hoth")
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestFindAllAndFindPageAreOrderedByID(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	var created []planet.Planet
+	for _, name := range []string{"Tatooine", "Alderaan", "Hoth", "Dagobah"} {
+		p, _ := repo.Create(ctx, planet.Planet{Name: name})
+		created = append(created, p)
+	}
+	assert.NoError(t, repo.Delete(ctx, created[1].ID, 0))
+
+	all, err := repo.FindAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{created[0], created[2], created[3]}, all)
+
+	page, err := repo.FindPage(ctx, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{created[0], created[2]}, page)
+	page, err = repo.FindPage(ctx, created[2].ID, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{created[3]}, page)
+	page, err = repo.FindPage(ctx, created[3].ID, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func TestFindEach(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	first, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine"})
+	deleted, _ := repo.Create(ctx, planet.Planet{Name: "Alderaan"})
+	last, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+	repo.Delete(ctx, deleted.ID, 0)
+
+	var found []planet.Planet
+	err := repo.FindEach(ctx, func(p planet.Planet) error {
+		found = append(found, p)
+		// the snapshot lets the callback change the repository
+		_, err := repo.Create(ctx, planet.Planet{Name: "Copy of " + p.Name})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{first, last}, found)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.FindEach(ctx, func(p planet.Planet) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestUpdateAndPatchCheckTheVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid"})
+
+	updated, err := repo.Update(ctx, planet.Planet{ID: created.ID, Name: "Tatooine", Climate: "hot", Version: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "hot", updated.Climate)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = repo.Update(ctx, planet.Planet{ID: created.ID, Version: 1})
+	assert.Equal(t, repository.ErrVersionMismatch, err)
+
+	terrain := "desert"
+	patched, err := repo.Patch(ctx, created.ID, planet.Update{Version: 2, Terrain: &terrain})
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{ID: created.ID, Name: "Tatooine", Climate: "hot", Terrain: "desert", Version: 3}, patched)
+
+	_, err = repo.Patch(ctx, "missing", planet.Update{Terrain: &terrain})
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestUpdateCreatesAMissingPlanet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	created, err := repo.Update(ctx, planet.Planet{ID: "5f0000000000000000000001", Name: "Hoth"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{ID: "5f0000000000000000000001", Name: "Hoth", Version: 1}, created)
+	_, err = repo.Update(ctx, planet.Planet{ID: "5f0000000000000000000002", Name: "Hoth", Version: 1})
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestDeleteRestoreAndPurge(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Alderaan"})
+
+	assert.Equal(t, repository.ErrVersionMismatch, repo.Delete(ctx, created.ID, 5))
+	assert.NoError(t, repo.Delete(ctx, created.ID, 1))
+	assert.NoError(t, repo.Delete(ctx, created.ID, 0), "An unconditional delete of a deleted planet is not an error")
+	_, err := repo.FindByID(ctx, created.ID)
+	assert.Equal(t, repository.ErrNotFound, err)
+	_, err = repo.Update(ctx, planet.Planet{ID: created.ID, Name: "Alderaan"})
+	assert.Equal(t, repository.ErrNotFound, err, "A deleted planet must be restored before the update")
+
+	deleted, err := repo.FindDeleted(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	assert.NotNil(t, deleted[0].DeletedAt)
+
+	restored, err := repo.Restore(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, int64(3), restored.Version)
+	_, err = repo.Restore(ctx, created.ID)
+	assert.Equal(t, repository.ErrNotFound, err)
+
+	assert.NoError(t, repo.Delete(ctx, created.ID, 0))
+	purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	purged, err = repo.Purge(ctx, time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	deleted, _ = repo.FindDeleted(ctx)
+	assert.Empty(t, deleted)
+}
+
+func TestBulk(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	existing, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine"})
+	ops := []repository.BulkOperation{
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Hoth"}},
+		{Type: repository.BulkUpdate, Planet: planet.Planet{ID: existing.ID, Name: "Tatooine", Climate: "arid"}},
+		{Type: "unknown", Planet: planet.Planet{ID: "x"}},
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: existing.ID}},
+	}
+
+	results, err := repo.Bulk(ctx, ops, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.NotEmpty(t, results[0].ID)
+	assert.Equal(t, repository.BulkResult{ID: existing.ID}, results[1])
+	assert.Error(t, results[2].Err)
+	assert.Equal(t, repository.BulkResult{ID: existing.ID, Err: repository.ErrNotExecuted}, results[3])
+	found, _ := repo.FindByID(ctx, existing.ID)
+	assert.Equal(t, "arid", found.Climate)
+
+	results, err = repo.Bulk(ctx, ops[2:], false)
+	assert.NoError(t, err)
+	assert.Error(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	_, err = repo.FindByID(ctx, existing.ID)
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestTheFindersProjectTheFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+
+	found, err := repo.FindByID(ctx, created.ID, repository.FieldClimate)
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{ID: created.ID, Climate: "arid", Version: 1}, found)
+	found, err = repo.FindByName(ctx, "Tatooine", repository.FieldName, repository.FieldTerrain)
+	assert.NoError(t, err)
+	assert.Equal(t, planet.Planet{ID: created.ID, Name: "Tatooine", Terrain: "desert", Version: 1}, found)
+	all, err := repo.FindAll(ctx, repository.FieldName)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{{ID: created.ID, Name: "Tatooine", Version: 1}}, all)
+	page, err := repo.FindPage(ctx, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{created}, page, "No fields should be the whole planet")
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	tatooine, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	hoth, _ := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+	assert.NoError(t, repo.Delete(ctx, hoth.ID, 0))
+
+	results, err := repo.Search(ctx, "TATOOINE", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []repository.SearchResult{{Planet: tatooine, Score: 10}}, results)
+	results, err = repo.Search(ctx, "tatoine", 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1, "A typo should fall back to the close words")
+	results, err = repo.Search(ctx, "hoth", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results, "The deleted planets should not be found")
+}
+
+func TestTheNamesAreUniqueOnTheirKey(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	alderaan, _ := repo.Create(ctx, planet.Planet{Name: "Alderaan"})
+	hoth, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+
+	found, err := repo.FindByName(ctx, " alderaan ")
+	assert.NoError(t, err)
+	assert.Equal(t, alderaan, found)
+
+	_, err = repo.Create(ctx, planet.Planet{Name: "ALDERAAN"})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	_, err = repo.Update(ctx, planet.Planet{ID: hoth.ID, Name: "alderaan"})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	name := "Alderaan "
+	_, err = repo.Patch(ctx, hoth.ID, planet.Update{Name: &name})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	results, _ := repo.Bulk(ctx, []repository.BulkOperation{{Type: repository.BulkCreate, Planet: planet.Planet{Name: "hoth"}}}, true)
+	assert.Equal(t, repository.ErrDuplicateName, results[0].Err)
+	_, err = repo.Update(ctx, planet.Planet{ID: alderaan.ID, Name: "alderaan", Climate: "temperate"})
+	assert.NoError(t, err, "A planet should keep its own name")
+
+	assert.NoError(t, repo.Delete(ctx, alderaan.ID, 0))
+	_, err = repo.Create(ctx, planet.Planet{Name: "Alderaan"})
+	assert.NoError(t, err, "A deleted planet should free its name")
+	_, err = repo.Restore(ctx, alderaan.ID)
+	assert.Equal(t, repository.ErrDuplicateName, err)
+}
@@ -1,23 +1,46 @@
 package mongorep
 
 import (
+	"time"
+
 	"github.com/rafaelreinert/stars/pkg/planet"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// planetMongoModel is the planet document, the nameKey is the planet.NameKey of the name which the unique index holds,
+// it is removed from the deleted planets so their names can be used again
 type planetMongoModel struct {
-	ID      primitive.ObjectID `bson:"_id,omitempty"`
-	Name    string             `bson:"name,omitempty"`
-	Climate string             `bson:"climate,omitempty"`
-	Terrain string             `bson:"terrain,omitempty"`
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	NameKey   string             `bson:"nameKey,omitempty"`
+	Climate   string             `bson:"climate"`
+	Terrain   string             `bson:"terrain"`
+	Version   int64              `bson:"version"`
+	DeletedAt *time.Time         `bson:"deletedAt,omitempty"`
 }
 
 // ToPlanet convert the planetMongoModel to a Planet
 func (p planetMongoModel) ToPlanet() planet.Planet {
 	return planet.Planet{
-		ID:      p.ID.Hex(),
-		Name:    p.Name,
-		Climate: p.Climate,
-		Terrain: p.Terrain,
+		ID:        p.ID.Hex(),
+		Name:      p.Name,
+		Climate:   p.Climate,
+		Terrain:   p.Terrain,
+		Version:   p.Version,
+		DeletedAt: p.DeletedAt,
+	}
+}
+
+// newPlanetMongoModel converts the Planet to a planetMongoModel, an invalid id is left empty
+func newPlanetMongoModel(p planet.Planet) planetMongoModel {
+	oID, _ := primitive.ObjectIDFromHex(p.ID)
+	return planetMongoModel{
+		ID:        oID,
+		Name:      p.Name,
+		NameKey:   planet.NameKey(p.Name),
+		Climate:   p.Climate,
+		Terrain:   p.Terrain,
+		Version:   p.Version,
+		DeletedAt: p.DeletedAt,
 	}
 }
@@ -0,0 +1,221 @@
+package mongorep
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type outboxMongoModel struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Type         string             `bson:"type"`
+	PlanetID     string             `bson:"planetId"`
+	Planet       *planetMongoModel  `bson:"planet,omitempty"`
+	At           time.Time          `bson:"at"`
+	ClaimedUntil time.Time          `bson:"claimedUntil"`
+}
+
+// ToEvent converts the outbox entry to the event it records, the event id is the entry id
+func (m outboxMongoModel) ToEvent() event.Event {
+	e := event.Event{ID: m.ID.Hex(), Type: m.Type, PlanetID: m.PlanetID, At: m.At}
+	if m.Planet != nil {
+		p := m.Planet.ToPlanet()
+		e.Planet = &p
+	}
+	return e
+}
+
+type planetOutboxRepositoryImpl struct {
+	planetMongoRepositoryImpl
+	Outbox *mongo.Collection
+}
+
+// NewOutboxRepository creates a Repository which writes every planet change and its event on the outbox collection
+// in the same transaction, MongoDB must run as a replica set.
+// It creates the indexes first, which also creates the collections since MongoDB before 4.4 can not create them in a transaction.
+func NewOutboxRepository(ctx context.Context, db *mongo.Database) (repository.PlanetRepository, error) {
+	r := planetOutboxRepositoryImpl{
+		planetMongoRepositoryImpl: planetMongoRepositoryImpl{Collection: db.Collection("planet")},
+		Outbox:                    db.Collection("outbox"),
+	}
+	_, err := r.Outbox.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "claimedUntil", Value: 1}, {Key: "_id", Value: 1}}})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating the outbox index")
+	}
+	_, err = r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"deletedAt": 1}})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating the planet index")
+	}
+	return r, nil
+}
+
+// transaction runs fn in a transaction, it is retried by the driver on transient errors
+func (r planetOutboxRepositoryImpl) transaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := r.Collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// record writes the event of the change on the outbox, it must run in the transaction of the change
+func (r planetOutboxRepositoryImpl) record(ctx context.Context, eventType, planetID string, p *planet.Planet) error {
+	entry := outboxMongoModel{ID: primitive.NewObjectID(), Type: eventType, PlanetID: planetID, At: time.Now().UTC()}
+	if p != nil {
+		model := newPlanetMongoModel(*p)
+		entry.Planet = &model
+	}
+	_, err := r.Outbox.InsertOne(ctx, entry)
+	return err
+}
+
+// Create a new planet and its planet.created event on Mongo
+func (r planetOutboxRepositoryImpl) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	var created planet.Planet
+	err := r.transaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		created, err = r.planetMongoRepositoryImpl.Create(sc, p)
+		if err != nil {
+			return err
+		}
+		return r.record(sc, event.TypeCreated, created.ID, &created)
+	})
+	return created, err
+}
+
+// Update a planet and write its planet.updated event on Mongo
+func (r planetOutboxRepositoryImpl) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
+	var updated planet.Planet
+	err := r.transaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		updated, err = r.planetMongoRepositoryImpl.Update(sc, p)
+		if err != nil {
+			return err
+		}
+		return r.record(sc, event.TypeUpdated, updated.ID, &updated)
+	})
+	return updated, err
+}
+
+// Patch a planet and write its planet.updated event on Mongo
+func (r planetOutboxRepositoryImpl) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	var patched planet.Planet
+	err := r.transaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		patched, err = r.planetMongoRepositoryImpl.Patch(sc, id, u)
+		if err != nil {
+			return err
+		}
+		return r.record(sc, event.TypeUpdated, patched.ID, &patched)
+	})
+	return patched, err
+}
+
+// Delete a planet and write its planet.deleted event on Mongo, no event is written when no planet was deleted
+func (r planetOutboxRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	return r.transaction(ctx, func(sc mongo.SessionContext) error {
+		deleted, err := r.planetMongoRepositoryImpl.delete(sc, id, version)
+		if err != nil || !deleted {
+			return err
+		}
+		return r.record(sc, event.TypeDeleted, id, nil)
+	})
+}
+
+// Restore a planet and write its planet.created event on Mongo, since it is back on the listings
+func (r planetOutboxRepositoryImpl) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	var restored planet.Planet
+	err := r.transaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		restored, err = r.planetMongoRepositoryImpl.Restore(sc, id)
+		if err != nil {
+			return err
+		}
+		return r.record(sc, event.TypeCreated, id, &restored)
+	})
+	return restored, err
+}
+
+// Bulk runs each operation in its own transaction with its event, a failed write aborts the whole transaction
+// on Mongo so the operations can not share one
+func (r planetOutboxRepositoryImpl) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	results := make([]repository.BulkResult, len(ops))
+	for i, op := range ops {
+		var err error
+		switch op.Type {
+		case repository.BulkCreate:
+			var created planet.Planet
+			created, err = r.Create(ctx, op.Planet)
+			results[i].ID = created.ID
+		case repository.BulkUpdate:
+			p := op.Planet
+			p.Version = 0
+			_, err = r.Update(ctx, p)
+			results[i].ID = op.Planet.ID
+		case repository.BulkDelete:
+			err = r.Delete(ctx, op.Planet.ID, 0)
+			results[i].ID = op.Planet.ID
+		default:
+			err = errors.Errorf("unknown bulk operation %q", op.Type)
+			results[i].ID = op.Planet.ID
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		results[i].Err = err
+		if err != nil && ordered {
+			markNotExecuted(results[i+1:])
+			break
+		}
+	}
+	return results, nil
+}
+
+// OutboxSource is the outbox.Source of the events written by the outbox repository
+type OutboxSource struct {
+	Outbox *mongo.Collection
+}
+
+// NewOutboxSource creates the source of the relay, the outbox repository creates the outbox index
+func NewOutboxSource(db *mongo.Database) OutboxSource {
+	return OutboxSource{Outbox: db.Collection("outbox")}
+}
+
+// Claim takes the oldest entry which is not claimed by another relay, with a single atomic update on mongo
+func (s OutboxSource) Claim(ctx context.Context, now time.Time, lease time.Duration) (event.Event, bool, error) {
+	filter := bson.M{"claimedUntil": bson.M{"$lte": now.UTC()}}
+	update := bson.M{"$set": bson.M{"claimedUntil": now.Add(lease).UTC()}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"_id": 1}).SetReturnDocument(options.After)
+
+	var model outboxMongoModel
+	err := s.Outbox.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return event.Event{}, false, nil
+	}
+	if err != nil {
+		return event.Event{}, false, err
+	}
+	return model.ToEvent(), true, nil
+}
+
+// Ack removes the delivered entry from mongo
+func (s OutboxSource) Ack(ctx context.Context, id string) error {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.Outbox.DeleteOne(ctx, bson.M{"_id": oID})
+	return err
+}
@@ -0,0 +1,166 @@
+package mongorep
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// skipWithoutReplicaSet skips the transaction tests on a standalone MongoDB
+func skipWithoutReplicaSet(t *testing.T, client *mongo.Client) {
+	var status struct {
+		SetName string `bson:"setName"`
+	}
+	err := client.Database("admin").RunCommand(context.Background(), bson.M{"isMaster": 1}).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.SetName == "" {
+		t.Skip("The outbox transactions require MongoDB running as a replica set")
+	}
+}
+
+// claimAll drains the outbox like a relay would
+func claimAll(t *testing.T, source OutboxSource) []event.Event {
+	var events []event.Event
+	for {
+		e, ok, err := source.Claim(context.Background(), time.Now(), time.Minute)
+		assert.NoError(t, err)
+		if !ok {
+			return events
+		}
+		assert.NoError(t, source.Ack(context.Background(), e.ID))
+		events = append(events, e)
+	}
+}
+
+func TestOutboxRepository(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+	skipWithoutReplicaSet(t, client)
+
+	ctx := context.Background()
+	repo, err := NewOutboxRepository(ctx, client.Database("starwars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := NewOutboxSource(client.Database("starwars"))
+
+	created, err := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen"})
+	assert.NoError(t, err)
+	_, err = repo.Update(ctx, planet.Planet{ID: created.ID, Name: "Hoth", Climate: "cold", Version: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(ctx, created.ID, 0))
+	assert.NoError(t, repo.Delete(ctx, created.ID, 0), "Deleting twice is not an error")
+
+	events := claimAll(t, source)
+	if assert.Len(t, events, 3, "No event should be written when nothing was deleted") {
+		assert.Equal(t, event.TypeCreated, events[0].Type)
+		assert.Equal(t, created, *events[0].Planet)
+		assert.Equal(t, event.TypeUpdated, events[1].Type)
+		assert.Equal(t, "cold", events[1].Planet.Climate)
+		assert.Equal(t, int64(2), events[1].Planet.Version)
+		assert.Equal(t, event.TypeDeleted, events[2].Type)
+		assert.Equal(t, created.ID, events[2].PlanetID)
+		assert.Nil(t, events[2].Planet)
+	}
+}
+
+func TestOutboxRepositoryWritesNoEventOnFailure(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+	skipWithoutReplicaSet(t, client)
+
+	ctx := context.Background()
+	repo, err := NewOutboxRepository(ctx, client.Database("starwars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+	source := NewOutboxSource(client.Database("starwars"))
+	claimAll(t, source)
+
+	_, err = repo.Update(ctx, planet.Planet{ID: created.ID, Name: "Hoth", Version: 7})
+	assert.Equal(t, repository.ErrVersionMismatch, err)
+	_, err = repo.Patch(ctx, primitive.NewObjectID().Hex(), planet.Update{Version: 1})
+	assert.Equal(t, repository.ErrNotFound, err)
+
+	assert.Empty(t, claimAll(t, source))
+}
+
+func TestOutboxRepositoryBulk(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+	skipWithoutReplicaSet(t, client)
+
+	ctx := context.Background()
+	repo, err := NewOutboxRepository(ctx, client.Database("starwars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := repo.Bulk(ctx, []repository.BulkOperation{
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Hoth"}},
+		{Type: repository.BulkUpdate, Planet: planet.Planet{ID: "invalid", Name: "Endor"}},
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Naboo"}},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, repository.ErrNotExecuted, results[2].Err)
+	events := claimAll(t, NewOutboxSource(client.Database("starwars")))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, results[0].ID, events[0].PlanetID)
+	}
+}
+
+func TestOutboxSourceClaim(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	outbox := client.Database("starwars").Collection("outbox")
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	outbox.InsertOne(ctx, outboxMongoModel{ID: second, Type: event.TypeDeleted, PlanetID: "p2"})
+	outbox.InsertOne(ctx, outboxMongoModel{ID: first, Type: event.TypeDeleted, PlanetID: "p1"})
+	source := NewOutboxSource(client.Database("starwars"))
+	now := time.Now()
+
+	e, ok, err := source.Claim(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, first.Hex(), e.ID, "The oldest entry should be claimed first")
+	e, ok, _ = source.Claim(ctx, now, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, second.Hex(), e.ID)
+	_, ok, _ = source.Claim(ctx, now, time.Minute)
+	assert.False(t, ok, "The claimed entries should be hidden until the lease ends")
+
+	e, ok, _ = source.Claim(ctx, now.Add(2*time.Minute), time.Minute)
+	assert.True(t, ok, "An entry not acknowledged should be claimed again after the lease")
+	assert.Equal(t, first.Hex(), e.ID)
+}
@@ -2,15 +2,22 @@ package mongorep
 
 import (
 	"context"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rafaelreinert/stars/pkg/planet"
 	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/search"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// notDeleted matches the planets without the deletedAt tombstone
+var notDeleted = bson.M{"$exists": false}
+
 type planetMongoRepositoryImpl struct {
 	Collection *mongo.Collection
 }
@@ -20,15 +27,71 @@ func NewMongoRepository(db *mongo.Database) repository.PlanetRepository {
 	return planetMongoRepositoryImpl{Collection: db.Collection("planet")}
 }
 
+// nameKeyIndex is the name of the unique index of the planet.NameKey
+const nameKeyIndex = "nameKey"
+
+// CreateIndexes creates the indexes of the planet collection, the text index of the search and the unique index of the names.
+// The name keys of the planets written before the index are filled first, the index fails when two of them are the same.
+func CreateIndexes(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("planet")
+	if err := fillNameKeys(ctx, collection); err != nil {
+		return errors.Wrap(err, "Error filling the planet name keys")
+	}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "climate", Value: "text"}, {Key: "terrain", Value: "text"}},
+			// the planet names are not english, so the words are neither stemmed nor dropped as stop words
+			Options: options.Index().SetName("search").SetDefaultLanguage("none").SetWeights(bson.M{
+				"name":    search.NameWeight,
+				"climate": search.ClimateWeight,
+				"terrain": search.TerrainWeight,
+			}),
+		},
+		{
+			Keys: bson.M{"nameKey": 1},
+			// the deleted planets have no key and the empty names are not unique
+			Options: options.Index().SetName(nameKeyIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"nameKey": bson.M{"$gt": ""}}),
+		},
+	})
+	return errors.Wrap(err, "Error creating the planet indexes, the names must be unique")
+}
+
+// fillNameKeys sets the key of the planets which were not deleted and have none
+func fillNameKeys(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Find(ctx, bson.M{"nameKey": bson.M{"$exists": false}, "deletedAt": notDeleted},
+		options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var model planetMongoModel
+		if err := cursor.Decode(&model); err != nil {
+			return err
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": model.ID}, bson.M{"$set": bson.M{"nameKey": planet.NameKey(model.Name)}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
 // Create a new planet on Mongo
 func (r planetMongoRepositoryImpl) Create(ctx context.Context, p planet.Planet) (planet.Planet, error) {
 	model := planetMongoModel{
 		ID:      primitive.NewObjectID(),
 		Name:    p.Name,
+		NameKey: planet.NameKey(p.Name),
 		Climate: p.Climate,
 		Terrain: p.Terrain,
+		Version: 1,
 	}
 	result, err := r.Collection.InsertOne(ctx, model)
+	if isDuplicateName(err) {
+		return planet.Planet{}, repository.ErrDuplicateName
+	}
 	if err != nil {
 		return planet.Planet{}, err
 	}
@@ -36,16 +99,19 @@ func (r planetMongoRepositoryImpl) Create(ctx context.Context, p planet.Planet)
 	return model.ToPlanet(), nil
 }
 
-// FindByID finds a planet on Mongo using the id
-func (r planetMongoRepositoryImpl) FindByID(ctx context.Context, id string) (planet.Planet, error) {
+// FindByID finds a planet on Mongo using the id, it returns repository.ErrNotFound when there is none
+func (r planetMongoRepositoryImpl) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
 	oID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return planet.Planet{}, err
 	}
-	result := r.Collection.FindOne(ctx, bson.M{"_id": oID})
+	result := r.Collection.FindOne(ctx, bson.M{"_id": oID, "deletedAt": notDeleted}, options.FindOne().SetProjection(projection(fields)))
 
 	var model planetMongoModel
 	err = result.Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return planet.Planet{}, repository.ErrNotFound
+	}
 	if err != nil {
 		return planet.Planet{}, err
 	}
@@ -53,12 +119,15 @@ func (r planetMongoRepositoryImpl) FindByID(ctx context.Context, id string) (pla
 	return model.ToPlanet(), nil
 }
 
-// FindByName finds a planet on Mongo using the planet name
-func (r planetMongoRepositoryImpl) FindByName(ctx context.Context, name string) (planet.Planet, error) {
-	result := r.Collection.FindOne(ctx, bson.M{"name": name})
+// FindByName finds a planet on Mongo using the planet.NameKey of the name, it returns repository.ErrNotFound when there is none
+func (r planetMongoRepositoryImpl) FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error) {
+	result := r.Collection.FindOne(ctx, bson.M{"nameKey": planet.NameKey(name), "deletedAt": notDeleted}, options.FindOne().SetProjection(projection(fields)))
 
 	var model planetMongoModel
 	err := result.Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return planet.Planet{}, repository.ErrNotFound
+	}
 	if err != nil {
 		return planet.Planet{}, err
 	}
@@ -66,9 +135,103 @@ func (r planetMongoRepositoryImpl) FindByName(ctx context.Context, name string)
 	return model.ToPlanet(), nil
 }
 
-// FindAll finds all planets on Mongo
-func (r planetMongoRepositoryImpl) FindAll(ctx context.Context) ([]planet.Planet, error) {
-	result, err := r.Collection.Find(ctx, bson.M{})
+// FindAll finds all planets on Mongo which were not deleted
+func (r planetMongoRepositoryImpl) FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error) {
+	return r.find(ctx, bson.M{"deletedAt": notDeleted}, options.Find().SetProjection(projection(fields)))
+}
+
+// FindPage finds a page of the planets on Mongo which were not deleted, ordered by id
+func (r planetMongoRepositoryImpl) FindPage(ctx context.Context, after string, limit int, fields ...string) ([]planet.Planet, error) {
+	filter := bson.M{"deletedAt": notDeleted}
+	if after != "" {
+		oID, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return nil, err
+		}
+		filter["_id"] = bson.M{"$gt": oID}
+	}
+	return r.find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit)).SetProjection(projection(fields)))
+}
+
+// projection reads only the given planet fields with the id, the version and the tombstone,
+// it is nil, so every field is read, when none is given
+func projection(fields []string) interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	p := bson.M{"version": 1, "deletedAt": 1}
+	for _, field := range fields {
+		p[field] = 1
+	}
+	return p
+}
+
+// FindEach reads the planets on Mongo which were not deleted from a cursor, ordered by id
+func (r planetMongoRepositoryImpl) FindEach(ctx context.Context, fn func(planet.Planet) error) error {
+	cursor, err := r.Collection.Find(ctx, bson.M{"deletedAt": notDeleted}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var model planetMongoModel
+		if err := cursor.Decode(&model); err != nil {
+			return err
+		}
+		if err := fn(model.ToPlanet()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// scoredPlanetMongoModel is a planet found by the text search with its text score
+type scoredPlanetMongoModel struct {
+	planetMongoModel `bson:",inline"`
+	Score            float64 `bson:"score"`
+}
+
+// Search finds the planets on the text index, which ignores the case and the diacritics, ranked by the text score.
+// When no word matches it ranks the names, climates and terrains by their distance to the query, see search.Fuzzy.
+func (r planetMongoRepositoryImpl) Search(ctx context.Context, query string, limit int) ([]repository.SearchResult, error) {
+	// the words are searched alone, so the quotes and the minus signs are not the phrases and negations of $text
+	words := search.Words(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	cursor, err := r.Collection.Find(ctx,
+		bson.M{"$text": bson.M{"$search": strings.Join(words, " ")}, "deletedAt": notDeleted},
+		options.Find().SetProjection(score).SetSort(score).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var models []scoredPlanetMongoModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	if len(models) > 0 {
+		results := make([]repository.SearchResult, len(models))
+		for i, m := range models {
+			results[i] = repository.SearchResult{Planet: m.ToPlanet(), Score: m.Score}
+		}
+		return results, nil
+	}
+
+	planets, err := r.FindAll(ctx, repository.FieldName, repository.FieldClimate, repository.FieldTerrain)
+	if err != nil {
+		return nil, err
+	}
+	return search.Fuzzy(planets, query, limit), nil
+}
+
+// FindDeleted finds the deleted planets which were not purged yet, the last deleted first
+func (r planetMongoRepositoryImpl) FindDeleted(ctx context.Context) ([]planet.Planet, error) {
+	return r.find(ctx, bson.M{"deletedAt": bson.M{"$exists": true}}, options.Find().SetSort(bson.M{"deletedAt": -1}))
+}
+
+func (r planetMongoRepositoryImpl) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]planet.Planet, error) {
+	result, err := r.Collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,34 +249,276 @@ func (r planetMongoRepositoryImpl) FindAll(ctx context.Context) ([]planet.Planet
 	return planets, nil
 }
 
-// Update a planet on mongo
+// Update a planet on mongo, it is created when it does not exist and no version is expected,
+// a deleted planet must be restored before it can be updated
 func (r planetMongoRepositoryImpl) Update(ctx context.Context, p planet.Planet) (planet.Planet, error) {
 	oID, err := primitive.ObjectIDFromHex(p.ID)
 	if err != nil {
 		return planet.Planet{}, err
 	}
-	model := planetMongoModel{
-		ID:      oID,
-		Name:    p.Name,
-		Climate: p.Climate,
-		Terrain: p.Terrain,
-	}
+	set := bson.M{"name": p.Name, "nameKey": planet.NameKey(p.Name), "climate": p.Climate, "terrain": p.Terrain}
+	return r.compareAndSet(ctx, oID, p.Version, set, p.Version == 0)
+}
 
-	opts := options.Update().SetUpsert(true)
-	_, err = r.Collection.UpdateOne(ctx, bson.M{"_id": model.ID}, bson.D{{"$set", model}}, opts)
+// Patch changes only the fields set on the update, in a single atomic update on mongo
+func (r planetMongoRepositoryImpl) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	oID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return planet.Planet{}, err
 	}
 
+	set := bson.M{}
+	if u.Name != nil {
+		set["name"] = *u.Name
+		set["nameKey"] = planet.NameKey(*u.Name)
+	}
+	if u.Climate != nil {
+		set["climate"] = *u.Climate
+	}
+	if u.Terrain != nil {
+		set["terrain"] = *u.Terrain
+	}
+	return r.compareAndSet(ctx, oID, u.Version, set, false)
+}
+
+// compareAndSet sets the fields and increments the version when the document is still on the expected version
+func (r planetMongoRepositoryImpl) compareAndSet(ctx context.Context, id primitive.ObjectID, version int64, set bson.M, upsert bool) (planet.Planet, error) {
+	filter := bson.M{"_id": id, "deletedAt": notDeleted}
+	if version != 0 {
+		filter["version"] = version
+	}
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(upsert)
+
+	var model planetMongoModel
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return planet.Planet{}, r.missingOrMismatch(ctx, id, version)
+	}
+	if isDuplicateName(err) {
+		return planet.Planet{}, repository.ErrDuplicateName
+	}
+	if isDuplicateKey(err) {
+		// the upsert collided with the tombstone of a deleted planet
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
 	return model.ToPlanet(), nil
 }
 
-// Delete a planet on mongo
-func (r planetMongoRepositoryImpl) Delete(ctx context.Context, id string) error {
-	oID, err := primitive.ObjectIDFromHex(id)
+// missingOrMismatch tells why a conditional change matched no document
+func (r planetMongoRepositoryImpl) missingOrMismatch(ctx context.Context, id primitive.ObjectID, version int64) error {
+	if version == 0 {
+		return repository.ErrNotFound
+	}
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": notDeleted})
 	if err != nil {
 		return err
 	}
-	_, err = r.Collection.DeleteOne(ctx, bson.M{"_id": oID})
+	if count == 0 {
+		return repository.ErrNotFound
+	}
+	return repository.ErrVersionMismatch
+}
+
+// Delete marks a planet as deleted on mongo, when a version is given the planet is only deleted if it is still on that version.
+// The planet is kept with the deletedAt tombstone until Purge removes it.
+func (r planetMongoRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	_, err := r.delete(ctx, id, version)
 	return err
 }
+
+// delete sets the tombstone and reports whether a planet was deleted, an unconditional delete of a missing planet is not an error
+func (r planetMongoRepositoryImpl) delete(ctx context.Context, id string, version int64) (bool, error) {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": oID, "deletedAt": notDeleted}
+	if version != 0 {
+		filter["version"] = version
+	}
+	result, err := r.Collection.UpdateOne(ctx, filter, tombstone(time.Now()))
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 && version != 0 {
+		return false, r.missingOrMismatch(ctx, oID, version)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Restore removes the tombstone of a deleted planet on mongo and gives its name key back,
+// it fails with repository.ErrDuplicateName when another planet took the name meanwhile
+func (r planetMongoRepositoryImpl) Restore(ctx context.Context, id string) (planet.Planet, error) {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	filter := bson.M{"_id": oID, "deletedAt": bson.M{"$exists": true}}
+	var deleted planetMongoModel
+	err = r.Collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"name": 1})).Decode(&deleted)
+	if err == mongo.ErrNoDocuments {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
+
+	update := bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+		"$set":   bson.M{"nameKey": planet.NameKey(deleted.Name)},
+		"$inc":   bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var model planetMongoModel
+	err = r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return planet.Planet{}, repository.ErrNotFound
+	}
+	if isDuplicateName(err) {
+		return planet.Planet{}, repository.ErrDuplicateName
+	}
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	return model.ToPlanet(), nil
+}
+
+// Purge removes from mongo the planets deleted before the given time
+func (r planetMongoRepositoryImpl) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.Collection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": before.UTC()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// tombstone marks the planet as deleted and frees its name key
+func tombstone(now time.Time) bson.M {
+	return bson.M{"$set": bson.M{"deletedAt": now.UTC()}, "$unset": bson.M{"nameKey": ""}, "$inc": bson.M{"version": 1}}
+}
+
+// Bulk runs the operations with a single BulkWrite on mongo
+func (r planetMongoRepositoryImpl) Bulk(ctx context.Context, ops []repository.BulkOperation, ordered bool) ([]repository.BulkResult, error) {
+	results := make([]repository.BulkResult, len(ops))
+	models := make([]mongo.WriteModel, 0, len(ops))
+	// positions keeps the operation position of each model, the write errors are indexed by model
+	positions := make([]int, 0, len(ops))
+	for i, op := range ops {
+		model, id, err := bulkWriteModel(op)
+		results[i].ID = id
+		if err != nil {
+			results[i].Err = err
+			if ordered {
+				markNotExecuted(results[i+1:])
+				break
+			}
+			continue
+		}
+		models = append(models, model)
+		positions = append(positions, i)
+	}
+	if len(models) == 0 {
+		return results, nil
+	}
+
+	_, err := r.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+	if err == nil {
+		return results, nil
+	}
+	bulkErr, ok := err.(mongo.BulkWriteException)
+	if !ok || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		results[positions[writeErr.Index]].Err = errors.New(writeErr.Message)
+		if isDuplicateNameError(writeErr.WriteError) {
+			results[positions[writeErr.Index]].Err = repository.ErrDuplicateName
+		}
+	}
+	if ordered && len(bulkErr.WriteErrors) > 0 {
+		for _, position := range positions[bulkErr.WriteErrors[0].Index+1:] {
+			results[position].Err = repository.ErrNotExecuted
+		}
+	}
+	return results, nil
+}
+
+// bulkWriteModel converts the operation to the mongo write model and returns the id of the changed planet
+func bulkWriteModel(op repository.BulkOperation) (mongo.WriteModel, string, error) {
+	if op.Type == repository.BulkCreate {
+		model := planetMongoModel{
+			ID:      primitive.NewObjectID(),
+			Name:    op.Planet.Name,
+			NameKey: planet.NameKey(op.Planet.Name),
+			Climate: op.Planet.Climate,
+			Terrain: op.Planet.Terrain,
+			Version: 1,
+		}
+		return mongo.NewInsertOneModel().SetDocument(model), model.ID.Hex(), nil
+	}
+
+	oID, err := primitive.ObjectIDFromHex(op.Planet.ID)
+	if err != nil {
+		return nil, op.Planet.ID, err
+	}
+	switch op.Type {
+	case repository.BulkUpdate:
+		update := bson.M{
+			"$set": bson.M{"name": op.Planet.Name, "nameKey": planet.NameKey(op.Planet.Name), "climate": op.Planet.Climate, "terrain": op.Planet.Terrain},
+			"$inc": bson.M{"version": 1},
+		}
+		filter := bson.M{"_id": oID, "deletedAt": notDeleted}
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true), op.Planet.ID, nil
+	case repository.BulkDelete:
+		filter := bson.M{"_id": oID, "deletedAt": notDeleted}
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(tombstone(time.Now())), op.Planet.ID, nil
+	}
+	return nil, op.Planet.ID, errors.Errorf("unknown bulk operation %q", op.Type)
+}
+
+func markNotExecuted(results []repository.BulkResult) {
+	for i := range results {
+		results[i].Err = repository.ErrNotExecuted
+	}
+}
+
+// isDuplicateName reports whether the write failed on the unique index of the name keys
+func isDuplicateName(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if isDuplicateNameError(writeErr) {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == 11000 && strings.Contains(e.Message, "index: "+nameKeyIndex+" ")
+	}
+	return false
+}
+
+func isDuplicateNameError(writeErr mongo.WriteError) bool {
+	return writeErr.Code == 11000 && strings.Contains(writeErr.Message, "index: "+nameKeyIndex+" ")
+}
+
+// isDuplicateKey reports whether the write failed on a unique index, like the _id one
+func isDuplicateKey(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if writeErr.Code == 11000 {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == 11000
+	}
+	return false
+}
@@ -2,11 +2,14 @@ package mongorep
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -65,6 +68,32 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, planetCreated.Name, planetUpdated.Name, "The names should be equals.")
 	assert.Equal(t, "cold", planetUpdated.Climate, "The climate should be cold.")
 	assert.Equal(t, planetCreated.Terrain, planetUpdated.Terrain, "The terrains should be equals.")
+	assert.Equal(t, int64(2), planetUpdated.Version, "The version should be incremented.")
+}
+
+func TestUpdateWithAStaleVersion(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+
+	first := planetCreated
+	first.Climate = "cold"
+	_, errFirst := repo.Update(ctx, first)
+	second := planetCreated
+	second.Climate = "hot"
+	_, errSecond := repo.Update(ctx, second)
+	planetFound, _ := repo.FindByID(ctx, planetCreated.ID)
+
+	assert.NoError(t, errFirst)
+	assert.Equal(t, repository.ErrVersionMismatch, errSecond)
+	assert.Equal(t, "cold", planetFound.Climate, "The stale update should not overwrite the first one.")
 }
 
 func TestUpdateWhenDocumentDoesNotExists(t *testing.T) {
@@ -117,6 +146,69 @@ func TestUpdateWithAnInvalidId(t *testing.T) {
 	assert.Equal(t, "encoding/hex: invalid byte: U+0073 's'", err.Error())
 }
 
+func TestPatch(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{
+		Name:    "Tatooine",
+		Climate: "arid",
+		Terrain: "desert",
+	})
+
+	climate := "hot"
+	empty := ""
+	planetPatched, err := repo.Patch(ctx, planetCreated.ID, planet.Update{Climate: &climate, Terrain: &empty})
+	planetFound, _ := repo.FindByID(ctx, planetCreated.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, planetPatched, planetFound)
+	assert.Equal(t, "Tatooine", planetFound.Name, "The name should not change.")
+	assert.Equal(t, "hot", planetFound.Climate, "The climate should be hot.")
+	assert.Equal(t, "", planetFound.Terrain, "The terrain should be cleared.")
+}
+
+func TestPatchWhenDocumentDoesNotExists(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	climate := "hot"
+
+	_, err = repo.Patch(ctx, primitive.NewObjectID().Hex(), planet.Update{Climate: &climate})
+
+	assert.Equal(t, repository.ErrNotFound, err)
+}
+
+func TestPatchWithAStaleVersion(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	climate := "hot"
+
+	_, err = repo.Patch(ctx, planetCreated.ID, planet.Update{Version: planetCreated.Version + 1, Climate: &climate})
+
+	assert.Equal(t, repository.ErrVersionMismatch, err)
+}
+
 func TestDelete(t *testing.T) {
 	client, err := ConnectMongoClient()
 	if err != nil {
@@ -135,11 +227,91 @@ func TestDelete(t *testing.T) {
 
 	planetCreated, err := repo.Create(ctx, planetToCreate)
 
-	err = repo.Delete(ctx, planetCreated.ID)
+	err = repo.Delete(ctx, planetCreated.ID, 0)
 	_, findErr := repo.FindByID(ctx, planetCreated.ID)
 
 	assert.NoError(t, err)
-	assert.Error(t, findErr)
+	assert.Equal(t, repository.ErrNotFound, findErr)
+}
+
+func TestRestore(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	_ = repo.Delete(ctx, planetCreated.ID, 0)
+
+	deleted, errDeleted := repo.FindDeleted(ctx)
+	all, _ := repo.FindAll(ctx)
+	_, errUpdate := repo.Update(ctx, planetCreated)
+	restored, err := repo.Restore(ctx, planetCreated.ID)
+	planetFound, errFind := repo.FindByID(ctx, planetCreated.ID)
+	_, errRestoreAgain := repo.Restore(ctx, planetCreated.ID)
+
+	assert.NoError(t, errDeleted)
+	if assert.Len(t, deleted, 1) {
+		assert.NotNil(t, deleted[0].DeletedAt)
+	}
+	assert.Empty(t, all, "The finders should ignore the deleted planets")
+	assert.Equal(t, repository.ErrNotFound, errUpdate, "A deleted planet should not be updated")
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.NoError(t, errFind)
+	assert.Equal(t, restored, planetFound)
+	assert.Equal(t, repository.ErrNotFound, errRestoreAgain)
+}
+
+func TestPurge(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	deleted, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine"})
+	_, _ = repo.Create(ctx, planet.Planet{Name: "Hoth"})
+	_ = repo.Delete(ctx, deleted.ID, 0)
+
+	kept, errKept := repo.Purge(ctx, time.Now().Add(-time.Hour))
+	purged, err := repo.Purge(ctx, time.Now().Add(time.Second))
+	_, errRestore := repo.Restore(ctx, deleted.ID)
+	all, _ := repo.FindAll(ctx)
+
+	assert.NoError(t, errKept)
+	assert.Equal(t, int64(0), kept, "The planets deleted within the retention should be kept")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, repository.ErrNotFound, errRestore)
+	assert.Len(t, all, 1)
+}
+
+func TestDeleteWithAStaleVersion(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+
+	err = repo.Delete(ctx, planetCreated.ID, planetCreated.Version+1)
+	_, findErr := repo.FindByID(ctx, planetCreated.ID)
+
+	assert.Equal(t, repository.ErrVersionMismatch, err)
+	assert.NoError(t, findErr)
 }
 
 func TestDeleteWithAnInvalidId(t *testing.T) {
@@ -153,7 +325,7 @@ func TestDeleteWithAnInvalidId(t *testing.T) {
 	ctx := context.Background()
 	repo := NewMongoRepository(client.Database("starwars"))
 
-	err = repo.Delete(ctx, "sdfsd")
+	err = repo.Delete(ctx, "sdfsd", 0)
 
 	assert.Error(t, err)
 	assert.Equal(t, "encoding/hex: invalid byte: U+0073 's'", err.Error())
@@ -199,8 +371,7 @@ func TestFindByIdWhenDocumentDoesNotExists(t *testing.T) {
 
 	_, err = repo.FindByID(ctx, primitive.NewObjectID().Hex())
 
-	assert.Error(t, err)
-	assert.Equal(t, "mongo: no documents in result", err.Error())
+	assert.Equal(t, repository.ErrNotFound, err, "The API answers 404 on ErrNotFound like with the memory repository")
 }
 
 func TestFindByIdWithAnInvalidID(t *testing.T) {
@@ -260,7 +431,7 @@ func TestFindByNameWhenDocumentDoesNotExists(t *testing.T) {
 
 	_, err = repo.FindByName(ctx, "Pluto")
 
-	assert.Error(t, err)
+	assert.Equal(t, repository.ErrNotFound, err, "The importer upsert relies on ErrNotFound")
 }
 
 func TestFindAll(t *testing.T) {
@@ -313,12 +484,72 @@ func TestFindAllEmpty(t *testing.T) {
 	assert.Empty(t, planets)
 }
 
+func TestFindPage(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	first, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	deleted, _ := repo.Create(ctx, planet.Planet{Name: "Alderaan", Climate: "temperate", Terrain: "grasslands"})
+	last, _ := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+	assert.NoError(t, repo.Delete(ctx, deleted.ID, 0))
+
+	page, err := repo.FindPage(ctx, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{first}, page)
+
+	page, err = repo.FindPage(ctx, first.ID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{last}, page)
+
+	page, err = repo.FindPage(ctx, last.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	_, err = repo.FindPage(ctx, "invalid", 10)
+	assert.Error(t, err)
+}
+
+func TestFindEach(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	first, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	deleted, _ := repo.Create(ctx, planet.Planet{Name: "Alderaan", Climate: "temperate", Terrain: "grasslands"})
+	last, _ := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+	assert.NoError(t, repo.Delete(ctx, deleted.ID, 0))
+
+	var found []planet.Planet
+	err = repo.FindEach(ctx, func(p planet.Planet) error {
+		found = append(found, p)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{first, last}, found)
+
+	stop := errors.New("stop")
+	err = repo.FindEach(ctx, func(p planet.Planet) error { return stop })
+	assert.Equal(t, stop, err)
+}
+
 func ConnectMongoClient() (*mongo.Client, error) {
 	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
 	if err != nil {
 		return nil, err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	err = client.Connect(ctx)
 	if err != nil {
 		return nil, err
@@ -327,3 +558,139 @@ func ConnectMongoClient() (*mongo.Client, error) {
 	return client, nil
 
 }
+
+func TestBulk(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+
+	results, err := repo.Bulk(ctx, []repository.BulkOperation{
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Hoth", Climate: "frozen"}},
+		{Type: repository.BulkUpdate, Planet: planet.Planet{ID: planetCreated.ID, Name: "Tatooine", Climate: "hot"}},
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: "invalid"}},
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Naboo"}},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.Error(t, results[2].Err)
+	assert.Equal(t, repository.ErrNotExecuted, results[3].Err)
+	hoth, errFind := repo.FindByID(ctx, results[0].ID)
+	assert.NoError(t, errFind)
+	assert.Equal(t, "Hoth", hoth.Name)
+	tatooine, _ := repo.FindByID(ctx, planetCreated.ID)
+	assert.Equal(t, "hot", tatooine.Climate)
+	assert.Equal(t, int64(2), tatooine.Version)
+	_, errFind = repo.FindByName(ctx, "Naboo")
+	assert.Error(t, errFind, "The operations after the failure should not run")
+}
+
+func TestBulkUnordered(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	repo := NewMongoRepository(client.Database("starwars"))
+	planetCreated, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine"})
+
+	results, err := repo.Bulk(ctx, []repository.BulkOperation{
+		{Type: repository.BulkDelete, Planet: planet.Planet{ID: planetCreated.ID}},
+		{Type: "rename", Planet: planet.Planet{ID: planetCreated.ID}},
+		{Type: repository.BulkCreate, Planet: planet.Planet{Name: "Naboo"}},
+	}, false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	_, errFind := repo.FindByName(ctx, "Naboo")
+	assert.NoError(t, errFind)
+}
+
+func TestSearch(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	assert.NoError(t, CreateIndexes(ctx, client.Database("starwars")))
+	repo := NewMongoRepository(client.Database("starwars"))
+	tatooine, _ := repo.Create(ctx, planet.Planet{Name: "Tatooine", Climate: "arid", Terrain: "desert"})
+	jungle, _ := repo.Create(ctx, planet.Planet{Name: "Jungle", Climate: "humid", Terrain: "rainforests"})
+	kashyyyk, _ := repo.Create(ctx, planet.Planet{Name: "Kashyyyk", Climate: "tropical", Terrain: "jungle, forests"})
+	hoth, _ := repo.Create(ctx, planet.Planet{Name: "Hoth", Climate: "frozen", Terrain: "tundra"})
+	assert.NoError(t, repo.Delete(ctx, hoth.ID, 0))
+
+	results, err := repo.Search(ctx, "JÚNGLE", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, jungle.ID, results[0].Planet.ID, "The name should weigh more")
+		assert.Equal(t, kashyyyk.ID, results[1].Planet.ID)
+		assert.True(t, results[0].Score > results[1].Score)
+	}
+
+	results, err = repo.Search(ctx, "tatoine", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1, "A typo should fall back to the close words") {
+		assert.Equal(t, tatooine.ID, results[0].Planet.ID)
+	}
+
+	results, err = repo.Search(ctx, "hoth", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results, "The deleted planets should not be found")
+}
+
+func TestTheNamesAreUniqueOnTheirKey(t *testing.T) {
+	client, err := ConnectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database("starwars").Drop(context.Background())
+
+	ctx := context.Background()
+	// a planet written before the name keys
+	legacyID := primitive.NewObjectID()
+	_, err = client.Database("starwars").Collection("planet").InsertOne(ctx, bson.M{"_id": legacyID, "name": "Alderaan", "version": 1})
+	assert.NoError(t, err)
+	assert.NoError(t, CreateIndexes(ctx, client.Database("starwars")))
+	repo := NewMongoRepository(client.Database("starwars"))
+	hoth, _ := repo.Create(ctx, planet.Planet{Name: "Hoth"})
+
+	found, err := repo.FindByName(ctx, " alderaan ")
+	assert.NoError(t, err)
+	assert.Equal(t, legacyID.Hex(), found.ID, "The key of the old planets should be filled")
+
+	_, err = repo.Create(ctx, planet.Planet{Name: "ALDERAAN"})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	_, err = repo.Update(ctx, planet.Planet{ID: hoth.ID, Name: "alderaan"})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	name := "Alderaan "
+	_, err = repo.Patch(ctx, hoth.ID, planet.Update{Name: &name})
+	assert.Equal(t, repository.ErrDuplicateName, err)
+	results, err := repo.Bulk(ctx, []repository.BulkOperation{{Type: repository.BulkCreate, Planet: planet.Planet{Name: "hoth"}}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, repository.ErrDuplicateName, results[0].Err)
+
+	assert.NoError(t, repo.Delete(ctx, legacyID.Hex(), 0))
+	_, err = repo.Create(ctx, planet.Planet{Name: "Alderaan"})
+	assert.NoError(t, err, "A deleted planet should free its name")
+	_, err = repo.Restore(ctx, legacyID.Hex())
+	assert.Equal(t, repository.ErrDuplicateName, err)
+}
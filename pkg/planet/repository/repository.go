@@ -2,23 +2,113 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/rafaelreinert/stars/pkg/planet"
 )
 
-// PlanetRepository is the interface used to access the CRUD methods on database
+// ErrNotFound is returned when no planet matches the lookup
+var ErrNotFound = errors.New("planet not found")
+
+// ErrVersionMismatch is returned when a conditional change expected another version of the planet
+var ErrVersionMismatch = errors.New("planet version does not match")
+
+// ErrDuplicateName is returned when the change would give a planet the name of another planet which was not deleted
+var ErrDuplicateName = errors.New("a planet with this name already exists")
+
+// ErrNotExecuted is the result of the bulk operations skipped because an earlier ordered operation failed
+var ErrNotExecuted = errors.New("operation not executed, an earlier operation failed")
+
+// The planet fields a finder may be limited to, the id and the version are always read
+const (
+	FieldName    = "name"
+	FieldClimate = "climate"
+	FieldTerrain = "terrain"
+)
+
+// Project clears the planet fields which are not on the list, like a projected read, every field is kept when it is empty
+func Project(p planet.Planet, fields []string) planet.Planet {
+	if len(fields) == 0 {
+		return p
+	}
+	projected := planet.Planet{ID: p.ID, Version: p.Version, DeletedAt: p.DeletedAt}
+	for _, field := range fields {
+		switch field {
+		case FieldName:
+			projected.Name = p.Name
+		case FieldClimate:
+			projected.Climate = p.Climate
+		case FieldTerrain:
+			projected.Terrain = p.Terrain
+		}
+	}
+	return projected
+}
+
+// BulkOperationType is the kind of change made by a BulkOperation
+type BulkOperationType string
+
+// The bulk operation types
+const (
+	BulkCreate BulkOperationType = "create"
+	BulkUpdate BulkOperationType = "update"
+	BulkDelete BulkOperationType = "delete"
+)
+
+// BulkOperation is the struct which describes one change of a bulk write,
+// updates and deletes are unconditional and an update creates the planet when it does not exist, like Update
+type BulkOperation struct {
+	Type   BulkOperationType
+	Planet planet.Planet
+}
+
+// BulkResult is the struct which carries the outcome of one BulkOperation, in the same position
+type BulkResult struct {
+	ID  string
+	Err error
+}
+
+// SearchResult is the struct which carries a planet found by Search and its relevance, a higher score is a better match
+type SearchResult struct {
+	Planet planet.Planet
+	Score  float64
+}
+
+// PlanetRepository is the interface used to access the CRUD methods on database,
+// Update, Patch and Delete only apply when the planet is still on the given version, unless it is zero.
+// Delete keeps a tombstone which the finders ignore, until Restore brings the planet back or Purge removes it.
+// FindByID, FindByName, FindAll and FindPage only read the given fields, like FieldName, or every field when none is given.
+// The names of the planets which were not deleted are unique on their planet.NameKey, which FindByName also compares,
+// the changes which would repeat one fail with ErrDuplicateName.
 type PlanetRepository interface {
 	Create(ctx context.Context, p planet.Planet) (planet.Planet, error)
-	FindByID(ctx context.Context, id string) (planet.Planet, error)
-	FindByName(ctx context.Context, name string) (planet.Planet, error)
-	FindAll(ctx context.Context) ([]planet.Planet, error)
+	FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error)
+	FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error)
+	FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error)
+	// FindPage finds up to limit planets which were not deleted, ordered by id and after the given id unless it is empty
+	FindPage(ctx context.Context, after string, limit int, fields ...string) ([]planet.Planet, error)
+	// FindEach calls fn with every planet which was not deleted, ordered by id, reading them from a cursor
+	// so they are never all in memory. It stops on the first error returned by fn.
+	FindEach(ctx context.Context, fn func(planet.Planet) error) error
+	// Search finds up to limit planets which were not deleted whose name, climate or terrain words match the query,
+	// ignoring the case and the diacritics, the best first. When no word matches it falls back to the close words, for the typos.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
 	Update(ctx context.Context, p planet.Planet) (planet.Planet, error)
-	Delete(ctx context.Context, id string) error
+	Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error)
+	Delete(ctx context.Context, id string, version int64) error
+	Restore(ctx context.Context, id string) (planet.Planet, error)
+	FindDeleted(ctx context.Context) ([]planet.Planet, error)
+	// Purge removes the planets deleted before the given time and returns how many were removed
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// Bulk runs the operations together, when ordered it stops on the first failure.
+	// The error is only returned when the whole bulk failed, each operation failure is on its result.
+	Bulk(ctx context.Context, ops []BulkOperation, ordered bool) ([]BulkResult, error)
 }
 
 // PlanetFinder is the interface used to access the Finder methods on database
 type PlanetFinder interface {
-	FindByID(ctx context.Context, id string) (planet.Planet, error)
-	FindByName(ctx context.Context, name string) (planet.Planet, error)
-	FindAll(ctx context.Context) ([]planet.Planet, error)
+	FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error)
+	FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error)
+	FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error)
 }
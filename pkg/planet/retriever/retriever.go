@@ -19,7 +19,7 @@ func RetrivePlanet(ctx context.Context, id string, counter PlanetAppearancesOnMo
 	if err != nil {
 		return planet.Planet{}, err
 	}
-	return fillNumberOfAppearancesOnMovies(ctx, p, counter)
+	return FillNumberOfAppearancesOnMovies(ctx, p, counter)
 }
 
 // RetrivePlanetByName finds a planet on database using name, then fills the planet with the appearances on movies
@@ -28,7 +28,7 @@ func RetrivePlanetByName(ctx context.Context, name string, counter PlanetAppeara
 	if err != nil {
 		return planet.Planet{}, err
 	}
-	return fillNumberOfAppearancesOnMovies(ctx, p, counter)
+	return FillNumberOfAppearancesOnMovies(ctx, p, counter)
 }
 
 // RetriveAllPlanets finds all planets on database then fills the planets with the appearances on movies
@@ -37,34 +37,75 @@ func RetriveAllPlanets(ctx context.Context, counter PlanetAppearancesOnMoviesCou
 	if err != nil {
 		return nil, err
 	}
+	return FillAllNumberOfAppearancesOnMovies(ctx, planets, counter)
+}
+
+// PlanetFilmsLister defines the interface to list the StarWars movies the planet appears on
+type PlanetFilmsLister interface {
+	ListPlanetFilms(context.Context, string) ([]planet.Film, error)
+}
+
+// FillAllNumberOfAppearancesOnMovies fills the planets with the appearances on movies, up to 10 at a time.
+// A planet whose count fails is kept without it, and a nil counter skips the lookup.
+func FillAllNumberOfAppearancesOnMovies(ctx context.Context, planets []planet.Planet, counter PlanetAppearancesOnMoviesCounter) ([]planet.Planet, error) {
+	if counter == nil {
+		return planets, nil
+	}
+	return fillAll(ctx, planets, func(p planet.Planet) (planet.Planet, error) {
+		return FillNumberOfAppearancesOnMovies(ctx, p, counter)
+	})
+}
+
+// FillAllFilms fills the planets with the movies they appear on, and their number, up to 10 at a time.
+// A planet whose films fail is kept without them.
+func FillAllFilms(ctx context.Context, planets []planet.Planet, lister PlanetFilmsLister) ([]planet.Planet, error) {
+	return fillAll(ctx, planets, func(p planet.Planet) (planet.Planet, error) {
+		return FillFilms(ctx, p, lister)
+	})
+}
 
+// fillAll replaces each planet by the filled one, up to 10 at a time, the planets whose fill fails are kept
+func fillAll(ctx context.Context, planets []planet.Planet, fill func(planet.Planet) (planet.Planet, error)) ([]planet.Planet, error) {
 	var wg sync.WaitGroup
 	planetInputChannel := make(chan *planet.Planet)
-
 	numberOfConnection := len(planets)
 	if len(planets) > 10 {
 		numberOfConnection = 10
 	}
-
 	for i := 0; i < numberOfConnection; i++ {
 		go func() {
 			for p := range planetInputChannel {
-				*p, _ = fillNumberOfAppearancesOnMovies(ctx, *p, counter)
+				if filled, err := fill(*p); err == nil {
+					*p = filled
+				}
 				wg.Done()
 			}
-
 		}()
 	}
 	for i := 0; i < len(planets); i++ {
 		wg.Add(1)
-		planetInputChannel <- &planets[i]
+		select {
+		case planetInputChannel <- &planets[i]:
+		case <-ctx.Done():
+			wg.Done()
+			wg.Wait()
+			close(planetInputChannel)
+			return nil, ctx.Err()
+		}
 	}
 	wg.Wait()
 	close(planetInputChannel)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return planets, nil
 }
 
-func fillNumberOfAppearancesOnMovies(ctx context.Context, p planet.Planet, counter PlanetAppearancesOnMoviesCounter) (planet.Planet, error) {
+// FillNumberOfAppearancesOnMovies fills the planet with the appearances on movies, a nil counter skips the lookup
+func FillNumberOfAppearancesOnMovies(ctx context.Context, p planet.Planet, counter PlanetAppearancesOnMoviesCounter) (planet.Planet, error) {
+	if counter == nil {
+		return p, nil
+	}
 	n, err := counter.CountPlanetAppearancesOnMovies(ctx, p.Name)
 	if err != nil {
 		return planet.Planet{}, err
@@ -72,3 +113,14 @@ func fillNumberOfAppearancesOnMovies(ctx context.Context, p planet.Planet, count
 	p.NumberOfAppearancesOnMovies = n
 	return p, nil
 }
+
+// FillFilms fills the planet with the movies it appears on, the number of appearances is their count
+func FillFilms(ctx context.Context, p planet.Planet, lister PlanetFilmsLister) (planet.Planet, error) {
+	films, err := lister.ListPlanetFilms(ctx, p.Name)
+	if err != nil {
+		return planet.Planet{}, err
+	}
+	p.Films = films
+	p.NumberOfAppearancesOnMovies = len(films)
+	return p, nil
+}
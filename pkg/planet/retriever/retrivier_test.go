@@ -2,6 +2,7 @@ package retriever
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/rafaelreinert/stars/pkg/planet"
@@ -45,6 +46,96 @@ func TestRetriveAllPlanetEmpty(t *testing.T) {
 	assert.Empty(t, p)
 }
 
+func TestRetriveAllPlanetWithCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := RetriveAllPlanets(ctx, counterMock{}, finderMock{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRetriveAllPlanetKeepsThePlanetWhenTheCountFails(t *testing.T) {
+	p, err := RetriveAllPlanets(context.Background(), failingCounterMock{}, finderMock{})
+	assert.NoError(t, err)
+	assert.Contains(t, p, planet.Planet{
+		Name:    "Tatooine",
+		Climate: "arid",
+		Terrain: "desert",
+	})
+}
+
+func TestRetriveAllPlanetStopsSendingWhenTheContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	counter := blockingCounterMock{cancel: cancel}
+	planets := make([]planet.Planet, 100)
+	for i := range planets {
+		planets[i] = planet.Planet{Name: "Tatooine"}
+	}
+
+	_, err := RetriveAllPlanets(ctx, counter, finderMock{Planets: planets})
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFillAllNumberOfAppearancesOnMoviesKeepsTheOrder(t *testing.T) {
+	planets := []planet.Planet{{Name: "Alderaan"}, {Name: "Tatooine"}, {Name: "Hoth"}}
+
+	p, err := FillAllNumberOfAppearancesOnMovies(context.Background(), planets, counterMock{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{
+		{Name: "Alderaan", NumberOfAppearancesOnMovies: 1},
+		{Name: "Tatooine", NumberOfAppearancesOnMovies: 6},
+		{Name: "Hoth", NumberOfAppearancesOnMovies: 1},
+	}, p)
+}
+
+type failingCounterMock struct{}
+
+func (c failingCounterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	return 0, errors.New("SWAPI is unavailable")
+}
+
+func TestFillAllNumberOfAppearancesOnMoviesWithoutCounter(t *testing.T) {
+	planets := []planet.Planet{{Name: "Tatooine"}}
+	p, err := FillAllNumberOfAppearancesOnMovies(context.Background(), planets, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{{Name: "Tatooine"}}, p, "A nil counter should skip the lookup")
+}
+
+func TestFillAllFilms(t *testing.T) {
+	planets := []planet.Planet{{Name: "Tatooine"}, {Name: "Hoth"}, {Name: "Kamino"}}
+	p, err := FillAllFilms(context.Background(), planets, filmsListerMock{})
+	assert.NoError(t, err)
+	assert.Equal(t, []planet.Planet{
+		{Name: "Tatooine", NumberOfAppearancesOnMovies: 2, Films: []planet.Film{{Title: "A New Hope", EpisodeID: 4}, {Title: "Return of the Jedi", EpisodeID: 6}}},
+		{Name: "Hoth", NumberOfAppearancesOnMovies: 1, Films: []planet.Film{{Title: "The Empire Strikes Back", EpisodeID: 5}}},
+		{Name: "Kamino"},
+	}, p, "A planet whose films fail should be kept without them")
+}
+
+type filmsListerMock struct{}
+
+func (l filmsListerMock) ListPlanetFilms(ctx context.Context, name string) ([]planet.Film, error) {
+	switch name {
+	case "Tatooine":
+		return []planet.Film{{Title: "A New Hope", EpisodeID: 4}, {Title: "Return of the Jedi", EpisodeID: 6}}, nil
+	case "Hoth":
+		return []planet.Film{{Title: "The Empire Strikes Back", EpisodeID: 5}}, nil
+	}
+	return nil, errors.New("SWAPI is unavailable")
+}
+
+// blockingCounterMock cancels the context on the first call and blocks until it is done
+type blockingCounterMock struct {
+	cancel context.CancelFunc
+}
+
+func (c blockingCounterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name string) (int, error) {
+	c.cancel()
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
 type counterMock struct {
 }
 
@@ -56,10 +147,11 @@ func (c counterMock) CountPlanetAppearancesOnMovies(ctx context.Context, name st
 }
 
 type finderMock struct {
-	Empty bool
+	Empty   bool
+	Planets []planet.Planet
 }
 
-func (r finderMock) FindByID(ctx context.Context, id string) (planet.Planet, error) {
+func (r finderMock) FindByID(ctx context.Context, id string, fields ...string) (planet.Planet, error) {
 
 	return planet.Planet{
 		Name:    "Tatooine",
@@ -68,7 +160,7 @@ func (r finderMock) FindByID(ctx context.Context, id string) (planet.Planet, err
 	}, nil
 }
 
-func (r finderMock) FindByName(ctx context.Context, name string) (planet.Planet, error) {
+func (r finderMock) FindByName(ctx context.Context, name string, fields ...string) (planet.Planet, error) {
 
 	return planet.Planet{
 		Name:    "Alderaan",
@@ -77,10 +169,13 @@ func (r finderMock) FindByName(ctx context.Context, name string) (planet.Planet,
 	}, nil
 }
 
-func (r finderMock) FindAll(ctx context.Context) ([]planet.Planet, error) {
+func (r finderMock) FindAll(ctx context.Context, fields ...string) ([]planet.Planet, error) {
 	if r.Empty {
 		return []planet.Planet{}, nil
 	}
+	if r.Planets != nil {
+		return r.Planets, nil
+	}
 
 	planetOneToCreate := planet.Planet{
 		Name:    "Tatooine",
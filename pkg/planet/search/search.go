@@ -0,0 +1,179 @@
+// Package search ranks the planets whose words match a query without a text index,
+// it is the search of the memory repository and the typo fallback of the mongo one
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The weights of the planet fields, a word of the name is worth more than a word of the climate or the terrain.
+// The mongo text index uses the same weights.
+const (
+	NameWeight    = 10
+	ClimateWeight = 1
+	TerrainWeight = 1
+)
+
+// Fold lowers the case and removes the diacritics, so "Tatooíne" and "tatooine" are the same word
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC, cases.Fold())
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
+
+// Words splits the folded text on everything which is not a letter or a digit, like "temperate, tropical"
+func Words(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Rank returns up to limit planets whose words are the query words, the best first.
+// When no word matches it falls back to Fuzzy.
+func Rank(planets []planet.Planet, query string, limit int) []repository.SearchResult {
+	results := rank(planets, query, exact)
+	if len(results) == 0 {
+		return Fuzzy(planets, query, limit)
+	}
+	return top(results, limit)
+}
+
+// Fuzzy returns up to limit planets whose words are close to the query words, the closest first.
+// A word is close when it starts with the query word, or when it is at most one edit away from a query word
+// of up to five letters, or two edits away from a longer one. The words shorter than three letters must be exact.
+func Fuzzy(planets []planet.Planet, query string, limit int) []repository.SearchResult {
+	return top(rank(planets, query, similarity), limit)
+}
+
+// rank scores every planet with the weighted sum of the best match of each query word on each field,
+// the planets which score nothing are left out
+func rank(planets []planet.Planet, query string, match func(term, word string) float64) []repository.SearchResult {
+	terms := unique(Words(query))
+	var results []repository.SearchResult
+	for _, p := range planets {
+		fields := []struct {
+			words  []string
+			weight float64
+		}{
+			{Words(p.Name), NameWeight},
+			{Words(p.Climate), ClimateWeight},
+			{Words(p.Terrain), TerrainWeight},
+		}
+		score := 0.0
+		for _, term := range terms {
+			for _, field := range fields {
+				best := 0.0
+				for _, word := range field.words {
+					if m := match(term, word); m > best {
+						best = m
+					}
+				}
+				score += field.weight * best
+			}
+		}
+		if score > 0 {
+			results = append(results, repository.SearchResult{Planet: p, Score: score})
+		}
+	}
+	return results
+}
+
+// top orders the results by score, keeping the given order on the ties, and cuts them to limit
+func top(results []repository.SearchResult, limit int) []repository.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func exact(term, word string) float64 {
+	if term == word {
+		return 1
+	}
+	return 0
+}
+
+// similarity is 1 for the same word, and less the more the words differ, down to 0 when they are not close
+func similarity(term, word string) float64 {
+	if term == word {
+		return 1
+	}
+	termLength, wordLength := utf8.RuneCountInString(term), utf8.RuneCountInString(word)
+	if termLength < 3 {
+		return 0
+	}
+	if strings.HasPrefix(word, term) {
+		return float64(termLength) / float64(wordLength)
+	}
+	allowed := 1
+	if termLength > 5 {
+		allowed = 2
+	}
+	distance := Levenshtein(term, word)
+	if distance > allowed {
+		return 0
+	}
+	longest := termLength
+	if wordLength > longest {
+		longest = wordLength
+	}
+	return 1 - float64(distance)/float64(longest)
+}
+
+// Levenshtein is the number of rune insertions, deletions and substitutions which change a into b
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minimum(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
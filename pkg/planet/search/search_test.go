@@ -0,0 +1,89 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/stretchr/testify/assert"
+)
+
+var planets = []planet.Planet{
+	{ID: "1", Name: "Tatooine", Climate: "arid", Terrain: "desert"},
+	{ID: "2", Name: "Hoth", Climate: "frozen", Terrain: "tundra, ice caves, mountain ranges"},
+	{ID: "3", Name: "Dagobah", Climate: "murky", Terrain: "swamp, jungles"},
+	{ID: "4", Name: "Bespin", Climate: "temperate", Terrain: "gas giant"},
+	{ID: "5", Name: "Endor", Climate: "temperate", Terrain: "forests, mountains, lakes"},
+	{ID: "6", Name: "Mustafar", Climate: "hot", Terrain: "volcanoes, lava rivers, mountains, caves"},
+	{ID: "7", Name: "Écumenopolis", Climate: "Temperate", Terrain: "cityscape"},
+}
+
+func names(t *testing.T, query string, limit int) []string {
+	t.Helper()
+	var found []string
+	for _, r := range Rank(planets, query, limit) {
+		assert.True(t, r.Score > 0, "The score should be positive")
+		found = append(found, r.Planet.Name)
+	}
+	return found
+}
+
+func TestFoldAndWords(t *testing.T) {
+	assert.Equal(t, "ecumenopolis", Fold("Écumenopolis"))
+	assert.Equal(t, "strasse", Fold("STRASSE"))
+	assert.Equal(t, []string{"tundra", "ice", "caves", "mountain", "ranges"}, Words("tundra, ice caves, mountain ranges"))
+	assert.Empty(t, Words(" - , "))
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"hoth", "hoth", 0},
+		{"hoth", "", 4},
+		{"tatoine", "tatooine", 1},
+		{"tatooine", "tatooien", 2},
+		{"kitten", "sitting", 3},
+		{"endór", "endor", 1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.distance, Levenshtein(tt.a, tt.b), tt.a+" -> "+tt.b)
+	}
+}
+
+func TestRankMatchesTheWordsIgnoringTheCaseAndTheDiacritics(t *testing.T) {
+	assert.Equal(t, []string{"Tatooine"}, names(t, "tatooine", 10))
+	assert.Equal(t, []string{"Tatooine"}, names(t, "TATOOÍNE", 10))
+	assert.Equal(t, []string{"Écumenopolis"}, names(t, "ecumenopolis", 10))
+	assert.Equal(t, []string{"Hoth", "Mustafar"}, names(t, "caves", 10))
+}
+
+func TestRankWeighsTheNameMore(t *testing.T) {
+	heavy := []planet.Planet{
+		{ID: "1", Name: "Kashyyyk", Climate: "tropical", Terrain: "jungle, forests"},
+		{ID: "2", Name: "Jungle", Climate: "humid", Terrain: "rainforests"},
+	}
+	results := Rank(heavy, "jungle", 10)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "Jungle", results[0].Planet.Name)
+	assert.Equal(t, float64(NameWeight), results[0].Score)
+	assert.Equal(t, float64(TerrainWeight), results[1].Score)
+}
+
+func TestRankScoresEveryWordOfTheQuery(t *testing.T) {
+	assert.Equal(t, []string{"Endor", "Bespin", "Écumenopolis"}, names(t, "temperate forests", 10))
+	assert.Equal(t, []string{"Endor", "Bespin"}, names(t, "temperate forests", 2))
+}
+
+func TestRankFallsBackToTheCloseWords(t *testing.T) {
+	assert.Equal(t, []string{"Tatooine"}, names(t, "tatoine", 10), "A letter missing")
+	assert.Equal(t, []string{"Dagobah"}, names(t, "dagoba", 10), "The start of the name")
+	assert.Equal(t, []string{"Mustafar"}, names(t, "mustafra", 10), "Two letters swapped")
+	assert.Equal(t, []string{"Endor", "Mustafar"}, names(t, "mountians", 10))
+	assert.Empty(t, names(t, "ho", 10), "The short words should be exact")
+	assert.Empty(t, names(t, "coruscant", 10))
+
+	typo, start := Fuzzy(planets, "tatoine", 10), Fuzzy(planets, "tatoo", 10)
+	assert.True(t, typo[0].Score > start[0].Score, "A closer word should score more")
+}
@@ -0,0 +1,159 @@
+// Package swapisync creates and updates the local planets out of the SWAPI catalogue
+package swapisync
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+)
+
+// The sync modes
+const (
+	// ModeMerge creates the missing planets and fills the empty fields, the local values are kept
+	ModeMerge = "merge"
+	// ModeOverwrite creates the missing planets and replaces the fields which differ from SWAPI
+	ModeOverwrite = "overwrite"
+	// ModeReport lists what ModeOverwrite would change without writing
+	ModeReport = "report"
+)
+
+// The actions taken on a planet
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	// ActionKeep is a planet whose differences were all kept by ModeMerge
+	ActionKeep = "keep"
+)
+
+// Source is the interface used to read the SWAPI planets, like swapi.SWAPI
+type Source interface {
+	ListPlanets(ctx context.Context, fn func(planet.Planet) error) error
+}
+
+// FieldDiff is the struct which describes a field whose local value differs from SWAPI,
+// Kept is set when ModeMerge kept the local value
+type FieldDiff struct {
+	Field string `json:"field"`
+	Local string `json:"local"`
+	SWAPI string `json:"swapi"`
+	Kept  bool   `json:"kept,omitempty"`
+}
+
+// Change is the struct which describes a planet created, updated or kept by the sync,
+// Error tells why the change failed
+type Change struct {
+	Name   string      `json:"name"`
+	ID     string      `json:"id,omitempty"`
+	Action string      `json:"action"`
+	Fields []FieldDiff `json:"fields,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Report is the struct which carries the diff summary of a sync, the unchanged planets are only counted
+type Report struct {
+	Mode      string   `json:"mode"`
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Kept      int      `json:"kept"`
+	Unchanged int      `json:"unchanged"`
+	Failed    int      `json:"failed"`
+	Changes   []Change `json:"changes"`
+}
+
+// ValidMode reports whether the mode is one of the sync modes
+func ValidMode(mode string) bool {
+	return mode == ModeMerge || mode == ModeOverwrite || mode == ModeReport
+}
+
+// Sync reads every SWAPI planet and creates or updates the local planet with the same name, copying the name, the climate
+// and the terrain. The updates are conditional on the version read, so a planet changed meanwhile fails instead of being lost.
+// The error is returned when SWAPI can not be read, the report still has the changes made before it.
+func Sync(ctx context.Context, rep repository.PlanetRepository, source Source, mode string) (Report, error) {
+	report := Report{Mode: mode, Changes: []Change{}}
+	if !ValidMode(mode) {
+		return report, errors.Errorf("unknown sync mode %q, use merge, overwrite or report", mode)
+	}
+	err := source.ListPlanets(ctx, func(remote planet.Planet) error {
+		change, err := syncPlanet(ctx, rep, remote, mode)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			change.Error = err.Error()
+			report.Failed++
+			report.Changes = append(report.Changes, change)
+			return nil
+		}
+		switch change.Action {
+		case ActionCreate:
+			report.Created++
+		case ActionUpdate:
+			report.Updated++
+		case ActionKeep:
+			report.Kept++
+		default:
+			report.Unchanged++
+			return nil
+		}
+		report.Changes = append(report.Changes, change)
+		return nil
+	})
+	return report, err
+}
+
+// syncPlanet applies the SWAPI planet to the local one, the action is empty when nothing differs
+func syncPlanet(ctx context.Context, rep repository.PlanetRepository, remote planet.Planet, mode string) (Change, error) {
+	change := Change{Name: remote.Name}
+	local, err := rep.FindByName(ctx, remote.Name)
+	if err == repository.ErrNotFound {
+		change.Action = ActionCreate
+		if mode == ModeReport {
+			return change, nil
+		}
+		created, err := rep.Create(ctx, planet.Planet{Name: remote.Name, Climate: remote.Climate, Terrain: remote.Terrain})
+		change.ID = created.ID
+		return change, err
+	}
+	if err != nil {
+		return change, err
+	}
+	change.ID = local.ID
+
+	u := planet.Update{Version: local.Version}
+	for _, field := range []struct {
+		name          string
+		local, remote string
+		set           **string
+	}{
+		{"name", local.Name, remote.Name, &u.Name},
+		{"climate", local.Climate, remote.Climate, &u.Climate},
+		{"terrain", local.Terrain, remote.Terrain, &u.Terrain},
+	} {
+		if field.local == field.remote {
+			continue
+		}
+		diff := FieldDiff{Field: field.name, Local: field.local, SWAPI: field.remote}
+		if mode == ModeMerge && field.local != "" {
+			diff.Kept = true
+		} else {
+			value := field.remote
+			*field.set = &value
+		}
+		change.Fields = append(change.Fields, diff)
+	}
+	switch {
+	case len(change.Fields) == 0:
+		return change, nil
+	case u.IsEmpty():
+		change.Action = ActionKeep
+		return change, nil
+	}
+	change.Action = ActionUpdate
+	if mode == ModeReport {
+		return change, nil
+	}
+	_, err = rep.Patch(ctx, local.ID, u)
+	return change, err
+}
@@ -0,0 +1,150 @@
+package swapisync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/repository"
+	"github.com/rafaelreinert/stars/pkg/planet/repository/memrep"
+	"github.com/rafaelreinert/stars/pkg/swapi"
+	"github.com/rafaelreinert/stars/pkg/swapi/swapitest"
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceSource lists the given planets, then fails with err when it is set
+type sliceSource struct {
+	planets []planet.Planet
+	err     error
+}
+
+func (s sliceSource) ListPlanets(ctx context.Context, fn func(planet.Planet) error) error {
+	for _, p := range s.planets {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+var catalogue = sliceSource{planets: []planet.Planet{
+	{Name: "Tatooine", Climate: "arid", Terrain: "desert", NumberOfAppearancesOnMovies: 5},
+	{Name: "Hoth", Climate: "frozen", Terrain: "tundra, ice caves"},
+	{Name: "Dagobah", Climate: "murky", Terrain: "swamp, jungles"},
+}}
+
+// newLocal has Tatooine with an empty terrain and a local climate, Dagobah as in SWAPI and no Hoth
+func newLocal() (repository.PlanetRepository, planet.Planet) {
+	rep := memrep.NewMemoryRepository()
+	tatooine, _ := rep.Create(context.Background(), planet.Planet{Name: "Tatooine", Climate: "hot"})
+	rep.Create(context.Background(), planet.Planet{Name: "Dagobah", Climate: "murky", Terrain: "swamp, jungles"})
+	return rep, tatooine
+}
+
+func TestSyncMerge(t *testing.T) {
+	rep, tatooine := newLocal()
+
+	report, err := Sync(context.Background(), rep, catalogue, ModeMerge)
+
+	assert.NoError(t, err)
+	hoth, _ := rep.FindByName(context.Background(), "Hoth")
+	assert.Equal(t, Report{Mode: ModeMerge, Created: 1, Updated: 1, Unchanged: 1, Changes: []Change{
+		{Name: "Tatooine", ID: tatooine.ID, Action: ActionUpdate, Fields: []FieldDiff{
+			{Field: "climate", Local: "hot", SWAPI: "arid", Kept: true},
+			{Field: "terrain", Local: "", SWAPI: "desert"},
+		}},
+		{Name: "Hoth", ID: hoth.ID, Action: ActionCreate},
+	}}, report)
+	updated, _ := rep.FindByID(context.Background(), tatooine.ID)
+	assert.Equal(t, "hot", updated.Climate)
+	assert.Equal(t, "desert", updated.Terrain)
+	assert.Equal(t, "tundra, ice caves", hoth.Terrain)
+
+	report, _ = Sync(context.Background(), rep, catalogue, ModeMerge)
+	assert.Equal(t, 1, report.Kept, "The kept local values should be reported again")
+	assert.Equal(t, ActionKeep, report.Changes[0].Action)
+}
+
+func TestSyncOverwrite(t *testing.T) {
+	rep, tatooine := newLocal()
+
+	report, err := Sync(context.Background(), rep, catalogue, ModeOverwrite)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, []FieldDiff{{Field: "climate", Local: "hot", SWAPI: "arid"}, {Field: "terrain", Local: "", SWAPI: "desert"}}, report.Changes[0].Fields)
+	updated, _ := rep.FindByID(context.Background(), tatooine.ID)
+	assert.Equal(t, planet.Planet{ID: tatooine.ID, Name: "Tatooine", Climate: "arid", Terrain: "desert", Version: 2}, updated)
+}
+
+func TestSyncReportDoesNotWrite(t *testing.T) {
+	rep, tatooine := newLocal()
+
+	report, err := Sync(context.Background(), rep, catalogue, ModeReport)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, []Change{
+		{Name: "Tatooine", ID: tatooine.ID, Action: ActionUpdate, Fields: []FieldDiff{{Field: "climate", Local: "hot", SWAPI: "arid"}, {Field: "terrain", Local: "", SWAPI: "desert"}}},
+		{Name: "Hoth", Action: ActionCreate},
+	}, report.Changes)
+	all, _ := rep.FindAll(context.Background())
+	assert.Len(t, all, 2)
+	unchanged, _ := rep.FindByID(context.Background(), tatooine.ID)
+	assert.Equal(t, tatooine, unchanged)
+}
+
+// conflictingRepository changes the planet between the lookup and the update
+type conflictingRepository struct {
+	repository.PlanetRepository
+}
+
+func (r conflictingRepository) Patch(ctx context.Context, id string, u planet.Update) (planet.Planet, error) {
+	climate := "changed"
+	r.PlanetRepository.Patch(ctx, id, planet.Update{Climate: &climate})
+	return r.PlanetRepository.Patch(ctx, id, u)
+}
+
+func TestSyncDoesNotOverwriteAConcurrentChange(t *testing.T) {
+	rep, tatooine := newLocal()
+
+	report, err := Sync(context.Background(), conflictingRepository{rep}, catalogue, ModeOverwrite)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, repository.ErrVersionMismatch.Error(), report.Changes[0].Error)
+	updated, _ := rep.FindByID(context.Background(), tatooine.ID)
+	assert.Equal(t, "changed", updated.Climate)
+}
+
+func TestSyncStopsWhenSWAPIFails(t *testing.T) {
+	rep := memrep.NewMemoryRepository()
+	source := sliceSource{planets: catalogue.planets[:1], err: errors.New("SWAPI answered 502")}
+
+	report, err := Sync(context.Background(), rep, source, ModeMerge)
+
+	assert.EqualError(t, err, "SWAPI answered 502")
+	assert.Equal(t, 1, report.Created)
+
+	_, err = Sync(context.Background(), rep, source, "replace")
+	assert.Error(t, err)
+}
+
+func TestSyncWithTheFakeSWAPI(t *testing.T) {
+	var planets []planet.Planet
+	for i := 0; i < swapitest.PageSize+2; i++ {
+		planets = append(planets, planet.Planet{Name: fmt.Sprintf("Planet %d", i), Climate: "arid"})
+	}
+	ts := swapitest.NewServer(planets...)
+	defer ts.Close()
+	rep := memrep.NewMemoryRepository()
+
+	report, err := Sync(context.Background(), rep, swapi.SWAPI{APIURL: ts.URL}, ModeMerge)
+
+	assert.NoError(t, err)
+	assert.Equal(t, swapitest.PageSize+2, report.Created)
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowConsumesTheBurst(t *testing.T) {
+	l := New(1, 2)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	first := l.Allow("a")
+	second := l.Allow("a")
+	third := l.Allow("a")
+
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Second, third.RetryAfter)
+	assert.Equal(t, 2*time.Second, third.Reset)
+	assert.Equal(t, 2, third.Limit)
+}
+
+func TestAllowKeepsOneBucketPerKey(t *testing.T) {
+	l := New(1, 1)
+
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+	assert.True(t, l.Allow("b").Allowed)
+}
+
+func TestAllowRefillsTheBucket(t *testing.T) {
+	l := New(2, 1)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("a").Allowed)
+}
+
+func TestCheckDoesNotTakeAToken(t *testing.T) {
+	l := New(1, 1)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Check("a").Allowed)
+	assert.True(t, l.Check("a").Allowed)
+	assert.True(t, l.Allow("a").Allowed)
+	result := l.Check("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l := New(1, 1)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.Allow("a")
+
+	now = now.Add(2 * sweepInterval)
+	l.Allow("b")
+
+	assert.Len(t, l.buckets, 1)
+}
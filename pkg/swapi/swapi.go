@@ -6,16 +6,31 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rafaelreinert/stars/pkg/planet"
 )
 
+// maxPages bounds the pages followed by ListPlanets, so a next link pointing back can not loop forever
+const maxPages = 1000
+
 type searchResponse struct {
+	Next    *string          `json:"next"`
 	Results []planetResponse `json:"results"`
 }
 
 type planetResponse struct {
-	Name  string   `json:"name"`
-	Films []string `json:"films"`
+	Name    string   `json:"name"`
+	Climate string   `json:"climate"`
+	Terrain string   `json:"terrain"`
+	Films   []string `json:"films"`
+}
+
+type filmResponse struct {
+	Title       string `json:"title"`
+	EpisodeID   int    `json:"episode_id"`
+	Director    string `json:"director"`
+	ReleaseDate string `json:"release_date"`
 }
 
 // SWAPI is the struct used to access the StarWars API
@@ -25,31 +40,120 @@ type SWAPI struct {
 
 // CountPlanetAppearancesOnMovies retrivies the planet on swapi and return the number of movies with the planet appearance
 func (s SWAPI) CountPlanetAppearancesOnMovies(ctx context.Context, planetName string) (int, error) {
+	p, err := s.searchPlanet(ctx, planetName)
+	if err != nil || p == nil {
+		return 0, err
+	}
+	return len(p.Films), nil
+}
+
+// ListPlanetFilms retrivies the planet on swapi and then each movie with the planet appearance, in the SWAPI order
+func (s SWAPI) ListPlanetFilms(ctx context.Context, planetName string) ([]planet.Film, error) {
+	p, err := s.searchPlanet(ctx, planetName)
+	if err != nil || p == nil {
+		return []planet.Film{}, err
+	}
+	films := make([]planet.Film, 0, len(p.Films))
+	for _, link := range p.Films {
+		filmURL, err := resolve(s.APIURL, link)
+		if err != nil {
+			return nil, err
+		}
+		var film filmResponse
+		if err := s.get(ctx, filmURL, &film); err != nil {
+			return nil, errors.Wrapf(err, "reading the SWAPI film %s", filmURL)
+		}
+		films = append(films, planet.Film{Title: film.Title, EpisodeID: film.EpisodeID, Director: film.Director, ReleaseDate: film.ReleaseDate})
+	}
+	return films, nil
+}
+
+// searchPlanet finds the planet with the name on swapi, ignoring the case, it is nil when there is none
+func (s SWAPI) searchPlanet(ctx context.Context, planetName string) (*planetResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/planets/?search=%s", s.APIURL, url.QueryEscape(planetName)), nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var searchResponse searchResponse
 	err = json.NewDecoder(resp.Body).Decode(&searchResponse)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if len(searchResponse.Results) == 0 {
-		return 0, nil
+	for _, p := range searchResponse.Results {
+		if planet.NameKey(p.Name) == planet.NameKey(planetName) {
+			return &p, nil
+		}
 	}
 
-	for _, p := range searchResponse.Results {
-		if strings.EqualFold(p.Name, planetName) {
-			return len(p.Films), nil
+	return nil, nil
+}
+
+// ListPlanets pages through the SWAPI planets following the next links, fn is called with each planet
+// and its number of appearances on movies, the listing stops on the first error returned by fn
+func (s SWAPI) ListPlanets(ctx context.Context, fn func(planet.Planet) error) error {
+	next := s.APIURL + "/planets/"
+	for page := 1; next != ""; page++ {
+		if page > maxPages {
+			return errors.Errorf("SWAPI has more than %d pages of planets", maxPages)
+		}
+		response, err := s.planetsPage(ctx, next)
+		if err != nil {
+			return errors.Wrapf(err, "reading the SWAPI planets page %d", page)
+		}
+		for _, p := range response.Results {
+			if err := fn(planet.Planet{Name: p.Name, Climate: p.Climate, Terrain: p.Terrain, NumberOfAppearancesOnMovies: len(p.Films)}); err != nil {
+				return err
+			}
 		}
+		next = ""
+		if response.Next != nil && *response.Next != "" {
+			if next, err = resolve(s.APIURL, *response.Next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s SWAPI) planetsPage(ctx context.Context, pageURL string) (searchResponse, error) {
+	var response searchResponse
+	err := s.get(ctx, pageURL, &response)
+	return response, err
+}
+
+// get decodes the SWAPI resource, the answers other than 200 are errors
+func (s SWAPI) get(ctx context.Context, resourceURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return err
 	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("SWAPI answered %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
 
-	return 0, nil
+// resolve makes the next link absolute, relative links are resolved against the API URL
+func resolve(apiURL, link string) (string, error) {
+	base, err := url.Parse(apiURL + "/planets/")
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return "", errors.Wrapf(err, "the SWAPI next link %q is invalid", link)
+	}
+	return base.ResolveReference(ref).String(), nil
 }
@@ -2,11 +2,14 @@ package swapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/swapi/swapitest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -151,3 +154,92 @@ func initTestServer() *httptest.Server {
 	}))
 
 }
+
+func TestListPlanetsFollowsTheNextPages(t *testing.T) {
+	var planets []planet.Planet
+	for i := 1; i <= 2*swapitest.PageSize+3; i++ {
+		planets = append(planets, planet.Planet{Name: fmt.Sprintf("Planet %d", i), Climate: "arid", Terrain: "desert", NumberOfAppearancesOnMovies: i % 3})
+	}
+	ts := swapitest.NewServer(planets...)
+	defer ts.Close()
+
+	var listed []planet.Planet
+	err := SWAPI{APIURL: ts.URL}.ListPlanets(context.Background(), func(p planet.Planet) error {
+		listed = append(listed, p)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, planets, listed)
+}
+
+func TestListPlanetsStopsOnTheFirstError(t *testing.T) {
+	ts := swapitest.NewServer(planet.Planet{Name: "Tatooine"}, planet.Planet{Name: "Hoth"})
+	defer ts.Close()
+	stop := errors.New("stop")
+	calls := 0
+
+	err := SWAPI{APIURL: ts.URL}.ListPlanets(context.Background(), func(p planet.Planet) error {
+		calls++
+		return stop
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestListPlanetsFailsOnAnErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"next": "/api/planets/?page=2", "results": [{"name": "Tatooine"}]}`)
+	}))
+	defer ts.Close()
+
+	err := SWAPI{APIURL: ts.URL + "/api"}.ListPlanets(context.Background(), func(p planet.Planet) error { return nil })
+
+	assert.EqualError(t, err, "reading the SWAPI planets page 2: SWAPI answered 502")
+}
+
+func TestListPlanetFilms(t *testing.T) {
+	ts := swapitest.NewServer(planet.Planet{Name: "Hoth", NumberOfAppearancesOnMovies: 2}, planet.Planet{Name: "Kamino"})
+	defer ts.Close()
+	s := SWAPI{APIURL: ts.URL}
+
+	films, err := s.ListPlanetFilms(context.Background(), "hoth")
+	assert.NoError(t, err)
+	assert.Equal(t, swapitest.Films[:2], films)
+
+	films, err = s.ListPlanetFilms(context.Background(), "Kamino")
+	assert.NoError(t, err)
+	assert.Empty(t, films)
+
+	films, err = s.ListPlanetFilms(context.Background(), "Alderaan")
+	assert.NoError(t, err)
+	assert.Empty(t, films, "A planet which is not on SWAPI should have no films")
+}
+
+func TestListPlanetFilmsFailsWhenAFilmCanNotBeRead(t *testing.T) {
+	ts := swapitest.NewServer(planet.Planet{Name: "Hoth", NumberOfAppearancesOnMovies: len(swapitest.Films) + 1})
+	defer ts.Close()
+
+	_, err := SWAPI{APIURL: ts.URL}.ListPlanetFilms(context.Background(), "Hoth")
+
+	assert.EqualError(t, err, fmt.Sprintf("reading the SWAPI film %s/films/7/: SWAPI answered 404", ts.URL))
+}
+
+func TestThePlanetNamesAreMatchedOnTheirNameKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count": 1, "next": null, "results": [{"name": "Alderaan", "films": ["https://swapi.dev/api/films/1/", "https://swapi.dev/api/films/6/"]}]}`)
+	}))
+	defer ts.Close()
+
+	for _, name := range []string{"Alderaan", "alderaan", " ALDERAAN "} {
+		count, err := SWAPI{APIURL: ts.URL}.CountPlanetAppearancesOnMovies(context.Background(), name)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count, name)
+	}
+}
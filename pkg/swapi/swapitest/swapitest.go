@@ -0,0 +1,120 @@
+// Package swapitest serves a fake SWAPI with a fixed list of planets, for the tests and the local environments
+package swapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+)
+
+// PageSize is the number of planets on each page, like SWAPI
+const PageSize = 10
+
+type planetResponse struct {
+	Name    string   `json:"name"`
+	Climate string   `json:"climate"`
+	Terrain string   `json:"terrain"`
+	Films   []string `json:"films"`
+}
+
+type pageResponse struct {
+	Count    int              `json:"count"`
+	Next     *string          `json:"next"`
+	Previous *string          `json:"previous"`
+	Results  []planetResponse `json:"results"`
+}
+
+type filmResponse struct {
+	Title       string `json:"title"`
+	EpisodeID   int    `json:"episode_id"`
+	Director    string `json:"director"`
+	ReleaseDate string `json:"release_date"`
+}
+
+// Films are the films served on /films/1/ to /films/6/, the planets appear on the first ones
+var Films = []planet.Film{
+	{Title: "A New Hope", EpisodeID: 4, Director: "George Lucas", ReleaseDate: "1977-05-25"},
+	{Title: "The Empire Strikes Back", EpisodeID: 5, Director: "Irvin Kershner", ReleaseDate: "1980-05-17"},
+	{Title: "Return of the Jedi", EpisodeID: 6, Director: "Richard Marquand", ReleaseDate: "1983-05-25"},
+	{Title: "The Phantom Menace", EpisodeID: 1, Director: "George Lucas", ReleaseDate: "1999-05-19"},
+	{Title: "Attack of the Clones", EpisodeID: 2, Director: "George Lucas", ReleaseDate: "2002-05-16"},
+	{Title: "Revenge of the Sith", EpisodeID: 3, Director: "George Lucas", ReleaseDate: "2005-05-19"},
+}
+
+// Handler serves GET /planets/ with the SWAPI pagination and search and GET /films/{n}/, each planet appears on
+// the first NumberOfAppearancesOnMovies films
+func Handler(planets ...planet.Planet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/films/") {
+			serveFilm(w, r)
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/planets/" {
+			http.NotFound(w, r)
+			return
+		}
+		matches := planets
+		search := r.URL.Query().Get("search")
+		if search != "" {
+			matches = nil
+			for _, p := range planets {
+				if strings.Contains(strings.ToLower(p.Name), strings.ToLower(search)) {
+					matches = append(matches, p)
+				}
+			}
+		}
+		page := 1
+		if value := r.URL.Query().Get("page"); value != "" {
+			var err error
+			if page, err = strconv.Atoi(value); err != nil || page < 1 {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		response := pageResponse{Count: len(matches), Results: []planetResponse{}}
+		start, end := (page-1)*PageSize, page*PageSize
+		if end > len(matches) {
+			end = len(matches)
+		}
+		for i := start; i < end; i++ {
+			p := matches[i]
+			films := make([]string, p.NumberOfAppearancesOnMovies)
+			for f := range films {
+				films[f] = fmt.Sprintf("http://%s/films/%d/", r.Host, f+1)
+			}
+			response.Results = append(response.Results, planetResponse{Name: p.Name, Climate: p.Climate, Terrain: p.Terrain, Films: films})
+		}
+		if end < len(matches) {
+			next := fmt.Sprintf("http://%s/planets/?page=%d", r.Host, page+1)
+			if search != "" {
+				next += "&search=" + url.QueryEscape(search)
+			}
+			response.Next = &next
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+func serveFilm(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/films/"), "/"))
+	if err != nil || n < 1 || n > len(Films) {
+		http.NotFound(w, r)
+		return
+	}
+	film := Films[n-1]
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filmResponse{Title: film.Title, EpisodeID: film.EpisodeID, Director: film.Director, ReleaseDate: film.ReleaseDate})
+}
+
+// NewServer starts a fake SWAPI, its URL is the SWAPI_URL of the API
+func NewServer(planets ...planet.Planet) *httptest.Server {
+	return httptest.NewServer(Handler(planets...))
+}
@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+)
+
+// The headers sent with every delivery besides the signature
+const (
+	EventHeader    = "X-Stars-Event"
+	DeliveryHeader = "X-Stars-Delivery"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// Options is the struct which carries the retry policy of the deliveries
+type Options struct {
+	// MaxAttempts is how many times a delivery is sent before it goes to the dead letter
+	MaxAttempts int
+	// Backoff is the wait after the first failure, it doubles on each failure up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout is the time budget of each request to a subscriber
+	Timeout time.Duration
+}
+
+// Dispatcher is the struct which turns the planet events into deliveries and sends them to the subscribers
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	options Options
+	wake    chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher which keeps the deliveries on the store
+func NewDispatcher(store Store, options Options) *Dispatcher {
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 1
+	}
+	return &Dispatcher{
+		store:   store,
+		client:  &http.Client{Timeout: options.Timeout},
+		options: options,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Listen enqueues the deliveries of the bus events until ctx is done, a subscription dropped for falling behind
+// is resumed from the last event seen
+func (d *Dispatcher) Listen(ctx context.Context, bus *event.Bus) {
+	lastID := ""
+	for {
+		sub := bus.Subscribe(lastID)
+		if sub.Reset {
+			log.Println("Webhook events were lost, the dispatcher fell behind the event buffer")
+		}
+		for _, e := range sub.Replay {
+			d.Enqueue(ctx, e)
+			lastID = e.ID
+		}
+		lastID = d.consume(ctx, sub, lastID)
+		sub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) consume(ctx context.Context, sub event.Subscription, lastID string) string {
+	for {
+		select {
+		case <-ctx.Done():
+			return lastID
+		case e, open := <-sub.Events:
+			if !open {
+				return lastID
+			}
+			d.Enqueue(ctx, e)
+			lastID = e.ID
+		}
+	}
+}
+
+// Enqueue creates a pending delivery of the event for each subscription which wants it,
+// the failures are logged since the bus events can not be taken again
+func (d *Dispatcher) Enqueue(ctx context.Context, e event.Event) {
+	if err := d.Deliver(ctx, e); err != nil {
+		log.Println("Error enqueuing the webhook deliveries of the event", e.ID, err)
+	}
+}
+
+// Deliver creates a pending delivery of the event for each subscription which wants it, so the dispatcher
+// is an outbox sink. It fails on the first delivery not created, the ones created before are kept.
+func (d *Dispatcher) Deliver(ctx context.Context, e event.Event) error {
+	subs, err := d.store.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	defer d.Wake()
+	for _, sub := range subs {
+		if !sub.Wants(e.Type) {
+			continue
+		}
+		_, err := d.store.CreateDelivery(ctx, Delivery{
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wake makes the worker look for due deliveries right away
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the due deliveries, right away and then every interval or when woken, until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.SendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// SendDue sends the deliveries due now, one at a time, until none is left
+func (d *Dispatcher) SendDue(ctx context.Context) {
+	// the claim outlives the request, so no other instance sends the same delivery meanwhile
+	lease := d.options.Timeout + time.Minute
+	for ctx.Err() == nil {
+		delivery, ok, err := d.store.ClaimDue(ctx, time.Now().UTC(), lease)
+		if err != nil {
+			log.Println("Error claiming the due webhook deliveries", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := d.store.UpdateDelivery(ctx, d.attempt(ctx, delivery)); err != nil {
+			log.Println("Error updating the webhook delivery", delivery.ID, err)
+		}
+	}
+}
+
+// attempt sends the delivery once and returns it with the outcome and the next attempt
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) Delivery {
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now
+	sub, err := d.store.FindSubscription(ctx, delivery.SubscriptionID)
+	if err == ErrNotFound || (err == nil && !sub.Active) {
+		delivery.Status = StatusDead
+		delivery.LastError = "the webhook was removed or disabled"
+		return delivery
+	}
+	if err != nil {
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.options.Backoff)
+		return delivery
+	}
+
+	delivery.Attempts++
+	code, err := d.send(ctx, sub, delivery)
+	delivery.LastStatusCode = code
+	if err == nil {
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+		return delivery
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.options.MaxAttempts {
+		delivery.Status = StatusDead
+		return delivery
+	}
+	delivery.Status = StatusFailed
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	return delivery
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "stars-webhook")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errUnexpectedStatus(resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait before the next attempt, doubled on each failure with up to 10% of jitter
+// so the retries of many deliveries do not hit the subscriber together
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.options.Backoff
+	for i := 1; i < attempts && (d.options.MaxBackoff <= 0 || wait < d.options.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if d.options.MaxBackoff > 0 && wait > d.options.MaxBackoff {
+		wait = d.options.MaxBackoff
+	}
+	if jitter := int64(wait / 10); jitter > 0 {
+		wait += time.Duration(rand.Int63n(jitter))
+	}
+	return wait
+}
+
+type errUnexpectedStatus int
+
+func (e errUnexpectedStatus) Error() string {
+	return "unexpected status " + strconv.Itoa(int(e))
+}
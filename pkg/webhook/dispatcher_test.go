@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet"
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver is a subscriber which verifies the signatures and answers with the given statuses in turn
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	received []*http.Request
+	verified []bool
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, r)
+	rc.verified = append(rc.verified, Verify(rc.secret, r.Header.Get(SignatureHeader), body, time.Now(), time.Minute))
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(store Store, maxAttempts int) *Dispatcher {
+	return NewDispatcher(store, Options{MaxAttempts: maxAttempts, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, Timeout: time.Second})
+}
+
+// sendUntilSettled runs the worker until no delivery is waiting for a retry
+func sendUntilSettled(t *testing.T, d *Dispatcher, store *MemoryStore, subID string) []Delivery {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		d.SendDue(context.Background())
+		deliveries, _ := store.ListDeliveries(context.Background(), subID, 0)
+		settled := true
+		for _, delivery := range deliveries {
+			if delivery.Status == StatusPending || delivery.Status == StatusFailed {
+				settled = false
+			}
+		}
+		if settled {
+			return deliveries
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatal("The deliveries did not settle")
+	return nil
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	rc := &receiver{secret: "whsec_test"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sub, _ := store.CreateSubscription(ctx, Subscription{URL: srv.URL, Secret: rc.secret, Active: true, Events: []string{event.TypeCreated}})
+	store.CreateSubscription(ctx, Subscription{URL: srv.URL, Secret: "other", Active: false})
+	d := newTestDispatcher(store, 3)
+
+	d.Enqueue(ctx, event.Event{ID: "e-1", Type: event.TypeCreated, PlanetID: "p1", Planet: &planet.Planet{ID: "p1", Name: "Hoth"}})
+	d.Enqueue(ctx, event.Event{ID: "e-2", Type: event.TypeDeleted, PlanetID: "p1"})
+	deliveries := sendUntilSettled(t, d, store, sub.ID)
+
+	if assert.Len(t, deliveries, 1, "Only the wanted events of the active subscriptions should be delivered") {
+		assert.Equal(t, StatusSucceeded, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].LastStatusCode)
+	}
+	if assert.Len(t, rc.received, 1) {
+		assert.True(t, rc.verified[0], "The payload should be signed with the subscription secret")
+		assert.Equal(t, event.TypeCreated, rc.received[0].Header.Get(EventHeader))
+		assert.Equal(t, deliveries[0].ID, rc.received[0].Header.Get(DeliveryHeader))
+		assert.Equal(t, "application/json", rc.received[0].Header.Get("Content-Type"))
+	}
+}
+
+func TestDispatcherRetriesUntilSuccess(t *testing.T) {
+	rc := &receiver{secret: "s", statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sub, _ := store.CreateSubscription(ctx, Subscription{URL: srv.URL, Secret: rc.secret, Active: true})
+	d := newTestDispatcher(store, 5)
+
+	d.Enqueue(ctx, event.Event{ID: "e-1", Type: event.TypeUpdated, PlanetID: "p1"})
+	deliveries := sendUntilSettled(t, d, store, sub.ID)
+
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, StatusSucceeded, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Empty(t, deliveries[0].LastError)
+	}
+	assert.Len(t, rc.received, 3)
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	rc := &receiver{secret: "s", statuses: []int{500, 500, 500, 500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sub, _ := store.CreateSubscription(ctx, Subscription{URL: srv.URL, Secret: rc.secret, Active: true})
+	d := newTestDispatcher(store, 3)
+
+	d.Enqueue(ctx, event.Event{ID: "e-1", Type: event.TypeUpdated, PlanetID: "p1"})
+	deliveries := sendUntilSettled(t, d, store, sub.ID)
+
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, StatusDead, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Equal(t, 500, deliveries[0].LastStatusCode)
+		assert.Equal(t, "unexpected status 500", deliveries[0].LastError)
+	}
+	assert.Len(t, rc.received, 3, "The delivery should stop after the max attempts")
+}
+
+func TestDispatcherRemovedSubscription(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sub, _ := store.CreateSubscription(ctx, Subscription{URL: "http://127.0.0.1:1", Active: true})
+	d := newTestDispatcher(store, 3)
+
+	d.Enqueue(ctx, event.Event{ID: "e-1", Type: event.TypeUpdated, PlanetID: "p1"})
+	store.DeleteSubscription(ctx, sub.ID)
+	deliveries := sendUntilSettled(t, d, store, sub.ID)
+
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, StatusDead, deliveries[0].Status)
+		assert.Equal(t, 0, deliveries[0].Attempts)
+	}
+}
+
+func TestDispatcherListen(t *testing.T) {
+	rc := &receiver{secret: "s"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemoryStore()
+	sub, _ := store.CreateSubscription(ctx, Subscription{URL: srv.URL, Secret: rc.secret, Active: true})
+	bus := event.NewBus(10)
+	d := newTestDispatcher(store, 3)
+	go d.Listen(ctx, bus)
+	go d.Run(ctx, time.Hour)
+	time.Sleep(10 * time.Millisecond)
+
+	bus.Publish(event.TypeCreated, "p1", &planet.Planet{ID: "p1"})
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := store.ListDeliveries(ctx, sub.ID, 0)
+		return len(deliveries) == 1 && deliveries[0].Status == StatusSucceeded
+	}, 2*time.Second, 5*time.Millisecond, "The bus events should be delivered without waiting for the poll interval")
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(NewMemoryStore(), Options{Backoff: 10 * time.Second, MaxBackoff: time.Minute})
+
+	assert.InDelta(t, float64(10*time.Second), float64(d.backoff(1)), float64(time.Second))
+	assert.InDelta(t, float64(20*time.Second), float64(d.backoff(2)), float64(2*time.Second))
+	assert.InDelta(t, float64(40*time.Second), float64(d.backoff(3)), float64(4*time.Second))
+	assert.InDelta(t, float64(time.Minute), float64(d.backoff(10)), float64(6*time.Second))
+}
@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store which keeps the subscriptions and deliveries in memory
+type MemoryStore struct {
+	mu            sync.Mutex
+	seq           int
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscriptions: map[string]Subscription{}, deliveries: map[string]Delivery{}}
+}
+
+func (s *MemoryStore) nextID() string {
+	s.seq++
+	return strconv.Itoa(s.seq)
+}
+
+// CreateSubscription stores the subscription with a new id
+func (s *MemoryStore) CreateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.ID = s.nextID()
+	s.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+// FindSubscription finds the subscription by id
+func (s *MemoryStore) FindSubscription(ctx context.Context, id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+// ListSubscriptions returns the subscriptions ordered by creation
+func (s *MemoryStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := []Subscription{}
+	for _, sub := range s.subscriptions {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	return subs, nil
+}
+
+// UpdateSubscription replaces the subscription
+func (s *MemoryStore) UpdateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[sub.ID]; !ok {
+		return Subscription{}, ErrNotFound
+	}
+	s.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+// DeleteSubscription removes the subscription, its deliveries are kept on the log
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subscriptions, id)
+	return nil
+}
+
+// CreateDelivery stores the delivery with a new id
+func (s *MemoryStore) CreateDelivery(ctx context.Context, d Delivery) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.ID = s.nextID()
+	s.deliveries[d.ID] = d
+	return d, nil
+}
+
+// FindDelivery finds the delivery by id
+func (s *MemoryStore) FindDelivery(ctx context.Context, id string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	return d, nil
+}
+
+// UpdateDelivery replaces the delivery
+func (s *MemoryStore) UpdateDelivery(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deliveries[d.ID]; !ok {
+		return ErrNotFound
+	}
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// ClaimDue takes the oldest due delivery and pushes its next attempt by lease
+func (s *MemoryStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due *Delivery
+	for _, d := range s.deliveries {
+		if (d.Status == StatusPending || d.Status == StatusFailed) && !d.NextAttemptAt.After(now) {
+			if due == nil || d.NextAttemptAt.Before(due.NextAttemptAt) {
+				d := d
+				due = &d
+			}
+		}
+	}
+	if due == nil {
+		return Delivery{}, false, nil
+	}
+	due.NextAttemptAt = now.Add(lease)
+	s.deliveries[due.ID] = *due
+	return *due, true, nil
+}
+
+// ListDeliveries returns the last deliveries of the subscription, the newest first
+func (s *MemoryStore) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deliveries := []Delivery{}
+	for _, d := range s.deliveries {
+		if d.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			a, _ := strconv.Atoi(deliveries[i].ID)
+			b, _ := strconv.Atoi(deliveries[j].ID)
+			return a > b
+		}
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	created, err := s.CreateSubscription(ctx, Subscription{URL: "http://a", Active: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", created.ID)
+	s.CreateSubscription(ctx, Subscription{URL: "http://b"})
+
+	created.URL = "http://c"
+	updated, err := s.UpdateSubscription(ctx, created)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://c", updated.URL)
+	found, err := s.FindSubscription(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, updated, found)
+	subs, err := s.ListSubscriptions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, subs, 2)
+
+	assert.NoError(t, s.DeleteSubscription(ctx, "1"))
+	assert.Equal(t, ErrNotFound, s.DeleteSubscription(ctx, "1"))
+	_, err = s.FindSubscription(ctx, "1")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = s.UpdateSubscription(ctx, Subscription{ID: "9"})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryStoreClaimDue(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	now := time.Now().UTC()
+
+	late, _ := s.CreateDelivery(ctx, Delivery{SubscriptionID: "1", Status: StatusFailed, NextAttemptAt: now.Add(-time.Minute), CreatedAt: now})
+	s.CreateDelivery(ctx, Delivery{SubscriptionID: "1", Status: StatusPending, NextAttemptAt: now, CreatedAt: now})
+	s.CreateDelivery(ctx, Delivery{SubscriptionID: "1", Status: StatusPending, NextAttemptAt: now.Add(time.Hour), CreatedAt: now})
+	s.CreateDelivery(ctx, Delivery{SubscriptionID: "1", Status: StatusDead, NextAttemptAt: now, CreatedAt: now})
+
+	claimed, ok, err := s.ClaimDue(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, late.ID, claimed.ID, "The most late delivery should be claimed first")
+	assert.Equal(t, now.Add(time.Minute), claimed.NextAttemptAt)
+
+	_, ok, _ = s.ClaimDue(ctx, now, time.Minute)
+	assert.True(t, ok)
+	_, ok, _ = s.ClaimDue(ctx, now, time.Minute)
+	assert.False(t, ok, "The claimed, future and dead deliveries should not be due")
+
+	deliveries, err := s.ListDeliveries(ctx, "1", 3)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 3) {
+		assert.Equal(t, "4", deliveries[0].ID)
+	}
+	assert.Equal(t, ErrNotFound, s.UpdateDelivery(ctx, Delivery{ID: "9"}))
+}
@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type subscriptionMongoModel struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	URL       string             `bson:"url"`
+	Events    []string           `bson:"events"`
+	Secret    string             `bson:"secret"`
+	Active    bool               `bson:"active"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+func (m subscriptionMongoModel) toSubscription() Subscription {
+	return Subscription{ID: m.ID.Hex(), URL: m.URL, Events: m.Events, Secret: m.Secret, Active: m.Active, CreatedAt: m.CreatedAt}
+}
+
+type deliveryMongoModel struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	SubscriptionID string             `bson:"subscriptionId"`
+	EventID        string             `bson:"eventId"`
+	EventType      string             `bson:"eventType"`
+	Payload        []byte             `bson:"payload"`
+	Status         string             `bson:"status"`
+	Attempts       int                `bson:"attempts"`
+	LastStatusCode int                `bson:"lastStatusCode,omitempty"`
+	LastError      string             `bson:"lastError,omitempty"`
+	NextAttemptAt  time.Time          `bson:"nextAttemptAt"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt"`
+}
+
+func newDeliveryMongoModel(id primitive.ObjectID, d Delivery) deliveryMongoModel {
+	return deliveryMongoModel{
+		ID:             id,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		NextAttemptAt:  d.NextAttemptAt.UTC(),
+		CreatedAt:      d.CreatedAt.UTC(),
+		UpdatedAt:      d.UpdatedAt.UTC(),
+	}
+}
+
+func (m deliveryMongoModel) toDelivery() Delivery {
+	return Delivery{
+		ID:             m.ID.Hex(),
+		SubscriptionID: m.SubscriptionID,
+		EventID:        m.EventID,
+		EventType:      m.EventType,
+		Payload:        m.Payload,
+		Status:         m.Status,
+		Attempts:       m.Attempts,
+		LastStatusCode: m.LastStatusCode,
+		LastError:      m.LastError,
+		NextAttemptAt:  m.NextAttemptAt,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+	}
+}
+
+// MongoStore is a Store which keeps the subscriptions on the webhook MongoDB collection
+// and the delivery log on the webhook_delivery collection
+type MongoStore struct {
+	Subscriptions *mongo.Collection
+	Deliveries    *mongo.Collection
+}
+
+// NewMongoStore creates a Store instance to manipulate the webhooks on MongoDB, it ensures the indexes of the dispatcher and the log
+func NewMongoStore(ctx context.Context, db *mongo.Database) (MongoStore, error) {
+	s := MongoStore{Subscriptions: db.Collection("webhook"), Deliveries: db.Collection("webhook_delivery")}
+	_, err := s.Deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "subscriptionId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		return MongoStore{}, errors.Wrap(err, "Error creating the webhook delivery indexes")
+	}
+	return s, nil
+}
+
+// CreateSubscription inserts the subscription on Mongo
+func (s MongoStore) CreateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	model := subscriptionMongoModel{
+		ID:        primitive.NewObjectID(),
+		URL:       sub.URL,
+		Events:    sub.Events,
+		Secret:    sub.Secret,
+		Active:    sub.Active,
+		CreatedAt: sub.CreatedAt.UTC(),
+	}
+	if _, err := s.Subscriptions.InsertOne(ctx, model); err != nil {
+		return Subscription{}, err
+	}
+	return model.toSubscription(), nil
+}
+
+// FindSubscription finds the subscription by id on Mongo
+func (s MongoStore) FindSubscription(ctx context.Context, id string) (Subscription, error) {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Subscription{}, ErrNotFound
+	}
+	var model subscriptionMongoModel
+	err = s.Subscriptions.FindOne(ctx, bson.M{"_id": oID}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return Subscription{}, ErrNotFound
+	}
+	if err != nil {
+		return Subscription{}, err
+	}
+	return model.toSubscription(), nil
+}
+
+// ListSubscriptions returns the subscriptions on Mongo ordered by creation
+func (s MongoStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	cursor, err := s.Subscriptions.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var models []subscriptionMongoModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	subs := make([]Subscription, len(models))
+	for i, m := range models {
+		subs[i] = m.toSubscription()
+	}
+	return subs, nil
+}
+
+// UpdateSubscription changes the url, events, secret and active flag of the subscription on Mongo
+func (s MongoStore) UpdateSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	oID, err := primitive.ObjectIDFromHex(sub.ID)
+	if err != nil {
+		return Subscription{}, ErrNotFound
+	}
+	update := bson.M{"$set": bson.M{"url": sub.URL, "events": sub.Events, "secret": sub.Secret, "active": sub.Active}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var model subscriptionMongoModel
+	err = s.Subscriptions.FindOneAndUpdate(ctx, bson.M{"_id": oID}, update, opts).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return Subscription{}, ErrNotFound
+	}
+	if err != nil {
+		return Subscription{}, err
+	}
+	return model.toSubscription(), nil
+}
+
+// DeleteSubscription removes the subscription from Mongo, its deliveries are kept on the log
+func (s MongoStore) DeleteSubscription(ctx context.Context, id string) error {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := s.Subscriptions.DeleteOne(ctx, bson.M{"_id": oID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateDelivery inserts the delivery on Mongo
+func (s MongoStore) CreateDelivery(ctx context.Context, d Delivery) (Delivery, error) {
+	id := primitive.NewObjectID()
+	if _, err := s.Deliveries.InsertOne(ctx, newDeliveryMongoModel(id, d)); err != nil {
+		return Delivery{}, err
+	}
+	d.ID = id.Hex()
+	return d, nil
+}
+
+// FindDelivery finds the delivery by id on Mongo
+func (s MongoStore) FindDelivery(ctx context.Context, id string) (Delivery, error) {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Delivery{}, ErrNotFound
+	}
+	var model deliveryMongoModel
+	err = s.Deliveries.FindOne(ctx, bson.M{"_id": oID}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	return model.toDelivery(), nil
+}
+
+// UpdateDelivery replaces the delivery on Mongo
+func (s MongoStore) UpdateDelivery(ctx context.Context, d Delivery) error {
+	oID, err := primitive.ObjectIDFromHex(d.ID)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := s.Deliveries.ReplaceOne(ctx, bson.M{"_id": oID}, newDeliveryMongoModel(oID, d))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimDue takes the oldest due delivery with a single atomic update on Mongo
+func (s MongoStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error) {
+	filter := bson.M{
+		"status":        bson.M{"$in": []string{StatusPending, StatusFailed}},
+		"nextAttemptAt": bson.M{"$lte": now.UTC()},
+	}
+	update := bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease).UTC()}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"nextAttemptAt": 1}).SetReturnDocument(options.After)
+	var model deliveryMongoModel
+	err := s.Deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return Delivery{}, false, nil
+	}
+	if err != nil {
+		return Delivery{}, false, err
+	}
+	return model.toDelivery(), true, nil
+}
+
+// ListDeliveries returns the last deliveries of the subscription on Mongo, the newest first
+func (s MongoStore) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := s.Deliveries.Find(ctx, bson.M{"subscriptionId": subscriptionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var models []deliveryMongoModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, len(models))
+	for i, m := range models {
+		deliveries[i] = m.toDelivery()
+	}
+	return deliveries, nil
+}
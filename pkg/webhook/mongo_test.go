@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase keeps the store tests away from the starwars database used by the other packages
+const testDatabase = "starwars_webhook_test"
+
+func TestMongoStore(t *testing.T) {
+	client, err := connectMongoClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	defer client.Database(testDatabase).Drop(context.Background())
+
+	ctx := context.Background()
+	store, err := NewMongoStore(ctx, client.Database(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	sub, err := store.CreateSubscription(ctx, Subscription{URL: "http://a", Secret: "s", Active: true, CreatedAt: now})
+	assert.NoError(t, err)
+	sub.Events = []string{"planet.deleted"}
+	sub.Active = false
+	_, err = store.UpdateSubscription(ctx, sub)
+	assert.NoError(t, err)
+	found, err := store.FindSubscription(ctx, sub.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, sub, found)
+	subs, err := store.ListSubscriptions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, subs, 1)
+
+	d, err := store.CreateDelivery(ctx, Delivery{SubscriptionID: sub.ID, EventID: "e1", Payload: []byte("{}"), Status: StatusPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err)
+	claimed, ok, err := store.ClaimDue(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, d.ID, claimed.ID)
+	assert.Equal(t, now.Add(time.Minute), claimed.NextAttemptAt)
+	_, ok, _ = store.ClaimDue(ctx, now, time.Minute)
+	assert.False(t, ok, "A claimed delivery should not be claimed again before the lease ends")
+
+	claimed.Status = StatusSucceeded
+	claimed.Attempts = 1
+	assert.NoError(t, store.UpdateDelivery(ctx, claimed))
+	deliveries, err := store.ListDeliveries(ctx, sub.ID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Delivery{claimed}, deliveries)
+
+	assert.NoError(t, store.DeleteSubscription(ctx, sub.ID))
+	assert.Equal(t, ErrNotFound, store.DeleteSubscription(ctx, sub.ID))
+	_, err = store.FindSubscription(ctx, "invalid")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.FindDelivery(ctx, "000000000000000000000000")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func connectMongoClient() (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.Database(testDatabase).Drop(context.Background())
+	return client, nil
+}
@@ -0,0 +1,152 @@
+// Package webhook delivers the planet events to the subscribed URLs, signed with HMAC and retried with backoff
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+)
+
+// The delivery statuses, a failed delivery is retried until it succeeds or goes to the dead letter
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusDead      = "dead"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the payload, in the "t=<unix time>,v1=<hex>" format
+const SignatureHeader = "X-Stars-Signature"
+
+// ErrNotFound is returned when no subscription or delivery matches the lookup
+var ErrNotFound = errors.New("webhook not found")
+
+// Subscription is the struct which describes where the events are delivered, no event type means every type
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Wants reports whether the subscription receives the event type
+func (s Subscription) Wants(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the subscription URL and event types
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("the webhook url must be an absolute http or https URL")
+	}
+	for _, e := range s.Events {
+		if e != event.TypeCreated && e != event.TypeUpdated && e != event.TypeDeleted {
+			return errors.New("unknown webhook event " + strconv.Quote(e))
+		}
+	}
+	return nil
+}
+
+// Delivery is the struct which tracks the delivery of one event to one subscription, it is the delivery log
+type Delivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscriptionId"`
+	EventID        string    `json:"eventId"`
+	EventType      string    `json:"eventType"`
+	Payload        []byte    `json:"-"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastStatusCode int       `json:"lastStatusCode,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// Store is the interface used to keep the subscriptions and the deliveries
+type Store interface {
+	CreateSubscription(ctx context.Context, s Subscription) (Subscription, error)
+	FindSubscription(ctx context.Context, id string) (Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	UpdateSubscription(ctx context.Context, s Subscription) (Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+
+	CreateDelivery(ctx context.Context, d Delivery) (Delivery, error)
+	FindDelivery(ctx context.Context, id string) (Delivery, error)
+	UpdateDelivery(ctx context.Context, d Delivery) error
+	// ClaimDue takes a pending or failed delivery due at now and pushes its next attempt by lease,
+	// so a single dispatcher sends it even with many instances
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error)
+	// ListDeliveries returns the deliveries of the subscription, the newest first
+	ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error)
+}
+
+// GenerateSecret creates a random secret to sign the payloads
+func GenerateSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature header value of the payload sent at the given time
+func Sign(secret string, at time.Time, payload []byte) string {
+	t := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + t + ",v1=" + mac(secret, t, payload)
+}
+
+// Verify checks a signature header value, refusing the ones older than tolerance to prevent replays
+func Verify(secret, header string, payload []byte, now time.Time, tolerance time.Duration) bool {
+	var t, signature string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			t = kv[1]
+		case "v1":
+			signature = kv[1]
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || signature == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(mac(secret, t, payload)))
+}
+
+func mac(secret, t string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t + "."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
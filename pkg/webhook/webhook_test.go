@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaelreinert/stars/pkg/planet/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"type":"planet.created"}`)
+	at := time.Unix(1600000000, 0)
+	header := Sign("secret", at, payload)
+
+	assert.True(t, strings.HasPrefix(header, "t=1600000000,v1="))
+	assert.True(t, Verify("secret", header, payload, at.Add(time.Minute), 5*time.Minute))
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+	}{
+		{name: "wrong secret", secret: "other", header: header, payload: payload, now: at},
+		{name: "changed payload", secret: "secret", header: header, payload: []byte(`{}`), now: at},
+		{name: "replayed", secret: "secret", header: header, payload: payload, now: at.Add(10 * time.Minute)},
+		{name: "no timestamp", secret: "secret", header: header[strings.Index(header, ",")+1:], payload: payload, now: at},
+		{name: "no signature", secret: "secret", header: "t=1600000000", payload: payload, now: at},
+		{name: "garbage", secret: "secret", header: "garbage", payload: payload, now: at},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.False(t, Verify(tt.secret, tt.header, tt.payload, tt.now, 5*time.Minute))
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	assert.NoError(t, err)
+	b, _ := GenerateSecret()
+
+	assert.True(t, strings.HasPrefix(a, "whsec_"))
+	assert.Len(t, a, len("whsec_")+48)
+	assert.NotEqual(t, a, b)
+}
+
+func TestSubscriptionWants(t *testing.T) {
+	all := Subscription{Active: true}
+	deletes := Subscription{Active: true, Events: []string{event.TypeDeleted}}
+	inactive := Subscription{}
+
+	assert.True(t, all.Wants(event.TypeCreated))
+	assert.True(t, deletes.Wants(event.TypeDeleted))
+	assert.False(t, deletes.Wants(event.TypeUpdated))
+	assert.False(t, inactive.Wants(event.TypeCreated))
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		sub   Subscription
+		valid bool
+	}{
+		{name: "http", sub: Subscription{URL: "http://localhost:9000/hook"}, valid: true},
+		{name: "https with events", sub: Subscription{URL: "https://example.com", Events: []string{event.TypeCreated, event.TypeDeleted}}, valid: true},
+		{name: "relative", sub: Subscription{URL: "/hook"}},
+		{name: "ftp", sub: Subscription{URL: "ftp://example.com"}},
+		{name: "empty", sub: Subscription{}},
+		{name: "unknown event", sub: Subscription{URL: "https://example.com", Events: []string{"planet.renamed"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sub.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}